@@ -0,0 +1,181 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+var (
+	algorithmName = flag.String("algorithm", "levenshtein", "how to score the similarity of two files' code: levenshtein (edit distance), jaccard (shared distinct lines), cosine (token frequencies), lcs (longest common subsequence of lines), shingles (shared runs of --shingle-size tokens), simhash (a coarse but very fast estimate), or embedding (what the code means, per --embeddings)")
+	shingleSize   = flag.Int("shingle-size", 5, "with --algorithm=shingles, how many tokens make up a shingle")
+)
+
+// A similarityAlgorithm scores how alike two files' normalized code is, from 0 to 1, and says
+// whether it had to settle for an estimate.
+type similarityAlgorithm interface {
+	similarity(code1, code2 string) (score float64, timedOut bool)
+}
+
+var algorithms = map[string]similarityAlgorithm{
+	"levenshtein": levenshteinAlgorithm{},
+	"jaccard":     jaccardAlgorithm{},
+	"cosine":      cosineAlgorithm{},
+	"lcs":         lcsAlgorithm{},
+	"shingles":    shinglesAlgorithm{},
+	"simhash":     simhashAlgorithm{},
+	"embedding":   embeddingAlgorithm{},
+}
+
+func checkAlgorithm() error {
+	if algorithms[*algorithmName] == nil {
+		return fmt.Errorf("unknown --algorithm %q (want one of %s)", *algorithmName, strings.Join(sortedKeys(algorithms), ", "))
+	}
+	if *shingleSize < 1 {
+		return fmt.Errorf("--shingle-size must be at least 1")
+	}
+	return nil
+}
+
+// similarity scores two files' code with the --comparator or --algorithm in use.
+func similarity(code1, code2 string) (float64, bool) {
+	return scorerFor(nil).similarity(code1, code2)
+}
+
+// scorerFor returns the algorithm to score files of a language with: the language's own, if the
+// config file gives it one, or else the --comparator or --algorithm in use. Algorithms that
+// compare tokens split the code into them as the language says.
+func scorerFor(lang *language) similarityAlgorithm {
+	name := *algorithmName
+	if lang != nil && lang.algorithm != "" {
+		name = lang.algorithm
+	} else if len(comparatorArgs) > 0 {
+		return externalComparator{}
+	}
+	switch alg := algorithms[name].(type) {
+	case cosineAlgorithm:
+		alg.tokens = lang.tokenizer()
+		return alg
+	case shinglesAlgorithm:
+		alg.tokens = lang.tokenizer()
+		return alg
+	}
+	return algorithms[name]
+}
+
+// levenshteinAlgorithm scores by the edit distance between the files, relative to the longer one.
+// It's thorough, but slow on large, dissimilar files, and penalizes moving code around.
+type levenshteinAlgorithm struct{}
+
+func (levenshteinAlgorithm) similarity(code1, code2 string) (float64, bool) {
+	d := cachedDiff(code1, code2)
+	return d.asPercentage(), d.timedOut
+}
+
+// jaccardAlgorithm scores by the distinct lines the files share, ignoring their order and how
+// often each appears.
+type jaccardAlgorithm struct{}
+
+func (jaccardAlgorithm) similarity(code1, code2 string) (float64, bool) {
+	return jaccard(fingerprintOf(code1), fingerprintOf(code2)), false
+}
+
+// cosineAlgorithm scores by the angle between the files' token frequency vectors, so that it
+// ignores order entirely but notices how often each token is used.
+type cosineAlgorithm struct {
+	tokens tokenizer
+}
+
+func tokenFrequencies(code string, tokens tokenizer) map[string]float64 {
+	counts := make(map[string]float64)
+	tokens.each(code, func(token string) { counts[token]++ })
+	return counts
+}
+
+func (a cosineAlgorithm) similarity(code1, code2 string) (float64, bool) {
+	counts1, counts2 := tokenFrequencies(code1, a.tokens), tokenFrequencies(code2, a.tokens)
+	if len(counts1) == 0 && len(counts2) == 0 {
+		return 1, false
+	}
+	var dot, norm1, norm2 float64
+	for token, n := range counts1 {
+		dot += n * counts2[token]
+		norm1 += n * n
+	}
+	for _, n := range counts2 {
+		norm2 += n * n
+	}
+	if norm1 == 0 || norm2 == 0 {
+		return 0, false
+	}
+	return dot / math.Sqrt(norm1*norm2), false
+}
+
+// lcsAlgorithm scores by the longest common subsequence of the files' lines, as classic diff
+// finds it: the fraction of their lines that the files share. Changes within a line count as
+// much as replacing it. It's faster than levenshtein, since there are far fewer lines than
+// characters.
+type lcsAlgorithm struct{}
+
+// sharedLines returns how many lines are in the longest common subsequence of the lines of two
+// files, and how many lines each has.
+func sharedLines(code1, code2 string) (shared, lines1, lines2 int) {
+	for _, line := range lineDiff(code1, code2) {
+		switch line.op {
+		case diffmatchpatch.DiffEqual:
+			shared++
+			lines1++
+			lines2++
+		case diffmatchpatch.DiffDelete:
+			lines1++
+		case diffmatchpatch.DiffInsert:
+			lines2++
+		}
+	}
+	return shared, lines1, lines2
+}
+
+func (lcsAlgorithm) similarity(code1, code2 string) (float64, bool) {
+	shared, lines1, lines2 := sharedLines(code1, code2)
+	if lines1+lines2 == 0 {
+		return 1, false
+	}
+	return 2 * float64(shared) / float64(lines1+lines2), false
+}
+
+// shinglesAlgorithm scores by the runs of --shingle-size tokens the files share. It's fast, and
+// since it only looks at short runs, moving whole functions around doesn't change the score.
+type shinglesAlgorithm struct {
+	tokens tokenizer
+}
+
+// shingles returns the hashes of the distinct runs of n tokens in code, in the same form as a
+// fingerprint. Code of fewer than n tokens is one shingle.
+func shingles(code string, n int, each tokenizer) fingerprint {
+	var tokens []string
+	each.each(code, func(token string) { tokens = append(tokens, token) })
+	seen := make(map[uint64]bool)
+	var fp fingerprint
+	for i := 0; i == 0 || i+n <= len(tokens); i++ {
+		h := fnv.New64a()
+		for _, token := range tokens[i:min(i+n, len(tokens))] {
+			h.Write([]byte(token))
+			h.Write([]byte{0})
+		}
+		if sum := h.Sum64(); !seen[sum] {
+			seen[sum] = true
+			fp = append(fp, sum)
+		}
+	}
+	sort.Slice(fp, func(i, j int) bool { return fp[i] < fp[j] })
+	return fp
+}
+
+func (a shinglesAlgorithm) similarity(code1, code2 string) (float64, bool) {
+	return jaccard(shingles(code1, *shingleSize, a.tokens), shingles(code2, *shingleSize, a.tokens)), false
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+var alignmentsPath = flag.String("alignments", "", "write the aligned line ranges of each target file and its match to this JSON file, e.g. to annotate editors or code review with where code came from upstream")
+
+// Lines that differ between two runs of equal lines, up to this many, don't break an aligned range.
+const maxAlignGap = 3
+
+// alignedRanges splits the line diff of a target file against a source file into ranges of lines
+// that correspond, as indices of normalized lines. Each range starts and ends with lines in
+// common, and has no more than maxAlignGap differing lines in a row.
+func alignedRanges(source, target string) []snippet {
+	steps := alignSteps(source, target)
+	var ranges []snippet
+	start, lastEqual := -1, -1
+	flush := func() {
+		if start >= 0 {
+			r, _ := spanOf(steps[start : lastEqual+1])
+			ranges = append(ranges, r)
+		}
+		start = -1
+	}
+	for i, st := range steps {
+		if st.op != diffmatchpatch.DiffEqual {
+			if start >= 0 && i-lastEqual > maxAlignGap {
+				flush()
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+		lastEqual = i
+	}
+	flush()
+	return ranges
+}
+
+type alignedRange struct {
+	TargetStart int     `json:"target_start"`
+	TargetEnd   int     `json:"target_end"`
+	SourceStart int     `json:"source_start"`
+	SourceEnd   int     `json:"source_end"`
+	Similarity  float64 `json:"similarity"`
+}
+
+type alignment struct {
+	Path   string         `json:"path"`
+	Match  string         `json:"match"`
+	Ranges []alignedRange `json:"ranges"`
+}
+
+// writeAlignments writes the aligned line ranges of every matched target file to path. Lines are
+// numbered as in the files, from 1, and ranges are inclusive.
+func writeAlignments(path string, c *comparison) error {
+	if c.lineNumbers == nil {
+		c.lineNumbers = make(map[string][]int)
+	}
+	alignments := []alignment{}
+	for _, result := range c.results {
+		source, ok := c.sourceContents(result.matchedFilename)
+		if !ok {
+			continue
+		}
+		a := alignment{Path: c.relTarget(result.filename), Match: c.sourceLabel(result.matchedFilename), Ranges: []alignedRange{}}
+		for _, r := range alignedRanges(source, c.targetContents(result.filename)) {
+			a.Ranges = append(a.Ranges, alignedRange{
+				TargetStart: c.fileLine(result.filename, r.targetStart),
+				TargetEnd:   c.fileLine(result.filename, r.targetEnd),
+				SourceStart: c.fileLine(result.matchedFilename, r.sourceStart),
+				SourceEnd:   c.fileLine(result.matchedFilename, r.sourceEnd),
+				Similarity:  r.similarity,
+			})
+		}
+		alignments = append(alignments, a)
+	}
+	data, err := json.MarshalIndent(alignments, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
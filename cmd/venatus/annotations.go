@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	githubAnnotations = flag.Bool("github-annotations", false, "print GitHub Actions workflow commands for target files scoring below --warning-below or --error-below, so that they are annotated in the Checks UI")
+	warningBelow      = flag.Float64("warning-below", 0.8, "with --github-annotations, warn about target files scoring below this, from 0 to 1")
+	errorBelow        = flag.Float64("error-below", 0, "with --github-annotations, flag as errors target files scoring below this, from 0 to 1")
+)
+
+func checkAnnotations() error {
+	if *warningBelow < 0 || *warningBelow > 1 || *errorBelow < 0 || *errorBelow > 1 {
+		return fmt.Errorf("--warning-below and --error-below must be between 0 and 1")
+	}
+	return nil
+}
+
+// escapeData escapes the message of a workflow command.
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeProperty escapes a property of a workflow command.
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// printAnnotations prints a workflow command for each target file scoring below the thresholds.
+// Paths are relative to the working directory, which in Actions is the workspace, as the
+// annotations need.
+func printAnnotations(c *comparison) {
+	if !*githubAnnotations {
+		return
+	}
+	wd, _ := os.Getwd()
+	fmt.Println()
+	for _, result := range c.results {
+		level := ""
+		switch {
+		case result.matchSimilarity < *errorBelow:
+			level = "error"
+		case result.matchSimilarity < *warningBelow:
+			level = "warning"
+		default:
+			continue
+		}
+		path := result.filename
+		if rel, err := filepath.Rel(wd, path); err == nil {
+			path = rel
+		}
+		message := "no similar upstream file"
+		if result.matchedFilename != "N/A" {
+			message = fmt.Sprintf("%v similar to %s", percentage(result.matchSimilarity), c.sourceLabel(result.matchedFilename))
+		}
+		fmt.Printf("::%s file=%s,title=%s::%s\n", level, escapeProperty(filepath.ToSlash(path)), escapeProperty("Low upstream similarity"), escapeData(message))
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// readTokens reads a file of "<client> <token>" lines, ignoring blank lines and # comments.
+// Returns a map from token to client name.
+func readTokens(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	tokens := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: want \"<client> <token>\"", path, line)
+		}
+		tokens[fields[1]] = fields[0]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("%s: no tokens", path)
+	}
+	return tokens, nil
+}
+
+// authenticate wraps h so that only requests bearing one of the given tokens get through.
+// Browsers can't easily set headers, so the token is also accepted as a ?token= query parameter,
+// which sets a cookie for subsequent requests (e.g., the dashboard fetching diffs).
+// The name of the authenticated client is passed along in the request's context.
+func authenticate(tokens map[string]string, h http.Handler) http.Handler {
+	const cookieName = "venatus-token"
+	lookup := func(presented string) (string, bool) {
+		for token, client := range tokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(presented)) == 1 {
+				return client, true
+			}
+		}
+		return "", false
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var presented string
+		if auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			presented = auth
+		} else if q := r.URL.Query().Get("token"); q != "" {
+			presented = q
+			http.SetCookie(w, &http.Cookie{Name: cookieName, Value: q, Path: "/", HttpOnly: true, SameSite: http.SameSiteStrictMode})
+		} else if cookie, err := r.Cookie(cookieName); err == nil {
+			presented = cookie.Value
+		}
+		client, ok := lookup(presented)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientKey{}, client)))
+	})
+}
+
+type clientKey struct{}
+
+// clientName returns who made the request: the authenticated client if there is one, or else
+// the remote host.
+func clientName(r *http.Request) string {
+	if client, ok := r.Context().Value(clientKey{}).(string); ok {
+		return client
+	}
+	return remoteHost(r)
+}
+
+func remoteHost(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// How often the rate limiter forgets the hosts that have stopped making requests.
+const pruneInterval = time.Minute
+
+// rateLimiter is a token bucket per remote host.
+type rateLimiter struct {
+	mu        sync.Mutex
+	perSec    float64
+	burst     float64
+	buckets   map[string]*bucket
+	lastPrune time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(perMinute int) *rateLimiter {
+	return &rateLimiter{
+		perSec:    float64(perMinute) / 60,
+		burst:     float64(perMinute),
+		buckets:   make(map[string]*bucket),
+		lastPrune: time.Now(),
+	}
+}
+
+func (l *rateLimiter) allow(client string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if now.Sub(l.lastPrune) >= pruneInterval {
+		l.prune(now)
+	}
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[client] = b
+	}
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.perSec)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// prune forgets the buckets that have filled up again, which are just like new ones, so that
+// hosts that have come and gone don't take up memory forever.
+func (l *rateLimiter) prune(now time.Time) {
+	for client, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.perSec >= l.burst {
+			delete(l.buckets, client)
+		}
+	}
+	l.lastPrune = now
+}
+
+// limit wraps h so that each remote host can only make so many requests per minute.
+func (l *rateLimiter) limit(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !l.allow(remoteHost(r)) {
+			w.Header().Set("Retry-After", "60")
+			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// auditLog records each submitted comparison as a line of JSON.
+type auditLog struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+func openAuditLog(path string) (*auditLog, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &auditLog{f: f}, nil
+}
+
+func (a *auditLog) record(r *http.Request, j *job) {
+	if a == nil {
+		return
+	}
+	line, _ := json.Marshal(map[string]string{
+		"time":   time.Now().UTC().Format(time.RFC3339),
+		"client": clientName(r),
+		"remote": r.RemoteAddr,
+		"job":    j.id,
+		"source": j.source,
+		"target": j.target,
+	})
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.f.Write(append(line, '\n'))
+}
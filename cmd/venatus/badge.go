@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html"
+	"os"
+)
+
+var badgePath = flag.String("badge", "", "write a shields.io-style SVG badge of the overall score to this file, for projects to show in their README how closely they track upstream")
+
+const badgeLabel = "upstream similarity"
+
+// badgeColor picks a badge's color by score, from shields.io's palette.
+func badgeColor(score float64) string {
+	switch {
+	case score >= 0.9:
+		return "#4c1"
+	case score >= 0.8:
+		return "#97ca00"
+	case score >= 0.6:
+		return "#dfb317"
+	}
+	return "#e05d44"
+}
+
+// textWidth estimates how many pixels wide text is in the badge's 11px Verdana.
+func textWidth(s string) int {
+	return len(s)*7 + 10
+}
+
+// writeBadge writes a badge of the overall score to path.
+func writeBadge(path string, c *comparison) error {
+	value := percentage(c.overallScore).String()
+	labelWidth, valueWidth := textWidth(badgeLabel), textWidth(value)
+	width := labelWidth + valueWidth
+	svg := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="20" role="img" aria-label="%[2]s: %[3]s">
+<title>%[2]s: %[3]s</title>
+<linearGradient id="s" x2="0" y2="100%%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>
+<clipPath id="r"><rect width="%[1]d" height="20" rx="3" fill="#fff"/></clipPath>
+<g clip-path="url(#r)">
+<rect width="%[4]d" height="20" fill="#555"/>
+<rect x="%[4]d" width="%[5]d" height="20" fill="%[6]s"/>
+<rect width="%[1]d" height="20" fill="url(#s)"/>
+</g>
+<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+<text x="%[7]d" y="15" fill="#010101" fill-opacity=".3">%[2]s</text>
+<text x="%[7]d" y="14">%[2]s</text>
+<text x="%[8]d" y="15" fill="#010101" fill-opacity=".3">%[3]s</text>
+<text x="%[8]d" y="14">%[3]s</text>
+</g>
+</svg>
+`, width, html.EscapeString(badgeLabel), html.EscapeString(value), labelWidth, valueWidth, badgeColor(c.overallScore), labelWidth/2, labelWidth+valueWidth/2)
+	return os.WriteFile(path, []byte(svg), 0644)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"sort"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+var compareBinaries = flag.Bool("binaries", false, "also compare non-text files (firmware blobs, images, archives) by fuzzy hash, and report them separately")
+
+// How much of a file to look at to decide whether it's binary.
+const binarySniffLen = 8000
+
+// isBinary reports whether a file looks like binary data: that is, it has a NUL byte near the
+// start, as text in any encoding but UTF-16 and UTF-32 doesn't.
+func isBinary(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	head := make([]byte, binarySniffLen)
+	n, _ := io.ReadFull(f, head)
+	return bytes.IndexByte(head[:n], 0) >= 0
+}
+
+// hashBinaryFiles fuzzy-hashes all the binary files in a repo.
+// Like openAllCodeFiles, it skips what it can't read, and records it in the report.
+func hashBinaryFiles(root string, report *walkReport) map[string]fuzzyHash {
+	hashes := make(map[string]fuzzyHash)
+	walkTree(root, func(path string, info fs.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() || info.Size() == 0 {
+			return nil
+		}
+		if languageOf(path, info) != nil || !isBinary(path) {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			report.skipped(path, info, err)
+			return nil
+		}
+		hashes[path] = fuzzyHashOf(data)
+		slog.Debug("Hashed binary file", "path", path, "hash", hashes[path])
+		return nil
+	})
+	return hashes
+}
+
+// A binaryResult is the best match for a binary target file.
+type binaryResult struct {
+	filename, matchedFilename string
+	similarity                float64
+	size                      int64
+}
+
+// matchBinaries finds the best match for each binary target file among the binary source files.
+func (c *comparison) matchBinaries() {
+	source := make(map[string]fuzzyHash)
+	for _, s := range c.sources {
+		if isIndexFile(s.root) {
+			slog.Warn("Indexes don't include binary files", "source", s.name)
+			continue
+		}
+		for path, h := range hashBinaryFiles(s.root, c.sourceReport) {
+			source[path] = h
+		}
+	}
+	for path, h := range hashBinaryFiles(c.targetRoot, c.targetReport) {
+		r := &binaryResult{filename: path, matchedFilename: "N/A"}
+		if info, err := os.Stat(path); err == nil {
+			r.size = info.Size()
+		}
+		for sourcePath, sourceHash := range source {
+			similarity := fuzzySimilarity(h, sourceHash)
+			if similarity > r.similarity || similarity == r.similarity && similarity > 0 && sourcePath < r.matchedFilename {
+				r.similarity = similarity
+				r.matchedFilename = sourcePath
+			}
+		}
+		c.binaryResults = append(c.binaryResults, r)
+	}
+	sort.Slice(c.binaryResults, func(i, j int) bool {
+		a, b := c.binaryResults[i], c.binaryResults[j]
+		if a.size != b.size {
+			return a.size > b.size
+		}
+		return a.filename < b.filename
+	})
+}
+
+// renderBinaryTable tabularizes the binary results. They aren't part of the overall score, which
+// is about code.
+func renderBinaryTable(c *comparison) string {
+	tw := table.NewWriter()
+	tw.SetStyle(table.StyleDouble)
+	tw.SetTitle("Binary files")
+	tw.AppendHeader(table.Row{"Path", "Best match", "Similarity", "Size"})
+	for _, r := range c.binaryResults {
+		tw.AppendRow(table.Row{
+			c.relTarget(r.filename),
+			c.sourceLabel(r.matchedFilename),
+			percentage(r.similarity),
+			byteSize(r.size),
+		})
+	}
+	tw.SetColumnConfigs([]table.ColumnConfig{
+		{Number: 3, Align: text.AlignRight},
+		{Number: 4, Align: text.AlignRight},
+	})
+	return tw.Render()
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+var minTokenOverlap = flag.Float64("min-token-overlap", 0, "only compare files whose sets of distinct tokens, estimated from Bloom filters, overlap by at least this fraction (0 to 1), to prune candidates cheaply before scoring them (0 compares all)")
+
+// Size of each file's Bloom filter, in bits and 64-bit words, and how many bits each token sets.
+const (
+	bloomBits   = 4096
+	bloomWords  = bloomBits / 64
+	bloomHashes = 3
+)
+
+// A bloomFilter is a Bloom filter of the distinct tokens of a file. Comparing two estimates how
+// many tokens the files share, in a fixed 512 bytes per file however large the file is.
+type bloomFilter [bloomWords]uint64
+
+func bloomOf(code string) *bloomFilter {
+	var b bloomFilter
+	forEachToken(code, func(token string) {
+		h := fnv.New64a()
+		h.Write([]byte(token))
+		sum := h.Sum64()
+		// Double hashing: the i'th bit is h1 + i*h2.
+		h1, h2 := uint32(sum), uint32(sum>>32)
+		for i := uint32(0); i < bloomHashes; i++ {
+			bit := (h1 + i*h2) % bloomBits
+			b[bit/64] |= 1 << (bit % 64)
+		}
+	})
+	return &b
+}
+
+// estimateSize estimates how many distinct tokens are in a filter with the given number of bits set.
+func estimateSize(set int) float64 {
+	if set >= bloomBits {
+		set = bloomBits - 1
+	}
+	return -bloomBits / float64(bloomHashes) * math.Log(1-float64(set)/bloomBits)
+}
+
+func (b *bloomFilter) count() int {
+	n := 0
+	for _, w := range b {
+		n += bits.OnesCount64(w)
+	}
+	return n
+}
+
+// overlap estimates the fraction of the distinct tokens of two files that are in both.
+func (b *bloomFilter) overlap(other *bloomFilter) float64 {
+	union := 0
+	for i := range b {
+		union += bits.OnesCount64(b[i] | other[i])
+	}
+	all := estimateSize(union)
+	if all == 0 {
+		return 1
+	}
+	shared := estimateSize(b.count()) + estimateSize(other.count()) - all
+	return max(0, min(1, shared/all))
+}
+
+func checkTokenOverlap() error {
+	if *minTokenOverlap < 0 || *minTokenOverlap > 1 {
+		return fmt.Errorf("--min-token-overlap must be between 0 and 1")
+	}
+	return nil
+}
+
+// filtersByTokens reports whether candidates are filtered by their Bloom filters.
+func filtersByTokens() bool {
+	return *minTokenOverlap > 0
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"sync"
+	"unicode/utf8"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// A breakdown explains a score: how many characters of normalized code the target file has in
+// common with its match, and how many it has inserted or deleted relative to it.
+type breakdown struct {
+	equal, inserted, deleted int
+	// The longest run of characters the files have in common.
+	longestEqual int
+}
+
+var (
+	breakdownsMu sync.Mutex
+	breakdowns   = make(map[*findResult]*breakdown)
+)
+
+// breakdown works out the breakdown of a result's score, for the columns that show it. Only
+// matches are broken down, since there's nothing to explain otherwise.
+func (c *comparison) breakdown(result *findResult) *breakdown {
+	breakdownsMu.Lock()
+	defer breakdownsMu.Unlock()
+	if b, ok := breakdowns[result]; ok {
+		return b
+	}
+	source, ok := c.sourceContents(result.matchedFilename)
+	if !ok {
+		breakdowns[result] = nil
+		return nil
+	}
+	b := &breakdown{}
+	for _, d := range dmp.DiffMain(source, c.targetContents(result.filename), false) {
+		n := utf8.RuneCountInString(d.Text)
+		switch d.Type {
+		case diffmatchpatch.DiffEqual:
+			b.equal += n
+			b.longestEqual = max(b.longestEqual, n)
+		case diffmatchpatch.DiffInsert:
+			b.inserted += n
+		case diffmatchpatch.DiffDelete:
+			b.deleted += n
+		}
+	}
+	breakdowns[result] = b
+	return b
+}
+
+// breakdownCell is a column cell showing part of a result's breakdown.
+func breakdownCell(part func(b *breakdown) int) func(c *comparison, result *findResult) interface{} {
+	return func(c *comparison, result *findResult) interface{} {
+		if b := c.breakdown(result); b != nil {
+			return part(b)
+		}
+		return ""
+	}
+}
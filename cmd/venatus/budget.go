@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+)
+
+var (
+	budget         = flag.Duration("budget", 0, "how long to spend comparing, e.g. 30m; once it is spent, the remaining pairs of files are scored by their fingerprints, which is quick but rough, and flagged as approximate (0 for no limit)")
+	maxComparisons = flag.Int64("max-comparisons", 0, "how many pairs of files to compare in full; the rest are scored by their fingerprints, as when --budget is spent (0 for no limit)")
+)
+
+func checkBudget() error {
+	if *budget < 0 {
+		return fmt.Errorf("--budget must not be negative")
+	}
+	if *maxComparisons < 0 {
+		return fmt.Errorf("--max-comparisons must not be negative")
+	}
+	return nil
+}
+
+// overBudget reports whether the run has used up --budget or --max-comparisons, so that pairs
+// should only be scored roughly from now on. Otherwise it counts another full comparison.
+func (c *comparison) overBudget() bool {
+	if *budget > 0 && time.Since(c.compareStart) > *budget {
+		return true
+	}
+	if *maxComparisons > 0 {
+		return c.comparisons.Add(1) > *maxComparisons
+	}
+	return false
+}
+
+// roughSimilarity scores a pair of files by how many of their fingerprints they share, in
+// place of a full comparison once the budget is spent.
+func roughSimilarity(print fingerprint, code string) float64 {
+	return jaccard(print, fingerprintOf(code))
+}
+
+// printApproximate says how many files were scored roughly because the budget ran out.
+func printApproximate(c *comparison) {
+	n := 0
+	for _, result := range c.results {
+		if result.approximate {
+			n++
+		}
+	}
+	if n == 0 {
+		return
+	}
+	fmt.Printf("\n\n%d target files were scored approximately, from their fingerprints, once --budget or --max-comparisons was spent.\n", n)
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// A pairCache remembers the outcome of comparing pairs of files, so that unchanged pairs don't
+// need to be diffed again on the next run (or, with a remote cache, on another machine).
+// Caches are best-effort: failures to read or write are treated as misses.
+type pairCache interface {
+	get(key string) (*result, bool)
+	put(key string, r *result)
+}
+
+// pairs is the cache in use, if any.
+var pairs pairCache
+
+// cachedDiff is diff, going through the pair cache if there is one.
+func cachedDiff(contents1, contents2 string) *result {
+	return cachedDiffWith(dmp, contents1, contents2)
+}
+
+// cachedDiffWith is cachedDiff with the given diff settings.
+func cachedDiffWith(differ *diffmatchpatch.DiffMatchPatch, contents1, contents2 string) *result {
+	if pairs == nil {
+		return diffWith(differ, contents1, contents2)
+	}
+	key := pairKey(differ, contents1, contents2)
+	if r, ok := pairs.get(key); ok {
+		pairCacheHits.Add(1)
+		return r
+	}
+	pairCacheMisses.Add(1)
+	r := diffWith(differ, contents1, contents2)
+	pairs.put(key, r)
+	return r
+}
+
+// pairKey identifies a comparison by the contents compared and the settings that affect the score.
+func pairKey(differ *diffmatchpatch.DiffMatchPatch, contents1, contents2 string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "venatus pair v3\x00%v\x00%d\x00%d\x00", differ.DiffTimeout, differ.DiffEditCost, *chunkSize)
+	binary.Write(h, binary.LittleEndian, uint64(len(contents1)))
+	io.WriteString(h, contents1)
+	io.WriteString(h, contents2)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func encodeResult(r *result) []byte {
+	return []byte(fmt.Sprintf("%d %d %t\n", r.levenshtein, r.length, r.timedOut))
+}
+
+func decodeResult(data []byte) (*result, bool) {
+	var r result
+	if _, err := fmt.Sscanf(string(data), "%d %d %t\n", &r.levenshtein, &r.length, &r.timedOut); err != nil {
+		return nil, false
+	}
+	return &r, true
+}
+
+// openPairCache sets up the cache described by the given flags: a local directory, a remote
+// cache, or the local directory in front of the remote one.
+func openPairCache(dir, remote string) (pairCache, error) {
+	var local, far pairCache
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+		local = dirCache(dir)
+	}
+	if remote != "" {
+		var err error
+		if far, err = openRemoteCache(remote); err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case local != nil && far != nil:
+		return tieredCache{local, far}, nil
+	case local != nil:
+		return local, nil
+	case far != nil:
+		return far, nil
+	}
+	return nil, nil
+}
+
+// dirCache keeps one small file per pair in a local directory.
+type dirCache string
+
+func (d dirCache) path(key string) string {
+	return filepath.Join(string(d), key[:2], key)
+}
+
+func (d dirCache) get(key string) (*result, bool) {
+	data, err := os.ReadFile(d.path(key))
+	if err != nil {
+		return nil, false
+	}
+	return decodeResult(data)
+}
+
+func (d dirCache) put(key string, r *result) {
+	path := d.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	// Write-then-rename, so that concurrent runs sharing a directory never see half a file.
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(encodeResult(r))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+	os.Rename(tmp.Name(), path)
+}
+
+// tieredCache consults a fast cache before a slow one, and fills the fast one from the slow one.
+type tieredCache struct {
+	near, far pairCache
+}
+
+func (t tieredCache) get(key string) (*result, bool) {
+	if r, ok := t.near.get(key); ok {
+		return r, true
+	}
+	r, ok := t.far.get(key)
+	if ok {
+		t.near.put(key, r)
+	}
+	return r, ok
+}
+
+func (t tieredCache) put(key string, r *result) {
+	t.near.put(key, r)
+	t.far.put(key, r)
+}
+
+// remoteCache stores pairs as objects under a base URL, with GET and PUT.
+// http(s):// URLs are used as-is, with the bearer token from $VENATUS_CACHE_TOKEN if set.
+// s3://bucket/prefix URLs are signed with the usual AWS_* environment variables, and
+// $AWS_ENDPOINT_URL can point them at any S3-compatible store.
+type remoteCache struct {
+	base   string
+	client *http.Client
+	// sign, if set, signs each request before it is sent.
+	sign func(req *http.Request, body []byte)
+	// Set once the remote can't be reached, so the rest of the run doesn't wait on it.
+	unreachable atomic.Bool
+}
+
+func openRemoteCache(remote string) (*remoteCache, error) {
+	u, err := url.Parse(remote)
+	if err != nil {
+		return nil, fmt.Errorf("--remote-cache: %w", err)
+	}
+	c := &remoteCache{client: &http.Client{Timeout: 10 * time.Second}}
+	switch u.Scheme {
+	case "http", "https":
+		c.base = strings.TrimSuffix(remote, "/")
+		if token := os.Getenv("VENATUS_CACHE_TOKEN"); token != "" {
+			c.sign = func(req *http.Request, body []byte) {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+		}
+	case "s3":
+		creds, err := s3CredentialsFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		endpoint := strings.TrimSuffix(os.Getenv("AWS_ENDPOINT_URL"), "/")
+		if endpoint == "" {
+			endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", creds.region)
+		}
+		// Path-style addressing works with every S3-compatible store.
+		c.base = endpoint + "/" + u.Host + strings.TrimSuffix(u.Path, "/")
+		c.sign = creds.sign
+	default:
+		return nil, fmt.Errorf("--remote-cache: unsupported scheme %q (want http, https, or s3)", u.Scheme)
+	}
+	return c, nil
+}
+
+func (c *remoteCache) do(method, key string, body []byte) (*http.Response, error) {
+	if c.unreachable.Load() {
+		return nil, errors.New("remote cache unreachable")
+	}
+	req, err := http.NewRequest(method, c.base+"/"+key, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if c.sign != nil {
+		c.sign(req, body)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil && c.unreachable.CompareAndSwap(false, true) {
+		slog.Warn("Remote cache unreachable, continuing without it", "err", err)
+	}
+	return resp, err
+}
+
+func (c *remoteCache) get(key string) (*result, bool) {
+	resp, err := c.do(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, false
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return nil, false
+	}
+	return decodeResult(data)
+}
+
+func (c *remoteCache) put(key string, r *result) {
+	resp, err := c.do(http.MethodPut, key, encodeResult(r))
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+}
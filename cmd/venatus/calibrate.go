@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var (
+	extensionWeightList   = flag.String("extension-weights", "", "comma-separated ext=factor pairs scaling how much files with each extension count in totals, e.g. \".h=0.5\" to count headers half as much (in a config file, this can be a list)")
+	extensionBaselineList = flag.String("extension-baselines", "", "comma-separated ext=score pairs giving the score that files with each extension get by chance, e.g. \".h=0.4\" for headers of look-alike prototypes; scores are rescaled so that this becomes 0")
+)
+
+// Per-extension calibration, from --extension-weights and --extension-baselines. Files without an
+// extension are keyed by "".
+var extensionWeights, extensionBaselines map[string]float64
+
+// parseExtensionValues parses a list of ext=value pairs, with values from 0 to max.
+func parseExtensionValues(name, list string, max float64) (map[string]float64, error) {
+	values := make(map[string]float64)
+	if list == "" {
+		return values, nil
+	}
+	for _, pair := range strings.Split(list, ",") {
+		ext, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("--%s: %q isn't ext=value", name, pair)
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil || v < 0 || v > max {
+			return nil, fmt.Errorf("--%s: value for %q must be a number from 0 to %v", name, ext, max)
+		}
+		if ext != "" && !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		values[ext] = v
+	}
+	return values, nil
+}
+
+func checkCalibration() error {
+	var err error
+	if extensionWeights, err = parseExtensionValues("extension-weights", *extensionWeightList, 1000); err != nil {
+		return err
+	}
+	if extensionBaselines, err = parseExtensionValues("extension-baselines", *extensionBaselineList, 1); err != nil {
+		return err
+	}
+	for ext, baseline := range extensionBaselines {
+		if baseline >= 1 {
+			return fmt.Errorf("--extension-baselines: baseline for %q must be less than 1", ext)
+		}
+	}
+	return nil
+}
+
+// extensionWeight is how much a file counts in totals relative to others, by its extension or
+// language.
+func extensionWeight(path string) float64 {
+	if w, ok := extensionWeights[filepath.Ext(path)]; ok {
+		return w
+	}
+	if lang := languages[filepath.Ext(path)]; lang != nil && lang.weight > 0 {
+		return lang.weight
+	}
+	return 1
+}
+
+// calibrate rescales a file's score so that its extension's baseline becomes 0 (and 1 stays 1).
+func calibrate(path string, score float64) float64 {
+	baseline, ok := extensionBaselines[filepath.Ext(path)]
+	if !ok || score <= 0 {
+		return score
+	}
+	return max(0, (score-baseline)/(1-baseline))
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var callPattern = regexp.MustCompile(`([A-Za-z_]\w*)\s*\(`)
+
+// Words that are followed by parentheses in C-like code without being calls.
+var callKeywords = map[string]bool{
+	"if": true, "for": true, "while": true, "switch": true, "return": true, "sizeof": true,
+	"_Alignof": true, "alignof": true, "typeof": true, "__typeof__": true, "defined": true,
+	"catch": true, "__attribute__": true, "__asm__": true, "asm": true,
+}
+
+// callEdges returns the sorted, distinct calls that the functions defined in normalized C-like
+// code make, as "caller -> callee". Unlike declaredSymbols, static functions count, since how a
+// file's internals call each other is exactly the architecture that survives a rewrite of their
+// bodies. Calls through function pointers and macros that look like calls are counted alike;
+// it's a rough parse, like the one declaredSymbols does.
+func callEdges(code string) []string {
+	seen := make(map[string]bool)
+	depth := 0
+	var statement, body strings.Builder
+	caller := ""
+	for _, line := range strings.Split(code, "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		inString := byte(0)
+		for i := 0; i < len(line); i++ {
+			c := line[i]
+			switch {
+			case inString != 0:
+				if c == '\\' {
+					i++
+				} else if c == inString {
+					inString = 0
+				}
+				continue
+			case c == '"' || c == '\'':
+				inString = c
+				continue
+			case c == '{':
+				if depth == 0 {
+					caller = definedFunction(statement.String())
+					statement.Reset()
+					body.Reset()
+				}
+				depth++
+				continue
+			case c == '}':
+				depth = max(0, depth-1)
+				if depth == 0 && caller != "" {
+					for _, m := range callPattern.FindAllStringSubmatch(body.String(), -1) {
+						if !callKeywords[m[1]] {
+							seen[caller+" -> "+m[1]] = true
+						}
+					}
+					caller = ""
+				}
+				continue
+			}
+			if depth > 0 {
+				body.WriteByte(c)
+			} else if c == ';' {
+				statement.Reset()
+			} else {
+				statement.WriteByte(c)
+			}
+		}
+		statement.WriteByte(' ')
+		body.WriteByte(' ')
+	}
+
+	edges := make([]string, 0, len(seen))
+	for edge := range seen {
+		edges = append(edges, edge)
+	}
+	sort.Strings(edges)
+	return edges
+}
+
+// definedFunction returns the name of the function that a top-level C statement (up to its {)
+// starts the definition of, static or not, or "" if it isn't a function.
+func definedFunction(decl string) string {
+	paren := strings.Index(decl, "(")
+	if paren < 0 {
+		return ""
+	}
+	names := identifierPattern.FindAllString(decl[:paren], -1)
+	if len(names) == 0 || callKeywords[names[len(names)-1]] {
+		return ""
+	}
+	return names[len(names)-1]
+}
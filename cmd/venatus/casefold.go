@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+)
+
+// caseCollisions returns the groups of files (relative to root) whose paths differ only by case.
+// Such files can't coexist on a case-insensitive filesystem, which matters when the trees were
+// authored on a case-sensitive one: each variant is compared on its own, but anything written
+// out per file (like patches) has to keep them apart.
+func caseCollisions(root string, files map[string]string) [][]string {
+	byFolded := make(map[string][]string)
+	for path := range files {
+		rel := relTo(root, path)
+		folded := strings.ToLower(rel)
+		byFolded[folded] = append(byFolded[folded], rel)
+	}
+	var collisions [][]string
+	for _, group := range byFolded {
+		if len(group) > 1 {
+			sort.Strings(group)
+			collisions = append(collisions, group)
+		}
+	}
+	sort.Slice(collisions, func(i, j int) bool {
+		return collisions[i][0] < collisions[j][0]
+	})
+	return collisions
+}
+
+// caseSuffixes returns a suffix to add to the name of each colliding path's output file, so
+// that no two of them differ only by case. The first path in each group needs no suffix.
+func caseSuffixes(collisions [][]string) map[string]string {
+	suffixes := make(map[string]string)
+	for _, group := range collisions {
+		for i, rel := range group[1:] {
+			suffixes[rel] = fmt.Sprintf("~%d", i+2)
+		}
+	}
+	return suffixes
+}
+
+func logCollisions(repo string, collisions [][]string) {
+	for _, group := range collisions {
+		slog.Warn("Paths differ only by case", "repo", repo, "paths", strings.Join(group, ", "))
+	}
+}
+
+// printCollisions reports paths in either repo that differ only by case.
+func printCollisions(c *comparison) {
+	for _, repo := range []struct {
+		name       string
+		collisions [][]string
+	}{
+		{"source", c.sourceCollisions},
+		{"target", c.targetCollisions},
+	} {
+		if len(repo.collisions) == 0 {
+			continue
+		}
+		fmt.Printf("\n\n%d sets of %s paths differ only by case (each was compared separately):\n", len(repo.collisions), repo.name)
+		for _, group := range repo.collisions {
+			fmt.Printf("  %s\n", strings.Join(group, ", "))
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var resume = flag.Bool("resume", false, "pick up an interrupted run where it left off, rather than comparing every file again (run the same command, plus this flag)")
+
+// How often results are saved during a run, so that it can be resumed if it's interrupted.
+// Runs shorter than this don't save anything.
+const checkpointInterval = 30 * time.Second
+
+// A checkpointEntry records the result for one target file.
+type checkpointEntry struct {
+	Path string
+	// Hash of the target file's normalized contents, so that files changed since aren't resumed.
+	Hash       string
+	Match      string
+	Score      float64
+	Confidence float64
+	TimedOut   bool        `json:",omitempty"`
+	Conflicts  []candidate `json:",omitempty"`
+	Overruled  *candidate  `json:",omitempty"`
+	// The best score against each source repo, with --versus.
+	BySource []float64 `json:",omitempty"`
+}
+
+func (c candidate) MarshalJSON() ([]byte, error) {
+	return json.Marshal([2]interface{}{c.filename, c.similarity})
+}
+
+func (c *candidate) UnmarshalJSON(data []byte) error {
+	var pair [2]interface{}
+	if err := json.Unmarshal(data, &pair); err != nil {
+		return err
+	}
+	filename, ok1 := pair[0].(string)
+	similarity, ok2 := pair[1].(float64)
+	if !ok1 || !ok2 {
+		return fmt.Errorf("bad candidate %s", data)
+	}
+	c.filename, c.similarity = filename, similarity
+	return nil
+}
+
+// A checkpoint saves the results of a run as they come in.
+type checkpoint struct {
+	path string
+	// Whether the checkpoint holds its path in checkpointsInUse.
+	claimed bool
+	// Results from an earlier run of the same comparison, with --resume, by target path.
+	done map[string]checkpointEntry
+
+	mu        sync.Mutex
+	pending   []checkpointEntry
+	lastFlush time.Time
+	file      *os.File
+}
+
+func contentHash(contents string) string {
+	h := sha256.Sum256([]byte(contents))
+	return hex.EncodeToString(h[:])
+}
+
+// The paths of the checkpoints of the comparisons running in this process. Under serve, jobs
+// comparing the same repos with the same flags can run at once; each after the first checkpoints
+// to a path of its own, so that they don't write over each other.
+var (
+	checkpointsMu    sync.Mutex
+	checkpointsInUse = make(map[string]bool)
+)
+
+// runKey identifies a comparison by its flags, the source files, and which target files there
+// are, so that a checkpoint is only resumed by the same command on the same repos. Target files
+// are hashed when they're resumed, so only their paths are needed here.
+func runKey(sourceFiles, targetFiles map[string]string) string {
+	h := sha256.New()
+	fmt.Fprint(h, "venatus checkpoint v1\x00")
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "resume", "timing", "cpuprofile", "memprofile":
+			return
+		}
+		fmt.Fprintf(h, "%s=%s\x00", f.Name, f.Value)
+	})
+	for _, path := range sortedKeys(sourceFiles) {
+		fmt.Fprintf(h, "%s\x00%s\x00", path, contentHash(sourceFiles[path]))
+	}
+	fmt.Fprint(h, "targets\x00")
+	for _, path := range sortedKeys(targetFiles) {
+		fmt.Fprintf(h, "%s\x00", path)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// openCheckpoint sets up checkpointing of a comparison, loading the results saved by an earlier
+// run of it with --resume. The checkpoint must be closed when the comparison is done with it.
+func openCheckpoint(sourceFiles, targetFiles map[string]string) (*checkpoint, error) {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	cp := &checkpoint{
+		done:      make(map[string]checkpointEntry),
+		lastFlush: time.Now(),
+	}
+	cp.claim(filepath.Join(cache, "venatus", "checkpoints"), runKey(sourceFiles, targetFiles))
+	if !*resume {
+		return cp, nil
+	}
+	f, err := os.Open(cp.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		slog.Info("Nothing to resume; starting from the beginning")
+		return cp, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for {
+		var entry checkpointEntry
+		if err := dec.Decode(&entry); err == io.EOF {
+			break
+		} else if err != nil {
+			// The run may have been killed partway through writing; keep what was whole.
+			slog.Warn("Couldn't read all of checkpoint", "path", cp.path, "err", err)
+			break
+		}
+		cp.done[entry.Path] = entry
+	}
+	slog.Info("Resuming", "done", len(cp.done), "checkpoint", cp.path)
+	return cp, nil
+}
+
+// resumed returns the result saved for a target file, if it hasn't changed since.
+func (cp *checkpoint) resumed(path, contents string) (*findResult, bool) {
+	entry, ok := cp.done[path]
+	if !ok || entry.Hash != contentHash(contents) {
+		return nil, false
+	}
+	return &findResult{
+		filename:        path,
+		matchedFilename: entry.Match,
+		matchSimilarity: entry.Score,
+		confidence:      entry.Confidence,
+		timedOut:        entry.TimedOut,
+		conflicts:       entry.Conflicts,
+		overruled:       entry.Overruled,
+		bySource:        entry.BySource,
+		lineCount:       strings.Count(contents, "\n"),
+		tokenCount:      tokenCount(contents),
+		complexity:      complexity(contents),
+		byteCount:       len(contents),
+	}, true
+}
+
+// save records a result, writing out what has been recorded if it's been a while.
+func (cp *checkpoint) save(result *findResult, contents string) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.pending = append(cp.pending, checkpointEntry{
+		Path:       result.filename,
+		Hash:       contentHash(contents),
+		Match:      result.matchedFilename,
+		Score:      result.matchSimilarity,
+		Confidence: result.confidence,
+		TimedOut:   result.timedOut,
+		Conflicts:  result.conflicts,
+		Overruled:  result.overruled,
+		BySource:   result.bySource,
+	})
+	if time.Since(cp.lastFlush) < checkpointInterval {
+		return
+	}
+	if err := cp.flush(); err != nil {
+		slog.Warn("Couldn't save checkpoint", "path", cp.path, "err", err)
+	}
+	cp.lastFlush = time.Now()
+}
+
+// flush appends the pending results to the checkpoint file, which includes the ones resumed, so
+// that a run can be interrupted and resumed more than once.
+func (cp *checkpoint) flush() error {
+	if cp.file == nil {
+		if err := os.MkdirAll(filepath.Dir(cp.path), 0755); err != nil {
+			return err
+		}
+		f, err := os.Create(cp.path)
+		if err != nil {
+			return err
+		}
+		cp.file = f
+		for _, path := range sortedKeys(cp.done) {
+			cp.pending = append(cp.pending, cp.done[path])
+		}
+	}
+	w := bufio.NewWriter(cp.file)
+	enc := json.NewEncoder(w)
+	for _, entry := range cp.pending {
+		if err := enc.Encode(entry); err != nil {
+			return err
+		}
+	}
+	cp.pending = nil
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return cp.file.Sync()
+}
+
+// claim picks the path of the checkpoint, in dir, by the run's key: the first one no other
+// comparison in the process is using. Any of them may be resumed, since they are all of the
+// same run.
+func (cp *checkpoint) claim(dir, key string) {
+	checkpointsMu.Lock()
+	defer checkpointsMu.Unlock()
+	cp.path = filepath.Join(dir, key+".jsonl")
+	for n := 2; checkpointsInUse[cp.path]; n++ {
+		cp.path = filepath.Join(dir, fmt.Sprintf("%s-%d.jsonl", key, n))
+	}
+	checkpointsInUse[cp.path] = true
+	cp.claimed = true
+}
+
+// close closes the checkpoint, keeping what it has saved to be resumed, and gives up its path.
+func (cp *checkpoint) close() {
+	if cp.file != nil {
+		cp.file.Close()
+		cp.file = nil
+	}
+	checkpointsMu.Lock()
+	defer checkpointsMu.Unlock()
+	if cp.claimed {
+		delete(checkpointsInUse, cp.path)
+		cp.claimed = false
+	}
+}
+
+// finish removes the checkpoint of a run that has completed, and closes it.
+func (cp *checkpoint) finish() {
+	if cp.file != nil {
+		cp.file.Close()
+		cp.file = nil
+	}
+	if err := os.Remove(cp.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		slog.Warn("Couldn't remove checkpoint", "path", cp.path, "err", err)
+	}
+	cp.close()
+}
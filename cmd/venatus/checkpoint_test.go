@@ -0,0 +1,97 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	defer func(old bool) { *resume = old }(*resume)
+
+	sourceFiles := map[string]string{"/src/a.c": "int a;\n"}
+	targetFiles := map[string]string{"/tgt/a.c": ""}
+	tests := []struct {
+		name   string
+		result *findResult
+	}{
+		{"plain", &findResult{filename: "/tgt/a.c", matchedFilename: "/src/a.c", matchSimilarity: 0.75, confidence: 0.5}},
+		{"timed out, with conflicts", &findResult{filename: "/tgt/a.c", matchedFilename: "/src/a.c", matchSimilarity: 0.5, confidence: 0.25, timedOut: true,
+			conflicts: []candidate{{"/src/b.c", 0.49}}}},
+		{"versus", &findResult{filename: "/tgt/a.c", matchedFilename: "/src/a.c", matchSimilarity: 1, confidence: 1, bySource: []float64{1, 0.5}}},
+		{"new file", &findResult{filename: "/tgt/a.c", matchedFilename: "N/A"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const contents = "int a;\nint b;\n"
+			*resume = false
+			cp, err := openCheckpoint(sourceFiles, targetFiles)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cp.save(tt.result, contents)
+			if err := cp.flush(); err != nil {
+				t.Fatal(err)
+			}
+			cp.close()
+
+			*resume = true
+			cp, err = openCheckpoint(sourceFiles, targetFiles)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cp.finish()
+			if _, ok := cp.resumed(tt.result.filename, "int a;\nchanged\n"); ok {
+				t.Errorf("resumed a file that changed since")
+			}
+			got, ok := cp.resumed(tt.result.filename, contents)
+			if !ok {
+				t.Fatalf("resumed(%q) found nothing", tt.result.filename)
+			}
+			want := *tt.result
+			want.lineCount, want.tokenCount, want.complexity, want.byteCount = 2, 6, 1, len(contents)
+			if !reflect.DeepEqual(*got, want) {
+				t.Errorf("resumed(%q) = %+v, want %+v", tt.result.filename, *got, want)
+			}
+		})
+	}
+}
+
+func TestCheckpointPaths(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	sourceFiles := map[string]string{"/upstream/lib/util.c": "int add(int a, int b);\n"}
+	open := func(targets ...string) *checkpoint {
+		targetFiles := make(map[string]string)
+		for _, path := range targets {
+			targetFiles[path] = ""
+		}
+		cp, err := openCheckpoint(sourceFiles, targetFiles)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cp
+	}
+
+	whole := open("/fork/lib/util.c", "/fork/lib/new.c")
+	defer whole.close()
+	if part := open("/fork/lib/util.c"); part.path == whole.path {
+		t.Errorf("runs over different target files share checkpoint %s", part.path)
+	} else {
+		part.close()
+	}
+
+	// As jobs under serve may be, at once.
+	again := open("/fork/lib/util.c", "/fork/lib/new.c")
+	if again.path == whole.path {
+		t.Errorf("runs at once share checkpoint %s", again.path)
+	}
+	again.close()
+	whole.close()
+	if after := open("/fork/lib/new.c", "/fork/lib/util.c"); after.path != whole.path {
+		t.Errorf("run after the first checkpoints to %s, want %s to resume it", after.path, whole.path)
+	} else {
+		after.close()
+	}
+}
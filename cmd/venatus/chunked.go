@@ -0,0 +1,133 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// chunkedDiff scores a pair of large files without handing either one to DiffMain whole, or
+// holding more than a few --chunk-size sections of either in memory. The files are read a chunk
+// at a time and aligned line by line, which only costs a rune per line; then only the sections
+// that changed are diffed character by character, at most --chunk-size bytes at a time. A changed
+// section at the end of a chunk is carried over to be aligned with the next one, unless it is
+// itself bigger than a chunk. The sum of the sections' edit distances is an upper bound on (and
+// in practice very close to) the edit distance of the whole files.
+func chunkedDiff(differ *diffmatchpatch.DiffMatchPatch, r1, r2 io.Reader) (*result, error) {
+	file1, file2 := &chunkReader{r: r1}, &chunkReader{r: r2}
+	levenshtein := 0
+	timedOut := false
+	var deleted, inserted strings.Builder
+	flush := func() {
+		l, t := sectionLevenshtein(differ, deleted.String(), inserted.String())
+		levenshtein += l
+		timedOut = timedOut || t
+		deleted.Reset()
+		inserted.Reset()
+	}
+	for !file1.done() || !file2.done() {
+		chunk1, err := file1.next(*chunkSize)
+		if err != nil {
+			return nil, err
+		}
+		chunk2, err := file2.next(*chunkSize)
+		if err != nil {
+			return nil, err
+		}
+		// Align what was carried over from the last chunk along with this one.
+		chunk1, chunk2 = deleted.String()+chunk1, inserted.String()+chunk2
+		deleted.Reset()
+		inserted.Reset()
+		for _, line := range lineDiff(chunk1, chunk2) {
+			switch line.op {
+			case diffEqual:
+				flush()
+			case diffDelete:
+				deleted.WriteString(line.text)
+			case diffInsert:
+				inserted.WriteString(line.text)
+			}
+		}
+		if deleted.Len()+inserted.Len() > *chunkSize {
+			flush()
+		}
+	}
+	flush()
+	return &result{
+		levenshtein: levenshtein,
+		length:      max(file1.read, file2.read),
+		timedOut:    timedOut,
+	}, nil
+}
+
+// A chunkReader reads a file a chunk of lines at a time.
+type chunkReader struct {
+	r io.Reader
+	// What has been read but not yet returned.
+	rest string
+	eof  bool
+	// How many bytes have been read in all.
+	read int
+}
+
+// next returns the next chunk of at most n bytes, cut as cutChunk cuts, or "" at the end.
+func (cr *chunkReader) next(n int) (string, error) {
+	// Read a byte more than a chunk, so that cutChunk can tell whether a line ends at its edge.
+	if want := n + 1 - len(cr.rest); !cr.eof && want > 0 {
+		buf := make([]byte, want)
+		got, err := io.ReadFull(cr.r, buf)
+		cr.read += got
+		cr.rest += string(buf[:got])
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			cr.eof = true
+		} else if err != nil {
+			return "", err
+		}
+	}
+	var head string
+	head, cr.rest = cutChunk(cr.rest, n)
+	return head, nil
+}
+
+// done returns whether the whole file has been returned.
+func (cr *chunkReader) done() bool {
+	return cr.eof && cr.rest == ""
+}
+
+// sectionLevenshtein returns the edit distance between two changed sections, diffing them a
+// chunk at a time if they are too large to do in one go, and whether any chunk ran out of time.
+func sectionLevenshtein(differ *diffmatchpatch.DiffMatchPatch, a, b string) (int, bool) {
+	levenshtein := 0
+	timedOut := false
+	for a != "" && b != "" {
+		var headA, headB string
+		headA, a = cutChunk(a, *chunkSize)
+		headB, b = cutChunk(b, *chunkSize)
+		l, t := editDistanceWith(differ, headA, headB)
+		levenshtein += l
+		timedOut = timedOut || t
+	}
+	// Whatever is left over on one side has nothing to match against.
+	return levenshtein + utf8.RuneCountInString(a) + utf8.RuneCountInString(b), timedOut
+}
+
+// cutChunk splits s after the last line ending within its first n bytes (or at n bytes, if
+// there is no line ending there).
+func cutChunk(s string, n int) (head, tail string) {
+	if len(s) <= n {
+		return s, ""
+	}
+	if i := strings.LastIndexByte(s[:n], '\n'); i >= 0 {
+		n = i + 1
+	}
+	// Don't split a multi-byte character.
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	if n == 0 {
+		n = len(s)
+	}
+	return s[:n], s[n:]
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+func TestCutChunk(t *testing.T) {
+	tests := []struct {
+		name       string
+		s          string
+		n          int
+		head, tail string
+	}{
+		{"shorter than a chunk", "abc\n", 10, "abc\n", ""},
+		{"exactly a chunk", "abc\n", 4, "abc\n", ""},
+		{"after the last line ending", "ab\ncd\nef\n", 7, "ab\ncd\n", "ef\n"},
+		{"line ending at the edge", "ab\ncd\nef\n", 6, "ab\ncd\n", "ef\n"},
+		{"no line ending", "abcdef", 4, "abcd", "ef"},
+		{"not inside a character", "aé", 2, "a", "é"},
+		{"not inside a character after a line", "a\nxé", 4, "a\n", "xé"},
+		{"a character longer than a chunk", "éa", 1, "éa", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			head, tail := cutChunk(tt.s, tt.n)
+			if head != tt.head || tail != tt.tail {
+				t.Errorf("cutChunk(%q, %d) = %q, %q; want %q, %q", tt.s, tt.n, head, tail, tt.head, tt.tail)
+			}
+		})
+	}
+}
+
+func TestChunkedDiff(t *testing.T) {
+	defer func(old int) { *chunkSize = old }(*chunkSize)
+	*chunkSize = 8
+	differ := diffmatchpatch.New()
+	differ.DiffTimeout = 0
+
+	lines := func(n int, line string) string { return strings.Repeat(line, n) }
+	tests := []struct {
+		name        string
+		a, b        string
+		levenshtein int
+	}{
+		{"same", lines(10, "line\n"), lines(10, "line\n"), 0},
+		{"one line changed", "a\nb\nc\n", "a\nB\nc\n", 1},
+		{"one line added", "a\nb\nc\n", "a\nb\nx\nc\n", 2},
+		{"one line removed", "a\nb\nc\n", "a\nc\n", 2},
+		{"changed section bigger than a chunk", "keep\n" + lines(4, "old1\n") + "keep\n", "keep\n" + lines(4, "new1\n") + "keep\n", 12},
+		{"left over on one side", "x\n" + lines(3, "abcdefg\n"), "x\n" + "abcdefg\n", 16},
+		{"everything changed", "", "abc\n", 4},
+		{"one line added, putting the chunks out of step", lines(10, "line\n"), "x\n" + lines(10, "line\n"), 2},
+		{"one line changed in every chunk", lines(6, "ab\ncd\n"), lines(6, "ab\ncD\n"), 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := chunkedDiff(differ, strings.NewReader(tt.a), strings.NewReader(tt.b))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.levenshtein != tt.levenshtein || got.length != max(len(tt.a), len(tt.b)) {
+				t.Errorf("chunkedDiff(%q, %q) = %+v; want levenshtein %d, length %d", tt.a, tt.b, *got, tt.levenshtein, max(len(tt.a), len(tt.b)))
+			}
+		})
+	}
+}
+
+func TestChunkedDiffReadError(t *testing.T) {
+	boom := errors.New("boom")
+	if _, err := chunkedDiff(diffmatchpatch.New(), strings.NewReader("abc\n"), iotest.ErrReader(boom)); !errors.Is(err, boom) {
+		t.Errorf("chunkedDiff() error = %v, want %v", err, boom)
+	}
+}
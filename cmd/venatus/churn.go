@@ -0,0 +1,66 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// churn counts the lines of normalized code a target file has added, removed, and modified
+// relative to its match. A run of removed lines directly followed by a run of added ones (or the
+// other way around) is a modification of as many lines as the shorter run; the rest of such runs
+// are additions or removals.
+type churn struct {
+	added, removed, modified int
+}
+
+var (
+	churnsMu sync.Mutex
+	churns   = make(map[*findResult]*churn)
+)
+
+// churn works out a result's churn, for the columns that show it, or nil if it has no match.
+func (c *comparison) churn(result *findResult) *churn {
+	churnsMu.Lock()
+	defer churnsMu.Unlock()
+	if ch, ok := churns[result]; ok {
+		return ch
+	}
+	source, ok := c.sourceContents(result.matchedFilename)
+	if !ok {
+		churns[result] = nil
+		return nil
+	}
+	ch := &churn{}
+	var inserted, deleted int
+	flush := func() {
+		modified := min(inserted, deleted)
+		ch.modified += modified
+		ch.added += inserted - modified
+		ch.removed += deleted - modified
+		inserted, deleted = 0, 0
+	}
+	for _, line := range lineDiff(source, c.targetContents(result.filename)) {
+		switch line.op {
+		case diffmatchpatch.DiffEqual:
+			flush()
+		case diffmatchpatch.DiffInsert:
+			inserted++
+		case diffmatchpatch.DiffDelete:
+			deleted++
+		}
+	}
+	flush()
+	churns[result] = ch
+	return ch
+}
+
+// churnCell is a column cell showing part of a result's churn.
+func churnCell(part func(ch *churn) int) func(c *comparison, result *findResult) interface{} {
+	return func(c *comparison, result *findResult) interface{} {
+		if ch := c.churn(result); ch != nil {
+			return part(ch)
+		}
+		return ""
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+var (
+	noColor     = flag.Bool("no-color", false, "don't color the results table, e.g. for logs")
+	colorScores = flag.String("colors", "0.9:green,0.8:hi-green,0.6:hi-yellow", "comma-separated score:color pairs; rows scoring more than a score get its color (in a config file, this can be a list)")
+	palette     = flag.String("palette", "default", "how to show the colors of --colors: default, or colorblind (which shows green as blue and red as magenta, so that red-green colorblind readers can tell scores apart)")
+)
+
+var colorNames = map[string]text.Color{
+	"black": text.FgBlack, "red": text.FgRed, "green": text.FgGreen, "yellow": text.FgYellow,
+	"blue": text.FgBlue, "magenta": text.FgMagenta, "cyan": text.FgCyan, "white": text.FgWhite,
+	"hi-black": text.FgHiBlack, "hi-red": text.FgHiRed, "hi-green": text.FgHiGreen, "hi-yellow": text.FgHiYellow,
+	"hi-blue": text.FgHiBlue, "hi-magenta": text.FgHiMagenta, "hi-cyan": text.FgHiCyan, "hi-white": text.FgHiWhite,
+}
+
+// The colors each palette shows in place of those named in --colors.
+var palettes = map[string]map[text.Color]text.Color{
+	"default": {},
+	"colorblind": {
+		text.FgGreen: text.FgBlue, text.FgHiGreen: text.FgHiCyan,
+		text.FgRed: text.FgMagenta, text.FgHiRed: text.FgHiMagenta,
+	},
+}
+
+type colorBand struct {
+	score float64
+	color text.Color
+}
+
+// The bands of --colors, highest score first.
+var colorBands []colorBand
+
+func checkColors() error {
+	shown, ok := palettes[*palette]
+	if !ok {
+		return fmt.Errorf("unknown --palette %q (want default or colorblind)", *palette)
+	}
+	colorBands = nil
+	for _, pair := range strings.Split(*colorScores, ",") {
+		score, name, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok {
+			return fmt.Errorf("--colors: %q isn't score:color", pair)
+		}
+		s, err := strconv.ParseFloat(score, 64)
+		if err != nil || s < 0 || s > 1 {
+			return fmt.Errorf("--colors: score %q must be between 0 and 1", score)
+		}
+		color, ok := colorNames[name]
+		if !ok {
+			return fmt.Errorf("--colors: unknown color %q", name)
+		}
+		if c, ok := shown[color]; ok {
+			color = c
+		}
+		colorBands = append(colorBands, colorBand{s, color})
+	}
+	sort.Slice(colorBands, func(i, j int) bool {
+		return colorBands[i].score > colorBands[j].score
+	})
+	return nil
+}
+
+// scoreColors returns the colors for a row of the results table with the given score.
+func scoreColors(pct percentage) text.Colors {
+	for _, band := range colorBands {
+		if float64(pct) > band.score {
+			return text.Colors{band.color}
+		}
+	}
+	return text.Colors{text.FgWhite}
+}
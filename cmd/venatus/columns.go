@@ -0,0 +1,184 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+var columnList = flag.String("columns", "path,match,license,score,confidence,loc", "comma-separated columns of the results table, from: "+strings.Join(columnNames(), ", "))
+
+// A column of the results table.
+type column struct {
+	name   string
+	header string
+	// Whether the column holds numbers, and so is right-aligned.
+	numeric bool
+	cell    func(c *comparison, result *findResult) interface{}
+}
+
+var allColumns = []*column{
+	{name: "path", header: "Path", cell: func(c *comparison, result *findResult) interface{} {
+		return c.relTarget(result.filename) + c.tag(result.filename)
+	}},
+	{name: "match", header: "Best match", cell: func(c *comparison, result *findResult) interface{} {
+		return c.sourceLabel(result.matchedFilename)
+	}},
+	{name: "license", header: "License", cell: func(c *comparison, result *findResult) interface{} {
+		return result.license
+	}},
+	{name: "score", header: "Score", numeric: true, cell: func(c *comparison, result *findResult) interface{} {
+		return percentage(result.matchSimilarity)
+	}},
+	{name: "confidence", header: "Confidence", numeric: true, cell: func(c *comparison, result *findResult) interface{} {
+		return percentage(result.confidence)
+	}},
+	{name: "loc", header: "LoC", numeric: true, cell: func(c *comparison, result *findResult) interface{} {
+		return result.lineCount
+	}},
+	{name: "match-loc", header: "Match LoC", numeric: true, cell: func(c *comparison, result *findResult) interface{} {
+		if contents, ok := c.sourceContents(result.matchedFilename); ok {
+			return strings.Count(contents, "\n")
+		}
+		return ""
+	}},
+	{name: "tokens", header: "Tokens", numeric: true, cell: func(c *comparison, result *findResult) interface{} {
+		return result.tokenCount
+	}},
+	{name: "bytes", header: "Bytes", numeric: true, cell: func(c *comparison, result *findResult) interface{} {
+		return result.byteCount
+	}},
+	{name: "complexity", header: "Complexity", numeric: true, cell: func(c *comparison, result *findResult) interface{} {
+		return result.complexity
+	}},
+	{name: "equal", header: "Equal chars", numeric: true, cell: breakdownCell(func(b *breakdown) int { return b.equal })},
+	{name: "inserted", header: "Inserted chars", numeric: true, cell: breakdownCell(func(b *breakdown) int { return b.inserted })},
+	{name: "deleted", header: "Deleted chars", numeric: true, cell: breakdownCell(func(b *breakdown) int { return b.deleted })},
+	{name: "longest-equal", header: "Longest equal run", numeric: true, cell: breakdownCell(func(b *breakdown) int { return b.longestEqual })},
+	{name: "added-lines", header: "Added lines", numeric: true, cell: churnCell(func(ch *churn) int { return ch.added })},
+	{name: "removed-lines", header: "Removed lines", numeric: true, cell: churnCell(func(ch *churn) int { return ch.removed })},
+	{name: "modified-lines", header: "Modified lines", numeric: true, cell: churnCell(func(ch *churn) int { return ch.modified })},
+	{name: "shared-lines", header: "Shared lines", numeric: true, cell: func(c *comparison, result *findResult) interface{} {
+		if source, ok := c.sourceContents(result.matchedFilename); ok {
+			shared, _, _ := sharedLines(c.targetContents(result.filename), source)
+			return shared
+		}
+		return ""
+	}},
+	{name: "containment", header: "Match contained", numeric: true, cell: func(c *comparison, result *findResult) interface{} {
+		if source, ok := c.sourceContents(result.matchedFilename); ok {
+			return percentage(containment(source, c.targetContents(result.filename)))
+		}
+		return ""
+	}},
+	{name: "commit", header: "Last commit", cell: func(c *comparison, result *findResult) interface{} {
+		return lastCommit(result.filename).hash
+	}},
+	{name: "date", header: "Commit date", cell: func(c *comparison, result *findResult) interface{} {
+		return lastCommit(result.filename).date
+	}},
+	{name: "match-commit", header: "Match's last commit", cell: func(c *comparison, result *findResult) interface{} {
+		return lastCommit(result.matchedFilename).hash
+	}},
+	{name: "match-date", header: "Match's commit date", cell: func(c *comparison, result *findResult) interface{} {
+		return lastCommit(result.matchedFilename).date
+	}},
+	{name: "algorithm", header: "Algorithm", cell: func(c *comparison, result *findResult) interface{} {
+		return result.algorithm()
+	}},
+}
+
+// The columns chosen with --columns, in order.
+var tableColumns []*column
+
+func columnNames() []string {
+	names := make([]string, len(allColumns))
+	for i, col := range allColumns {
+		names[i] = col.name
+	}
+	return names
+}
+
+func checkColumns() error {
+	tableColumns = nil
+	for _, name := range strings.Split(*columnList, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, col := range allColumns {
+			if col.name == name {
+				tableColumns = append(tableColumns, col)
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown --columns %q (want some of %s)", name, strings.Join(columnNames(), ", "))
+		}
+	}
+	return nil
+}
+
+// algorithm describes how a result's score was worked out.
+func (r *findResult) algorithm() string {
+	if r.matchSimilarity <= 0 {
+		return ""
+	}
+	if !comparesCode() {
+		return *compareMode + " overlap"
+	}
+	if len(comparatorArgs) > 0 {
+		return comparatorArgs[0]
+	}
+	if r.approximate {
+		return "fingerprint (over budget)"
+	}
+	if r.timedOut {
+		return *algorithmName + " (timed out)"
+	}
+	return *algorithmName
+}
+
+// columnRow makes a table row of the chosen columns from cells keyed by column name, leaving
+// the rest blank.
+func columnRow(cells map[string]interface{}) table.Row {
+	row := make(table.Row, len(tableColumns))
+	for i, col := range tableColumns {
+		if cell, ok := cells[col.name]; ok {
+			row[i] = cell
+		} else {
+			row[i] = ""
+		}
+	}
+	return row
+}
+
+// resultRow makes a table row of the chosen columns for a result.
+func resultRow(c *comparison, result *findResult) table.Row {
+	row := make(table.Row, len(tableColumns))
+	for i, col := range tableColumns {
+		row[i] = col.cell(c, result)
+	}
+	return row
+}
+
+func columnConfigs() []table.ColumnConfig {
+	var configs []table.ColumnConfig
+	for i, col := range tableColumns {
+		if col.numeric {
+			configs = append(configs, table.ColumnConfig{Number: i + 1, Align: text.AlignRight, AlignFooter: text.AlignRight})
+		}
+	}
+	return configs
+}
+
+// columnIndex returns where a column is among the chosen columns, or -1 if it wasn't chosen.
+func columnIndex(name string) int {
+	for i, col := range tableColumns {
+		if col.name == name {
+			return i
+		}
+	}
+	return -1
+}
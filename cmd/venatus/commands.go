@@ -0,0 +1,383 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+// A command is one of venatus's subcommands. Each has its own flags: those only it uses, and
+// whichever of the global flags apply to it. Those that change how code is read, normalized and
+// scored are shared by every command that compares code.
+type command struct {
+	name  string
+	usage string
+	// What the command does, in a line.
+	summary string
+	// run is given the arguments left over once the command's flags are parsed.
+	run func(args []string) error
+	// Command lines showing how it's used, for help.
+	examples []string
+
+	// The command's own flags, to which flagSet adds the global flags it takes: if it compares
+	// code, the shared ones and those of the groups in globals.
+	flags        *flag.FlagSet
+	comparesCode bool
+	globals      [][]string
+	once         sync.Once
+}
+
+var commands []*command
+
+// The global flags, as defined; flag.CommandLine is the running command's flags once they're parsed.
+var globalFlags = flag.CommandLine
+
+// Global flags that only some commands take. The rest are taken by every command that compares code.
+var (
+	// The repos to compare.
+	repoFlagNames = []string{"source", "target", "self"}
+	// How a report is printed, for the commands that print one.
+	displayFlagNames = []string{
+		"format", "live", "template", "sort", "reverse", "top", "plain", "columns", "no-color", "colors",
+		"palette", "histogram", "rollup-depth", "github-annotations", "warning-below", "error-below",
+	}
+	// What compare writes once it has compared, besides its report.
+	writeFlagNames = []string{
+		"write-index", "dump-normalized", "alignments", "redact", "badge", "dot", "xlsx", "junit",
+		"junit-fail-below", "emit-patches", "merge-upstream", "merge-dir", "show-diff", "summary-json",
+		"notify-webhook", "notify-worst", "baseline", "dry-run", "cpuprofile", "memprofile", "timing",
+	}
+)
+
+// flagSet returns the command's flags. The global flags it takes are added the first time, once
+// every file has defined its flags; they share their values with the global flags, so that code
+// reading them needn't know which command is running.
+func (cmd *command) flagSet() *flag.FlagSet {
+	cmd.once.Do(func() {
+		if cmd.flags == nil {
+			cmd.flags = flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+		}
+		if !cmd.comparesCode {
+			return
+		}
+		grouped, take := make(map[string]bool), make(map[string]bool)
+		for _, group := range [][]string{repoFlagNames, displayFlagNames, writeFlagNames} {
+			for _, name := range group {
+				grouped[name] = true
+			}
+		}
+		for _, group := range cmd.globals {
+			for _, name := range group {
+				take[name] = true
+			}
+		}
+		globalFlags.VisitAll(func(f *flag.Flag) {
+			if take[f.Name] || !grouped[f.Name] {
+				cmd.flags.Var(f.Value, f.Name, f.Usage)
+			}
+		})
+	})
+	return cmd.flags
+}
+
+func init() {
+	// Set here rather than in the declaration, since help refers back to commands.
+	commands = []*command{
+		{
+			name: "compare", usage: "compare --source REPO --target REPO [flags]", summary: "compare a target repo against source repos (the default command)",
+			run: compareCommand, comparesCode: true, globals: [][]string{repoFlagNames, displayFlagNames, writeFlagNames},
+			examples: []string{"venatus --source upstream --target fork", "venatus compare --source upstream --target fork --min-score 0.5 --max-score 0.95 --sort score", "venatus compare --source v1,v2 --versus --target fork --format jsonl"},
+		},
+		{
+			name: "diff", usage: "diff [flags] SOURCE_FILE TARGET_FILE | diff --source REPO --target REPO [flags] PATH", summary: "diff two files, or a target file against its best match",
+			run: diffCommand, comparesCode: true, globals: [][]string{repoFlagNames},
+			examples: []string{"venatus diff upstream/lib/zip.c fork/lib/zip.c", "venatus diff --source upstream --target fork lib/zip.c"},
+		},
+		{
+			name: "index", usage: "index --source REPO -o FILE [flags]", summary: "build an index of a source repo, to compare against later",
+			run: indexCommand, flags: indexFlags, comparesCode: true, globals: [][]string{{"source"}},
+			examples: []string{"venatus index --source upstream -o upstream.idx"},
+		},
+		{
+			name: "query", usage: "query --index FILE [flags] TARGET...", summary: "compare targets against an index",
+			run: query, flags: queryFlags, comparesCode: true, globals: [][]string{{"target"}, displayFlagNames},
+			examples: []string{"venatus query --index upstream.idx --target vendor-drop", "venatus query --index upstream.idx drops/*"},
+		},
+		{
+			name: "serve", usage: "serve [flags]", summary: "run comparisons from a web dashboard and JSON API",
+			run: serve, flags: serveFlags, comparesCode: true, globals: [][]string{repoFlagNames},
+			examples: []string{"venatus serve --port 8080", "venatus serve --tokens tokens.txt --rate-limit 60 --audit-log audit.jsonl"},
+		},
+		{
+			name: "report", usage: "report [--worst N] SUMMARY_JSON", summary: "print a --summary-json file from an earlier run",
+			run: reportCommand, flags: reportFlags,
+			examples: []string{"venatus report --worst 20 summary.json"},
+		},
+		{
+			name: "history", usage: "history [--by total|dir|file] [--path PATH] [--html FILE] SUMMARY_JSON...", summary: "chart scores over --summary-json files from a series of runs",
+			run: trend, flags: historyFlags,
+			examples: []string{"venatus history --by dir --html history.html runs/*.json"},
+		},
+		{
+			name: "pair", usage: "pair [flags] SOURCE_FILE TARGET_FILE", summary: "score and diff two files",
+			run: pair, comparesCode: true,
+			examples: []string{"venatus pair upstream/lib/zip.c fork/lib/zip.c"},
+		},
+		{
+			name: "matrix", usage: "matrix [flags] REPO REPO...", summary: "compare every pair of repos, to see which of a set of forks are derived from which",
+			run: matrix, flags: matrixFlags, comparesCode: true,
+			examples: []string{"venatus matrix vendor-a vendor-b vendor-c upstream"},
+		},
+		{
+			name: "fingerprint", usage: "fingerprint --db DB --project NAME --release VERSION [flags] DIR", summary: "add a release of a project to a fingerprint database",
+			run: fingerprintRelease, flags: fingerprintFlags, comparesCode: true,
+			examples: []string{"venatus fingerprint --db releases.db --project zlib --release 1.3.1 zlib-1.3.1"},
+		},
+		{
+			name: "identify", usage: "identify --db DB [flags] DIR", summary: "find which releases in a fingerprint database a tree contains",
+			run: identify, flags: identifyFlags, comparesCode: true,
+			examples: []string{"venatus identify --db releases.db fork"},
+		},
+		{
+			name: "sign", usage: "sign --key KEY [--signature FILE] REPORT", summary: "sign a report",
+			run: sign, flags: signFlags,
+			examples: []string{"venatus sign --key key.pem report.json"},
+		},
+		{
+			name: "verify", usage: "verify --public-key KEY [--signature FILE] REPORT", summary: "verify a signed report",
+			run: verify, flags: verifyFlags,
+			examples: []string{"venatus verify --public-key key.pub report.json"},
+		},
+		{
+			name: "completion", usage: "completion bash|zsh|fish", summary: "print a script completing commands, flags, and their values for a shell",
+			run:      completion,
+			examples: []string{"source <(venatus completion bash)", "venatus completion fish > ~/.config/fish/completions/venatus.fish"},
+		},
+		{
+			name: "help", usage: "help [COMMAND]", summary: "describe the commands, or one command and its flags",
+			run:      help,
+			examples: []string{"venatus help compare"},
+		},
+	}
+}
+
+// Other names commands have gone by, so that scripts using them keep working.
+var commandAliases = map[string]string{"trend": "history"}
+
+func lookupCommand(name string) *command {
+	if alias, ok := commandAliases[name]; ok {
+		name = alias
+	}
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// isFlag reports whether any command has a flag of the given name.
+func isFlag(name string) bool {
+	for _, cmd := range commands {
+		if cmd.flagSet().Lookup(name) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFlags parses a command's flags, and makes them flag.CommandLine, so that the config file,
+// checkpoints, and run metadata see the flags given to this command. Flags of other commands are
+// rejected by name, rather than being quietly ignored.
+func parseFlags(cmd *command, args []string) error {
+	fs := cmd.flagSet()
+	// Parse quietly, so as to say what went wrong in our own words.
+	fs.SetOutput(io.Discard)
+	err := fs.Parse(args)
+	fs.SetOutput(nil)
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		if cmd.name == "compare" {
+			usage()
+		} else {
+			describe(cmd)
+		}
+		return err
+	case err != nil:
+		if name, ok := strings.CutPrefix(err.Error(), "flag provided but not defined: -"); ok && isFlag(name) {
+			return fmt.Errorf("--%s doesn't apply to venatus %s; run \"venatus help %s\" for its flags", name, cmd.name, cmd.name)
+		}
+		return fmt.Errorf("%w; run \"venatus help %s\" for its flags", err, cmd.name)
+	}
+	flag.CommandLine = fs
+	return nil
+}
+
+func listCommands(w io.Writer) {
+	fmt.Fprintf(w, "Usage: venatus COMMAND [flags]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-12s %s\n", cmd.name, cmd.summary)
+	}
+}
+
+// usage describes the commands and compare's flags, for -h.
+func usage() {
+	cmd := lookupCommand("compare")
+	fs := cmd.flagSet()
+	w := fs.Output()
+	listCommands(w)
+	fmt.Fprintf(w, "\nExamples:\n")
+	for _, example := range cmd.examples {
+		fmt.Fprintf(w, "  %s\n", example)
+	}
+	fmt.Fprintf(w, "\nWithout a command, venatus compares, so \"venatus --source A --target B\" still works.\nRun \"venatus help COMMAND\" for more on a command.\n\nFlags:\n")
+	fs.PrintDefaults()
+}
+
+// describe describes a command and its flags.
+func describe(cmd *command) {
+	fs := cmd.flagSet()
+	w := fs.Output()
+	fmt.Fprintf(w, "Usage: venatus %s\n\n%s.\n", cmd.usage, strings.ToUpper(cmd.summary[:1])+cmd.summary[1:])
+	if len(cmd.examples) > 0 {
+		fmt.Fprintf(w, "\nExamples:\n")
+		for _, example := range cmd.examples {
+			fmt.Fprintf(w, "  %s\n", example)
+		}
+	}
+	hasFlags := false
+	fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+	if hasFlags {
+		fmt.Fprintf(w, "\nFlags:\n")
+		fs.PrintDefaults()
+	}
+}
+
+// help describes the commands, or one command and its flags.
+func help(args []string) error {
+	if len(args) == 0 {
+		listCommands(os.Stderr)
+		return nil
+	}
+	cmd := lookupCommand(args[0])
+	if cmd == nil {
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	describe(cmd)
+	return nil
+}
+
+// compareCommand compares a target repo against one or more source repos, and reports how alike
+// they are. It's what venatus does when not given a command.
+func compareCommand(args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("unexpected argument %q (repos are given with --source and --target)", args[0])
+	}
+	if err := setUp(); err != nil {
+		return err
+	}
+	return runComparison()
+}
+
+// diffCommand diffs two files, as pair does, or a target file against its best match in the
+// source, as --show-diff does. Only the one target file is compared, so the latter is quick even
+// in large repos.
+func diffCommand(args []string) error {
+	if len(sources) == 0 && *self == "" && *target == "" {
+		if len(args) != 2 {
+			return errors.New("usage: venatus diff [flags] SOURCE_FILE TARGET_FILE")
+		}
+		if err := setUp(); err != nil {
+			return err
+		}
+		return pairFiles(args[0], args[1])
+	}
+	if len(args) != 1 {
+		return errors.New("usage: venatus diff --source REPO --target REPO [flags] PATH")
+	}
+	if err := setUp(); err != nil {
+		return err
+	}
+	if err := checkRepoFlags(); err != nil {
+		return err
+	}
+	path := args[0]
+	listedTargets = []string{filepath.Join(*target, path)}
+	c, err := compare(sources, *target)
+	if err != nil {
+		return err
+	}
+	d, err := c.diff(path)
+	if err != nil {
+		return err
+	}
+	fmt.Print(d)
+	return nil
+}
+
+var (
+	reportFlags = flag.NewFlagSet("report", flag.ContinueOnError)
+	reportWorst = reportFlags.Int("worst", 0, "only list this many of the worst-scoring files (0 lists them all)")
+)
+
+// reportCommand prints a summary written by --summary-json: the run's overall score and coverage,
+// and its files from the worst-scoring up.
+func reportCommand(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: venatus report [--worst N] SUMMARY_JSON")
+	}
+	runs, err := readSummaries(args)
+	if err != nil {
+		return err
+	}
+	s := runs[0]
+	fmt.Printf("%s: %v over %d files, %d lines\n", s.Target, percentage(s.OverallScore), s.Files, s.LineCount)
+	if s.Metadata != nil {
+		fmt.Printf("Run by venatus %s, started %s\n", s.Metadata.Version, s.Metadata.Started.Format("2006-01-02 15:04:05 MST"))
+	}
+	if s.Coverage != nil {
+		for _, side := range []struct {
+			name string
+			c    coverageSummary
+		}{{"source", s.Coverage.Source}, {"target", s.Coverage.Target}} {
+			c := side.c
+			fmt.Printf("Coverage of %s: %d files examined, %d unreadable, %d too large, %d excluded, %d failed\n", side.name, c.Examined, len(c.Unreadable), len(c.TooLarge), len(c.Excluded), len(c.Failed))
+		}
+	}
+
+	paths := make([]string, 0, len(s.Scores))
+	for path := range s.Scores {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if s.Scores[paths[i]] != s.Scores[paths[j]] {
+			return s.Scores[paths[i]] < s.Scores[paths[j]]
+		}
+		return paths[i] < paths[j]
+	})
+	if *reportWorst > 0 && len(paths) > *reportWorst {
+		paths = paths[:*reportWorst]
+	}
+	matches := make(map[string]string)
+	for _, f := range s.Worst {
+		matches[f.Path] = f.Match
+	}
+	tw := table.NewWriter()
+	tw.SetStyle(table.StyleDouble)
+	tw.AppendHeader(table.Row{"Path", "Best match", "Score"})
+	for _, path := range paths {
+		tw.AppendRow(table.Row{path, matches[path], percentage(s.Scores[path])})
+	}
+	tw.SetColumnConfigs([]table.ColumnConfig{{Number: 3, Align: text.AlignRight}})
+	fmt.Println(tw.Render())
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestCommandFlags(t *testing.T) {
+	tests := []struct {
+		command     string
+		has, hasNot []string
+	}{
+		{"compare", []string{"source", "target", "xlsx", "badge", "junit", "format", "diff-timeout"}, []string{"port", "o", "index", "key"}},
+		{"serve", []string{"port", "tokens", "rate-limit", "audit-log", "keep-jobs", "source", "diff-timeout"}, []string{"xlsx", "badge", "junit", "format"}},
+		{"index", []string{"o", "source", "normalize-tabs"}, []string{"target", "write-index", "format"}},
+		{"query", []string{"index", "target", "format", "sort"}, []string{"source", "xlsx"}},
+		{"pair", []string{"algorithm", "config"}, []string{"source", "target", "format"}},
+		{"sign", []string{"key", "signature"}, []string{"public-key", "config", "source"}},
+		{"report", []string{"worst"}, []string{"config"}},
+	}
+	for _, tt := range tests {
+		fs := lookupCommand(tt.command).flagSet()
+		for _, name := range tt.has {
+			if fs.Lookup(name) == nil {
+				t.Errorf("venatus %s has no --%s", tt.command, name)
+			}
+		}
+		for _, name := range tt.hasNot {
+			if fs.Lookup(name) != nil {
+				t.Errorf("venatus %s has --%s", tt.command, name)
+			}
+		}
+	}
+}
+
+func TestParseFlagsRejects(t *testing.T) {
+	defer func(commandLine *flag.FlagSet) { flag.CommandLine = commandLine }(flag.CommandLine)
+	tests := []struct {
+		command string
+		args    []string
+		want    string
+	}{
+		{"serve", []string{"--xlsx", "out.xlsx"}, "--xlsx doesn't apply to venatus serve"},
+		{"index", []string{"--target", "fork"}, "--target doesn't apply to venatus index"},
+		{"sign", []string{"--port", "80"}, "--port doesn't apply to venatus sign"},
+		{"pair", []string{"--no-such-flag"}, "flag provided but not defined: -no-such-flag"},
+	}
+	for _, tt := range tests {
+		err := parseFlags(lookupCommand(tt.command), tt.args)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("venatus %s %s: error %v, want %q", tt.command, strings.Join(tt.args, " "), err, tt.want)
+		}
+	}
+}
+
+func TestCompletionFlags(t *testing.T) {
+	names := func(command string) map[string]completionFlag {
+		flags := make(map[string]completionFlag)
+		for _, f := range completionFlags(lookupCommand(command)) {
+			flags[f.name] = f
+		}
+		return flags
+	}
+	serve := names("serve")
+	if _, ok := serve["port"]; !ok {
+		t.Error("serve doesn't complete --port")
+	}
+	if _, ok := serve["xlsx"]; ok {
+		t.Error("serve completes --xlsx")
+	}
+	if f := names("history")["by"]; strings.Join(f.values, " ") != "total dir file" {
+		t.Errorf("history --by completes %q", f.values)
+	}
+	if f := names("compare")["dry-run"]; !f.isBool {
+		t.Error("compare --dry-run isn't completed as a bool")
+	}
+}
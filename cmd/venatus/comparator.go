@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+var comparatorCommand = flag.String("comparator", "", "a program to score the similarity of two files instead of --algorithm: it is given the paths of two files holding their normalized code (the target file's first), and must print a score from 0 to 1")
+
+// The command line of --comparator, split into words.
+var comparatorArgs []string
+
+func checkComparator() error {
+	comparatorArgs = strings.Fields(*comparatorCommand)
+	if len(comparatorArgs) == 0 {
+		return nil
+	}
+	if _, err := exec.LookPath(comparatorArgs[0]); err != nil {
+		return fmt.Errorf("--comparator: %w", err)
+	}
+	return nil
+}
+
+// externalComparator scores pairs of files with the --comparator program. A pair it fails to
+// score is logged and scores 0, so that one bad pair doesn't lose a long run.
+type externalComparator struct{}
+
+func (externalComparator) similarity(code1, code2 string) (float64, bool) {
+	score, err := runComparator(code1, code2)
+	if err != nil {
+		slog.Warn("Comparator failed", "err", err)
+		return 0, false
+	}
+	return score, false
+}
+
+// writeTemp writes code to a temporary file for the comparator, returning its path.
+func writeTemp(code string) (string, error) {
+	f, err := os.CreateTemp("", "venatus-*")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.WriteString(code); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
+
+func runComparator(code1, code2 string) (float64, error) {
+	path1, err := writeTemp(code1)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(path1)
+	path2, err := writeTemp(code2)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(path2)
+
+	cmd := exec.Command(comparatorArgs[0], append(comparatorArgs[1:], path1, path2)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w: %s", comparatorArgs[0], err, strings.TrimSpace(stderr.String()))
+	}
+	score, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil || score < 0 || score > 1 {
+		return 0, fmt.Errorf("%s: printed %q, not a score from 0 to 1", comparatorArgs[0], strings.TrimSpace(string(out)))
+	}
+	return score, nil
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// completionValues are the values to complete for flags that take one of a few. Flags not listed
+// here that take a value complete file names.
+func completionValues() map[string][]string {
+	algorithmNames := make([]string, 0, len(algorithms))
+	for name := range algorithms {
+		algorithmNames = append(algorithmNames, name)
+	}
+	sort.Strings(algorithmNames)
+	return map[string][]string{
+		"format":         {"table", "jsonl"},
+		"algorithm":      algorithmNames,
+		"compare":        {"code", "symbols", "strings", "calls", "comments"},
+		"sort":           {"loc", "score", "path", "match"},
+		"weight-by":      {"lines", "tokens", "bytes", "complexity"},
+		"generated":      {"keep", "tag", "exclude"},
+		"group-by":       {"dir"},
+		"log-format":     {"text", "json"},
+		"columns":        columnNames(),
+		"palette":        {"default", "colorblind"},
+		"by":             {"total", "dir", "file"},
+		"asm-dialect":    {"att", "arm", "aarch64"},
+		"map-precedence": {"pin", "best", "warn"},
+	}
+}
+
+// A completionFlag is a flag as shell completion sees it.
+type completionFlag struct {
+	name, description string
+	isBool            bool
+	// The values to offer, or nil for file names.
+	values []string
+}
+
+// completionFlags returns a command's flags. --profile is completed from the profiles of the
+// --config file given before it, which the scripts ask "venatus completion profiles FILE" for.
+func completionFlags(cmd *command) []completionFlag {
+	values := completionValues()
+	var flags []completionFlag
+	cmd.flagSet().VisitAll(func(f *flag.Flag) {
+		b, ok := f.Value.(interface{ IsBoolFlag() bool })
+		flags = append(flags, completionFlag{
+			name:        f.Name,
+			description: shortUsage(f.Usage),
+			isBool:      ok && b.IsBoolFlag(),
+			values:      values[f.Name],
+		})
+	})
+	return flags
+}
+
+// valueFlags returns the flags of every command that take one of a few values, once each.
+func valueFlags() []completionFlag {
+	seen := make(map[string]bool)
+	var flags []completionFlag
+	for _, cmd := range commands {
+		for _, f := range completionFlags(cmd) {
+			if f.values != nil && !seen[f.name] {
+				seen[f.name] = true
+				flags = append(flags, f)
+			}
+		}
+	}
+	return flags
+}
+
+// commandPattern matches a command's name, and any other names it has gone by, in a shell case.
+func commandPattern(cmd *command) string {
+	names := []string{cmd.name}
+	for alias, name := range commandAliases {
+		if name == cmd.name {
+			names = append(names, alias)
+		}
+	}
+	sort.Strings(names[1:])
+	return strings.Join(names, "|")
+}
+
+// shortUsage cuts a flag's usage down to its first phrase, to fit beside it in a menu.
+func shortUsage(usage string) string {
+	for _, sep := range []string{": ", "; ", " (", ", ", ". "} {
+		if i := strings.Index(usage, sep); i > 0 {
+			usage = usage[:i]
+		}
+	}
+	if len(usage) > 60 {
+		usage = usage[:57] + "..."
+	}
+	return usage
+}
+
+// completion prints a script completing venatus's commands, flags, and flag values for a shell:
+//
+//	source <(venatus completion bash)
+//	venatus completion zsh > "${fpath[1]}/_venatus"
+//	venatus completion fish > ~/.config/fish/completions/venatus.fish
+//
+// The scripts run "venatus completion profiles FILE" to list the profiles of a config file.
+func completion(args []string) error {
+	if len(args) == 2 && args[0] == "profiles" {
+		return printProfiles(args[1])
+	}
+	if len(args) != 1 {
+		return errors.New("usage: venatus completion bash|zsh|fish")
+	}
+	switch args[0] {
+	case "bash":
+		writeBashCompletion(os.Stdout)
+	case "zsh":
+		writeZshCompletion(os.Stdout)
+	case "fish":
+		writeFishCompletion(os.Stdout)
+	default:
+		return fmt.Errorf("can't complete for %q (want bash, zsh, or fish)", args[0])
+	}
+	return nil
+}
+
+// printProfiles prints the names of a config file's profiles, a line each, for completing --profile.
+func printProfiles(path string) error {
+	cfg, err := readConfig(path)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(cfg.profiles))
+	for name := range cfg.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Println(name)
+	}
+	return nil
+}
+
+func commandNames() []string {
+	names := make([]string, len(commands))
+	for i, cmd := range commands {
+		names[i] = cmd.name
+	}
+	return names
+}
+
+func writeBashCompletion(w io.Writer) {
+	fmt.Fprintf(w, "# bash completion for venatus\n_venatus() {\n")
+	fmt.Fprintf(w, "  local cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	fmt.Fprintf(w, "  case \"$prev\" in\n")
+	for _, f := range valueFlags() {
+		fmt.Fprintf(w, "    --%s) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", f.name, strings.Join(f.values, " "))
+	}
+	fmt.Fprintf(w, "    --profile)\n")
+	fmt.Fprintf(w, "      local i config\n")
+	fmt.Fprintf(w, "      for ((i = 1; i < COMP_CWORD - 1; i++)); do\n")
+	fmt.Fprintf(w, "        [[ ${COMP_WORDS[i]} == --config ]] && config=\"${COMP_WORDS[i+1]}\"\n")
+	fmt.Fprintf(w, "      done\n")
+	fmt.Fprintf(w, "      [[ -n $config ]] && COMPREPLY=($(compgen -W \"$(venatus completion profiles \"$config\" 2>/dev/null)\" -- \"$cur\"))\n")
+	fmt.Fprintf(w, "      return ;;\n")
+	fmt.Fprintf(w, "  esac\n")
+	fmt.Fprintf(w, "  if [[ $COMP_CWORD -eq 1 && $cur != -* ]]; then\n")
+	fmt.Fprintf(w, "    COMPREPLY=($(compgen -W %q -- \"$cur\")); return\n  fi\n", strings.Join(commandNames(), " "))
+	fmt.Fprintf(w, "  local cmd=compare flags\n")
+	fmt.Fprintf(w, "  [[ ${COMP_WORDS[1]} != -* ]] && cmd=\"${COMP_WORDS[1]}\"\n")
+	fmt.Fprintf(w, "  case \"$cmd\" in\n")
+	for _, cmd := range commands {
+		var names []string
+		for _, f := range completionFlags(cmd) {
+			names = append(names, "--"+f.name)
+		}
+		fmt.Fprintf(w, "    %s) flags=%q ;;\n", commandPattern(cmd), strings.Join(names, " "))
+	}
+	fmt.Fprintf(w, "  esac\n")
+	fmt.Fprintf(w, "  if [[ $cur == -* ]]; then\n")
+	fmt.Fprintf(w, "    COMPREPLY=($(compgen -W \"$flags\" -- \"$cur\")); return\n  fi\n")
+	fmt.Fprintf(w, "  COMPREPLY=($(compgen -f -- \"$cur\"))\n}\ncomplete -o filenames -F _venatus venatus\n")
+}
+
+// zshQuote escapes text for a single-quoted _arguments spec.
+func zshQuote(s string) string {
+	return strings.NewReplacer("'", `'\''`, "[", `\[`, "]", `\]`, ":", `\:`).Replace(s)
+}
+
+func writeZshCompletion(w io.Writer) {
+	fmt.Fprintf(w, "#compdef venatus\n\n")
+	fmt.Fprintf(w, "_venatus_profiles() {\n")
+	fmt.Fprintf(w, "  [[ -n ${opt_args[--config]} ]] && compadd -- ${(f)\"$(venatus completion profiles ${opt_args[--config]} 2>/dev/null)\"}\n")
+	fmt.Fprintf(w, "}\n\n")
+	fmt.Fprintf(w, "_venatus() {\n  local -a commands\n  commands=(\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "    '%s:%s'\n", cmd.name, zshQuote(cmd.summary))
+	}
+	fmt.Fprintf(w, "  )\n")
+	fmt.Fprintf(w, "  if (( CURRENT == 2 )) && [[ $PREFIX != -* ]]; then\n")
+	fmt.Fprintf(w, "    _describe command commands; return\n  fi\n")
+	fmt.Fprintf(w, "  local cmd=compare\n")
+	fmt.Fprintf(w, "  if [[ $words[2] != -* ]]; then\n")
+	fmt.Fprintf(w, "    cmd=$words[2]; shift words; (( CURRENT-- ))\n  fi\n")
+	fmt.Fprintf(w, "  case $cmd in\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "    %s)\n      _arguments \\\n", commandPattern(cmd))
+		for _, f := range completionFlags(cmd) {
+			spec := fmt.Sprintf("--%s[%s]", f.name, zshQuote(f.description))
+			switch {
+			case f.isBool:
+			case f.values != nil:
+				spec += fmt.Sprintf(":%s:(%s)", f.name, strings.Join(f.values, " "))
+			case f.name == "profile":
+				spec += ":profile:_venatus_profiles"
+			default:
+				spec += fmt.Sprintf(":%s:_files", f.name)
+			}
+			fmt.Fprintf(w, "        '%s' \\\n", spec)
+		}
+		fmt.Fprintf(w, "        '*:file:_files' ;;\n")
+	}
+	fmt.Fprintf(w, "  esac\n}\n\n_venatus \"$@\"\n")
+}
+
+func writeFishCompletion(w io.Writer) {
+	fmt.Fprintf(w, "# fish completion for venatus\n")
+	fmt.Fprintf(w, "function __fish_venatus_profiles\n")
+	fmt.Fprintf(w, "    set -l tokens (commandline -opc)\n")
+	fmt.Fprintf(w, "    set -l i (contains -i -- --config $tokens)\n")
+	fmt.Fprintf(w, "    and venatus completion profiles $tokens[(math $i + 1)] 2>/dev/null\n")
+	fmt.Fprintf(w, "end\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "complete -c venatus -n __fish_use_subcommand -f -a %s -d %s\n", cmd.name, fishQuote(cmd.summary))
+	}
+	// Without a command, venatus compares.
+	names := commandNames()
+	for alias := range commandAliases {
+		names = append(names, alias)
+	}
+	sort.Strings(names)
+	for _, cmd := range commands {
+		condition := "__fish_seen_subcommand_from " + strings.ReplaceAll(commandPattern(cmd), "|", " ")
+		if cmd.name == "compare" {
+			condition = "not __fish_seen_subcommand_from " + strings.Join(names, " ") + "; or " + condition
+		}
+		for _, f := range completionFlags(cmd) {
+			fmt.Fprintf(w, "complete -c venatus -n %s -l %s -d %s", fishQuote(condition), f.name, fishQuote(f.description))
+			switch {
+			case f.isBool:
+			case f.values != nil:
+				fmt.Fprintf(w, " -x -a %s", fishQuote(strings.Join(f.values, " ")))
+			case f.name == "profile":
+				fmt.Fprintf(w, " -x -a '(__fish_venatus_profiles)'")
+			default:
+				fmt.Fprintf(w, " -r")
+			}
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\\`, `\\\\`, "'", `\\'`).Replace(s) + "'"
+}
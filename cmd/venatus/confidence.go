@@ -0,0 +1,33 @@
+package main
+
+// How far ahead of the runner-up a match needs to be for there to be no doubt about it.
+const confidentMargin = 0.1
+
+// confidenceAmong estimates how sure we can be that r's match is the right one, given all the
+// candidates it was chosen from. This is separate from how similar the match is: a file can be a
+// poor match for every source file, but clearly a better match for one than the rest.
+// Confidence is lowered when:
+//   - the runner-up scored nearly as well,
+//   - the diff ran out of time, so the score is an estimate, or
+//   - the pre-filter was strict enough that a better candidate may never have been compared. The
+//     better the match that was found, the less likely that is.
+func (r *findResult) confidenceAmong(candidates []candidate, timedOut bool) float64 {
+	if r.matchSimilarity <= 0 {
+		return 0
+	}
+	runnerUp := 0.0
+	for _, other := range candidates {
+		if other.filename != r.matchedFilename {
+			runnerUp = max(runnerUp, other.similarity)
+		}
+	}
+	confidence := min(1, (r.matchSimilarity-runnerUp)/confidentMargin)
+	if timedOut {
+		confidence *= 0.5
+	}
+	strictness := *filenameSimilarityThreshold
+	if *noFilenameFilter {
+		strictness = *fingerprintThreshold
+	}
+	return max(0, confidence*(1-strictness*(1-r.matchSimilarity)))
+}
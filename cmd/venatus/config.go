@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+var (
+	configPath = flag.String("config", "", "JSON file of default flag values, keyed by flag name (e.g. {\"diff-timeout\": \"10s\"})")
+	profile    = flag.String("profile", "", "named set of flag values to use from the \"profiles\" section of the config file")
+)
+
+// A config file is a JSON object of flag values keyed by flag name, plus optionally a "profiles"
+// object of named sets of flag values, so that teams can share comparison settings, and a
+// "languages" object describing kinds of code files, which add to or replace the built-in ones:
+//
+//	{
+//	  "workers": 8,
+//	  "profiles": {
+//	    "quick": {"diff-timeout": "1s", "chunk-size": 65536},
+//	    "audit": {"diff-timeout": "1m", "emit-patches": "patches"}
+//	  },
+//	  "languages": {
+//	    "Fortran": {"extensions": [".f90"], "line-comments": ["!"]},
+//	    "C++": {"extensions": [".cc", ".hh"], "line-comments": ["//"], "block-comment": ["/*", "*/"],
+//	            "normalizer": "strip-banners --all", "weight": 0.5},
+//	    "Lisp": {"extensions": [".el"], "line-comments": [";"], "algorithm": "cosine",
+//	             "tokens": "[^\\s()']+|[()']"}
+//	  }
+//	}
+type config struct {
+	flags     map[string]json.RawMessage
+	profiles  map[string]map[string]json.RawMessage
+	languages map[string]languageConfig
+}
+
+// A languageConfig describes a language in a config file.
+type languageConfig struct {
+	Extensions []string `json:"extensions"`
+	// Whole names of files, like "Makefile".
+	Filenames    []string `json:"filenames"`
+	LineComments []string `json:"line-comments"`
+	// The start and end of block comments.
+	BlockComment []string `json:"block-comment"`
+	// A command line to run files through before they are normalized, as with --normalizers.
+	Normalizer string `json:"normalizer"`
+	// How much files count in totals, as with --extension-weights.
+	Weight float64 `json:"weight"`
+	// Whether the language has C++'s raw string literals, and C's digraphs.
+	RawStrings bool `json:"raw-strings"`
+	Digraphs   bool `json:"digraphs"`
+	// The --algorithm to score files of the language with, in place of the one in use.
+	Algorithm string `json:"algorithm"`
+	// A regular expression matching each token, for the algorithms that compare tokens (cosine and
+	// shingles), in place of each run of letters, digits, and underscores and each other character.
+	Tokens string `json:"tokens"`
+}
+
+// language makes the language a config file describes.
+func (lc languageConfig) language(name string) (*language, error) {
+	lang := &language{name: name, lineComments: lc.LineComments, normalizer: strings.Fields(lc.Normalizer), weight: lc.Weight,
+		rawStrings: lc.RawStrings, digraphs: lc.Digraphs, algorithm: lc.Algorithm, tokens: lc.Tokens}
+	switch len(lc.BlockComment) {
+	case 0:
+	case 2:
+		lang.blockStart, lang.blockEnd = lc.BlockComment[0], lc.BlockComment[1]
+	default:
+		return nil, fmt.Errorf("block-comment must be a start and an end")
+	}
+	if len(lc.Extensions) == 0 && len(lc.Filenames) == 0 {
+		return nil, fmt.Errorf("no extensions or filenames")
+	}
+	if lc.Weight < 0 {
+		return nil, fmt.Errorf("weight must not be negative")
+	}
+	if lc.Algorithm != "" && algorithms[lc.Algorithm] == nil {
+		return nil, fmt.Errorf("unknown algorithm %q (want one of %s)", lc.Algorithm, strings.Join(sortedKeys(algorithms), ", "))
+	}
+	if lc.Tokens != "" {
+		if _, err := regexp.Compile(lc.Tokens); err != nil {
+			return nil, fmt.Errorf("tokens: %w", err)
+		}
+	}
+	if len(lang.normalizer) > 0 {
+		if _, err := exec.LookPath(lang.normalizer[0]); err != nil {
+			return nil, fmt.Errorf("normalizer: %w", err)
+		}
+	}
+	return lang, nil
+}
+
+func readConfig(path string) (*config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &config{profiles: make(map[string]map[string]json.RawMessage)}
+	if err := json.Unmarshal(data, &cfg.flags); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if raw, ok := cfg.flags["profiles"]; ok {
+		delete(cfg.flags, "profiles")
+		if err := json.Unmarshal(raw, &cfg.profiles); err != nil {
+			return nil, fmt.Errorf("%s: profiles: %w", path, err)
+		}
+	}
+	if raw, ok := cfg.flags["languages"]; ok {
+		delete(cfg.flags, "languages")
+		if err := json.Unmarshal(raw, &cfg.languages); err != nil {
+			return nil, fmt.Errorf("%s: languages: %w", path, err)
+		}
+	}
+	return cfg, nil
+}
+
+// applyConfig sets each flag named in the config file. Flags given on the command line take
+// precedence over the selected profile, which takes precedence over the rest of the file.
+func applyConfig() error {
+	if *configPath == "" {
+		if *profile != "" {
+			return fmt.Errorf("--profile %q needs a --config file to come from", *profile)
+		}
+		return nil
+	}
+	cfg, err := readConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	for _, name := range sortedKeys(cfg.languages) {
+		lang, err := cfg.languages[name].language(name)
+		if err != nil {
+			return fmt.Errorf("%s: language %q: %w", *configPath, name, err)
+		}
+		setLanguage(lang, cfg.languages[name].Extensions, cfg.languages[name].Filenames)
+	}
+
+	layers := []map[string]json.RawMessage{cfg.flags}
+	if *profile != "" {
+		p, ok := cfg.profiles[*profile]
+		if !ok {
+			return fmt.Errorf("%s: no profile %q", *configPath, *profile)
+		}
+		layers = []map[string]json.RawMessage{p, cfg.flags}
+	}
+
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	for _, layer := range layers {
+		for name, raw := range layer {
+			if name == "config" || name == "profile" || flag.Lookup(name) == nil && !isFlag(name) {
+				return fmt.Errorf("%s: unknown key %q", *configPath, name)
+			}
+			// Keys for other commands' flags are left for them, so that one file can serve them all.
+			if set[name] || flag.Lookup(name) == nil {
+				continue
+			}
+			if err := flag.Set(name, configValue(raw)); err != nil {
+				return fmt.Errorf("%s: %q: %w", *configPath, name, err)
+			}
+			set[name] = true
+		}
+	}
+	return nil
+}
+
+// configValue turns a JSON value into what would have been written on the command line:
+// strings are unquoted, lists of strings are joined with commas, and numbers and booleans are
+// used as written.
+func configValue(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	// Lists are for flags that can be repeated, like --source.
+	var list []string
+	if err := json.Unmarshal(raw, &list); err == nil {
+		return strings.Join(list, ",")
+	}
+	return strings.TrimSpace(string(raw))
+}
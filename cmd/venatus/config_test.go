@@ -0,0 +1,120 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// withConfig runs applyConfig against a scratch flag set holding flags a, b, and c, with the
+// given config file contents, --profile, and flags set on the command line. It returns the
+// resulting flag values.
+func withConfig(t *testing.T, file, prof string, commandLine map[string]string) (map[string]string, error) {
+	t.Helper()
+	defer func(commandLine *flag.FlagSet, config, prof string) {
+		flag.CommandLine, *configPath, *profile = commandLine, config, prof
+	}(flag.CommandLine, *configPath, *profile)
+
+	flags := flag.NewFlagSet("venatus", flag.ContinueOnError)
+	for _, name := range []string{"a", "b", "c"} {
+		flags.String(name, "default", "")
+	}
+	flags.String("config", "", "")
+	flags.String("profile", "", "")
+	flag.CommandLine = flags
+	for name, value := range commandLine {
+		if err := flags.Set(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	*configPath, *profile = "", prof
+	if file != "" {
+		*configPath = filepath.Join(t.TempDir(), "venatus.json")
+		if err := os.WriteFile(*configPath, []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := applyConfig()
+	got := make(map[string]string)
+	for _, name := range []string{"a", "b", "c"} {
+		got[name] = flags.Lookup(name).Value.String()
+	}
+	return got, err
+}
+
+func TestProfilePrecedence(t *testing.T) {
+	const file = `{
+		"a": "file", "b": "file",
+		"profiles": {
+			"quick": {"a": "quick", "c": "quick"},
+			"empty": {}
+		}
+	}`
+	tests := []struct {
+		name        string
+		profile     string
+		commandLine map[string]string
+		a, b, c     string
+	}{
+		{"no profile", "", nil, "file", "file", "default"},
+		{"profile over the file", "quick", nil, "quick", "file", "quick"},
+		{"empty profile", "empty", nil, "file", "file", "default"},
+		{"command line over the profile", "quick", map[string]string{"a": "flag"}, "flag", "file", "quick"},
+		{"command line set to the default", "quick", map[string]string{"c": "default"}, "quick", "file", "default"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := withConfig(t, file, tt.profile, tt.commandLine)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got["a"] != tt.a || got["b"] != tt.b || got["c"] != tt.c {
+				t.Errorf("a, b, c = %q, %q, %q; want %q, %q, %q", got["a"], got["b"], got["c"], tt.a, tt.b, tt.c)
+			}
+		})
+	}
+}
+
+func TestProfileErrors(t *testing.T) {
+	for name, tt := range map[string]struct{ file, profile string }{
+		"profile without a config file": {"", "quick"},
+		"no such profile":               {`{"profiles": {"quick": {}}}`, "audit"},
+		"unknown key in a profile":      {`{"profiles": {"quick": {"nope": 1}}}`, "quick"},
+		"profile naming a profile":      {`{"profiles": {"quick": {"profile": "audit"}}}`, "quick"},
+		"profiles isn't an object":      {`{"profiles": ["quick"]}`, ""},
+		"top level naming a profile":    {`{"profile": "quick"}`, ""},
+		"unknown key outside a profile": {`{"nope": 1, "profiles": {"quick": {}}}`, "quick"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := withConfig(t, tt.file, tt.profile, nil); err == nil {
+				t.Error("applyConfig() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestLanguageScorer(t *testing.T) {
+	lc := languageConfig{Extensions: []string{".el"}, LineComments: []string{";"}, Algorithm: "cosine", Tokens: `[^\s()']+|[()']`}
+	lang, err := lc.language("Lisp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tokens []string
+	lang.tokenizer().each("(foo-bar 'baz)", func(token string) { tokens = append(tokens, token) })
+	if want := []string{"(", "foo-bar", "'", "baz", ")"}; !slices.Equal(tokens, want) {
+		t.Errorf("tokens = %q, want %q", tokens, want)
+	}
+	if _, ok := scorerFor(lang).(cosineAlgorithm); !ok {
+		t.Errorf("scorerFor() = %T, want the language's cosineAlgorithm", scorerFor(lang))
+	}
+	for name, lc := range map[string]languageConfig{
+		"unknown algorithm": {Extensions: []string{".el"}, Algorithm: "nope"},
+		"bad tokens":        {Extensions: []string{".el"}, Tokens: "("},
+	} {
+		if _, err := lc.language("Lisp"); err == nil {
+			t.Errorf("%s: language() succeeded, want an error", name)
+		}
+	}
+}
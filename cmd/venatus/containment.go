@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	findEmbedded         = flag.Bool("find-embedded", false, "also look for source files pasted whole, or nearly, into larger target files, which score poorly as whole files, and list them with how much of each is contained")
+	containmentThreshold = flag.Float64("containment-threshold", 0.9, "with --find-embedded, the fraction of a source file's lines a target file must contain to list it, from 0 to 1")
+)
+
+// Source files shorter than this many lines are too small to say they were embedded.
+const minEmbeddedLines = 10
+
+func checkContainment() error {
+	if *containmentThreshold <= 0 || *containmentThreshold > 1 {
+		return fmt.Errorf("--containment-threshold must be above 0, and at most 1")
+	}
+	return nil
+}
+
+// An embeddedCopy is a source file much of which is in a target file.
+type embeddedCopy struct {
+	filename string
+	// The fraction of the source file's lines that are in the target file, in order.
+	containment float64
+}
+
+// containment returns the fraction of the lines of code1 that are in code2, in order. Unlike
+// similarity, it is asymmetric: a file pasted into one ten times its size is wholly contained in
+// it, though the two are hardly similar.
+func containment(code1, code2 string) float64 {
+	shared, lines1, _ := sharedLines(code1, code2)
+	if lines1 == 0 {
+		return 0
+	}
+	return float64(shared) / float64(lines1)
+}
+
+// contains estimates from fingerprints the fraction of the distinct lines of b that are in a.
+func contains(a, b fingerprint) float64 {
+	if len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			shared++
+			i++
+			j++
+		}
+	}
+	return float64(shared) / float64(len(b))
+}
+
+// matchEmbedded finds the source files embedded in each target file, with --find-embedded.
+// Fingerprints rule out most pairs; the rest are diffed line by line.
+func (c *comparison) matchEmbedded() {
+	sourcePrints := make(map[string]fingerprint)
+	for path, contents := range c.sourceFiles {
+		if strings.Count(contents, "\n") >= minEmbeddedLines {
+			sourcePrints[path] = fingerprintOf(contents)
+		}
+	}
+	sources := sortedKeys(sourcePrints)
+	for _, result := range c.results {
+		target := c.targetContents(result.filename)
+		print := fingerprintOf(target)
+		for _, source := range sources {
+			if source == result.filename || contains(print, sourcePrints[source]) < *containmentThreshold {
+				continue
+			}
+			// Files as similar as they are contained in each other aren't embedded, just alike.
+			if source == result.matchedFilename && result.matchSimilarity >= *containmentThreshold {
+				continue
+			}
+			if share := containment(c.sourceFiles[source], target); share >= *containmentThreshold {
+				result.embedded = append(result.embedded, embeddedCopy{source, share})
+			}
+		}
+		sort.SliceStable(result.embedded, func(i, j int) bool {
+			return result.embedded[i].containment > result.embedded[j].containment
+		})
+	}
+}
+
+// printEmbedded lists the source files embedded in target files, with --find-embedded.
+func printEmbedded(c *comparison) {
+	var lines []string
+	for _, result := range c.results {
+		for _, e := range result.embedded {
+			source, _ := c.sourceContents(e.filename)
+			lines = append(lines, fmt.Sprintf("  %s contains %v of %s (%d LoC)",
+				c.relTarget(result.filename), percentage(e.containment), c.sourceLabel(e.filename), strings.Count(source, "\n")))
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+	fmt.Printf("\n\n%d source files are embedded in target files:\n%s\n", len(lines), strings.Join(lines, "\n"))
+}
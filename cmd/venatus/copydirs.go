@@ -0,0 +1,103 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var dirCopyThreshold = flag.Float64("dir-copy-threshold", 0.9, "report target directories whose files mirror those of a single source directory (possibly at a different path) at least this closely, from 0 to 1 (more than 1 for no report)")
+
+// A copied directory must have at least this many files, since one file is just a file.
+const minCopiedDirFiles = 2
+
+// A dirCopy is a target directory that looks like a copy of a source directory.
+type dirCopy struct {
+	target, source string
+	files          int
+	score          float64
+}
+
+// codeLines counts lines of normalized code.
+func codeLines(contents string) int {
+	return strings.Count(contents, "\n")
+}
+
+// copiedDirs finds target directories whose files were mostly matched in a single source directory.
+// A directory's score is the line-weighted score of the files matched there, over the lines of
+// whichever of the two directories has more, so that files missing from either side count
+// against it. Only the files directly in each directory count.
+func (c *comparison) copiedDirs() []dirCopy {
+	targetLines := make(map[string]int)
+	// Lines matched in each source directory, weighted by score, by target directory.
+	matched := make(map[string]map[string]float64)
+	matchedFiles := make(map[string]map[string]int)
+	for _, result := range c.results {
+		dir := filepath.Dir(result.filename)
+		targetLines[dir] += result.lineCount
+		if result.matchSimilarity <= 0 {
+			continue
+		}
+		if matched[dir] == nil {
+			matched[dir] = make(map[string]float64)
+			matchedFiles[dir] = make(map[string]int)
+		}
+		sourceDir := filepath.Dir(result.matchedFilename)
+		matched[dir][sourceDir] += result.matchSimilarity * float64(result.lineCount)
+		matchedFiles[dir][sourceDir]++
+	}
+	sourceLines := make(map[string]int)
+	for path := range c.sourceFiles {
+		contents, _ := c.sourceContents(path)
+		sourceLines[filepath.Dir(path)] += codeLines(contents)
+	}
+
+	var copies []dirCopy
+	for dir, bySource := range matched {
+		for _, sourceDir := range sortedKeys(bySource) {
+			if matchedFiles[dir][sourceDir] < minCopiedDirFiles {
+				continue
+			}
+			lines := max(targetLines[dir], sourceLines[sourceDir], 1)
+			if score := bySource[sourceDir] / float64(lines); score >= *dirCopyThreshold {
+				copies = append(copies, dirCopy{target: dir, source: sourceDir, files: matchedFiles[dir][sourceDir], score: score})
+			}
+		}
+	}
+	sort.Slice(copies, func(i, j int) bool {
+		if copies[i].target != copies[j].target {
+			return copies[i].target < copies[j].target
+		}
+		return copies[i].source < copies[j].source
+	})
+	return copies
+}
+
+// dirLabel shows a directory relative to its repo, with a trailing slash.
+func dirLabel(rel string) string {
+	if rel == "" || rel == "." {
+		return "./"
+	}
+	return rel + "/"
+}
+
+// printCopiedDirs lists target directories that look like wholesale copies of source directories.
+func printCopiedDirs(c *comparison) {
+	if *dirCopyThreshold > 1 {
+		return
+	}
+	copies := c.copiedDirs()
+	if len(copies) == 0 {
+		return
+	}
+	fmt.Printf("\n\n%d target directories look like copies of source directories:\n", len(copies))
+	for _, dc := range copies {
+		source := dirLabel(c.relSource(dc.source))
+		if s := c.sourceOf(dc.source); s != nil && len(c.sources) > 1 {
+			source = s.name + ":" + source
+		}
+		fmt.Printf("  %s appears to be a copy of %s at %v (%d files)\n", dirLabel(c.relTarget(dc.target)), source, percentage(dc.score), dc.files)
+	}
+}
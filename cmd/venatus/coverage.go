@@ -0,0 +1,97 @@
+package main
+
+import "fmt"
+
+// printCoverage says how much of a repo was examined, and lists what couldn't be, so that it is
+// clear exactly what the scores cover. Nothing is printed if the whole repo could be read.
+func printCoverage(name string, rel func(string) string, report *walkReport) {
+	if len(report.unreadable) == 0 {
+		return
+	}
+	var missing int64
+	for _, size := range report.unreadableSizes {
+		missing += size
+	}
+	fmt.Printf("\n\nCoverage of %s: examined %d files (%s); %d paths (at least %s) couldn't be read:\n",
+		name, report.examined, byteSize(report.examinedBytes), len(report.unreadable), byteSize(missing))
+	for _, path := range sortedKeys(report.unreadable) {
+		size := "size unknown"
+		if report.unreadableDirs[path] {
+			size = "directory, size unknown"
+		} else if n, ok := report.unreadableSizes[path]; ok {
+			size = byteSize(n)
+		}
+		fmt.Printf("  %s (%s): %v\n", rel(path), size, report.unreadable[path])
+	}
+}
+
+// printTooLarge lists the files skipped for being larger than --max-file-size.
+func printTooLarge(name string, rel func(string) string, report *walkReport) {
+	if len(report.tooLarge) == 0 {
+		return
+	}
+	fmt.Printf("\n\n%d %s files were skipped as larger than --max-file-size (%s):\n", len(report.tooLarge), name, byteSize(*maxFileSize))
+	for _, path := range sortedKeys(report.tooLarge) {
+		fmt.Printf("  %s (%s)\n", rel(path), byteSize(report.tooLarge[path]))
+	}
+}
+
+// byteSize formats a number of bytes for people.
+func byteSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// A coverageSummary lists, in machine-readable reports, what of a repo was left out of the
+// comparison, by relative path.
+type coverageSummary struct {
+	Examined   int      `json:"examined"`
+	Unreadable []string `json:"unreadable,omitempty"`
+	TooLarge   []string `json:"too_large,omitempty"`
+	TooSmall   []string `json:"too_small,omitempty"`
+	Excluded   []string `json:"excluded,omitempty"`
+	// Target files that couldn't be compared, with --keep-going.
+	Failed []string `json:"failed,omitempty"`
+}
+
+// A runCoverage is what was left out of each side of the comparison.
+type runCoverage struct {
+	Source coverageSummary `json:"source"`
+	Target coverageSummary `json:"target"`
+}
+
+func summarizeCoverage(rel func(string) string, report *walkReport) coverageSummary {
+	relAll := func(paths []string) []string {
+		for i, path := range paths {
+			paths[i] = rel(path)
+		}
+		return paths
+	}
+	return coverageSummary{
+		Examined:   report.examined,
+		Unreadable: relAll(sortedKeys(report.unreadable)),
+		TooLarge:   relAll(sortedKeys(report.tooLarge)),
+		TooSmall:   relAll(sortedKeys(report.tooSmall)),
+		Excluded:   relAll(sortedKeys(report.excluded)),
+	}
+}
+
+// coverage sums up what was left out of the comparison, for machine-readable reports.
+func (c *comparison) coverage() *runCoverage {
+	cov := &runCoverage{
+		Source: summarizeCoverage(c.sourceLabel, c.sourceReport),
+		Target: summarizeCoverage(c.relTarget, c.targetReport),
+	}
+	for _, path := range sortedKeys(c.failed.errors) {
+		cov.Target.Failed = append(cov.Target.Failed, c.relTarget(path))
+	}
+	return cov
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"io/fs"
+	"log/slog"
+	"sync"
+)
+
+// An inode identifies a file on disk, whatever path it is reached by.
+type inode struct {
+	dev, ino uint64
+}
+
+// hardLinks keeps track of the files of a walk that are hard links to files already walked, so
+// that each file is read only once, however many paths it has.
+type hardLinks struct {
+	seen map[inode]string
+	// The path each link's file was first walked by, by the link's path.
+	links map[string]string
+	sizes map[string]int64
+}
+
+func newHardLinks() *hardLinks {
+	return &hardLinks{seen: make(map[inode]string), links: make(map[string]string), sizes: make(map[string]int64)}
+}
+
+// linked records the file at path, returning whether it was already walked by another path.
+func (h *hardLinks) linked(path string, info fs.FileInfo) bool {
+	id, ok := inodeOf(info)
+	if !ok {
+		return false
+	}
+	if first, ok := h.seen[id]; ok {
+		h.links[path] = first
+		h.sizes[path] = info.Size()
+		return true
+	}
+	h.seen[id] = path
+	return false
+}
+
+// fill gives the links the contents read by the paths first walked, once the walk is done. Links
+// to files that were skipped are skipped too.
+func (h *hardLinks) fill(files map[string]string, report *walkReport) {
+	for path, first := range h.links {
+		contents, ok := files[first]
+		if !ok {
+			continue
+		}
+		files[path] = contents
+		report.examined++
+		report.examinedBytes += h.sizes[path]
+	}
+	if len(h.links) > 0 {
+		slog.Info("Read hard-linked files once", "links", len(h.links))
+	}
+}
+
+// duplicates numbers the distinct contents of the files compared, so that files with the same
+// contents, like the copies of a board's code in a firmware tree for each of its variants, are
+// only compared once with each source file, and the scores fanned out to all the copies.
+// Choosing the best match still depends on each copy's path.
+type duplicates struct {
+	// The number of each file's contents, by path.
+	ids map[string]int
+	// How many files have each contents.
+	copies map[int]int
+
+	mu     sync.Mutex
+	scores map[[2]int]pairScore
+}
+
+// pairScore is the outcome of comparing a target file with a source file.
+type pairScore struct {
+	similarity float64
+	timedOut   bool
+}
+
+// findDuplicates numbers the contents of the source and target files.
+func (c *comparison) findDuplicates() {
+	byContents := make(map[string]int)
+	d := &duplicates{ids: make(map[string]int), copies: make(map[int]int), scores: make(map[[2]int]pairScore)}
+	for _, files := range []map[string]string{c.sourceFiles, c.targetFiles} {
+		for path, contents := range files {
+			id, ok := byContents[contents]
+			if !ok {
+				id = len(byContents)
+				byContents[contents] = id
+			}
+			if _, ok := d.ids[path]; !ok {
+				d.copies[id]++
+			}
+			d.ids[path] = id
+		}
+	}
+	dupes := 0
+	for _, n := range d.copies {
+		dupes += n - 1
+	}
+	slog.Info("Found files with the same contents", "distinct", len(byContents), "duplicates", dupes)
+	c.dupes = d
+}
+
+// score returns the outcome of comparing the target and source files, from the comparison of
+// copies of them if one has been done, or else by calling compare. Only pairs with copies are
+// remembered, since no other pair is compared twice.
+func (d *duplicates) score(target, source string, compare func() pairScore) pairScore {
+	if d == nil {
+		return compare()
+	}
+	t, tok := d.ids[target]
+	s, sok := d.ids[source]
+	if !tok || !sok || d.copies[t] < 2 && d.copies[s] < 2 {
+		return compare()
+	}
+	key := [2]int{t, s}
+	d.mu.Lock()
+	score, ok := d.scores[key]
+	d.mu.Unlock()
+	if ok {
+		return score
+	}
+	score = compare()
+	d.mu.Lock()
+	d.scores[key] = score
+	d.mu.Unlock()
+	return score
+}
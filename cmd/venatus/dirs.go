@@ -0,0 +1,74 @@
+package main
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// dirSummary aggregates the results for the files directly in one target directory.
+type dirSummary struct {
+	// Relative to the target repo; "." for its root.
+	name      string
+	results   []*findResult
+	lineCount int
+	// Weighted like the overall score.
+	score  float64
+	weight float64
+}
+
+// prefix returns what to strip from the relative paths of files in the directory to get their
+// names within it.
+func (d *dirSummary) prefix() string {
+	if d.name == "." {
+		return ""
+	}
+	return d.name + "/"
+}
+
+// directories summarizes the results by target directory, in order of directory name.
+// Within each directory, results stay in the order of c.results.
+func (c *comparison) directories() []*dirSummary {
+	return c.summarize(path.Dir)
+}
+
+// rollup summarizes the results by the top depth levels of target directory, so that each
+// summary covers a whole subtree.
+func (c *comparison) rollup(depth int) []*dirSummary {
+	return c.summarize(func(rel string) string {
+		dir := path.Dir(rel)
+		if dir == "." {
+			return dir
+		}
+		parts := strings.Split(dir, "/")
+		return strings.Join(parts[:min(depth, len(parts))], "/")
+	})
+}
+
+// summarize groups the results by the directory that dirOf says each relative path belongs to.
+func (c *comparison) summarize(dirOf func(rel string) string) []*dirSummary {
+	byName := make(map[string]*dirSummary)
+	var dirs []*dirSummary
+	for _, result := range c.results {
+		name := dirOf(c.relTarget(result.filename))
+		d, ok := byName[name]
+		if !ok {
+			d = &dirSummary{name: name}
+			byName[name] = d
+			dirs = append(dirs, d)
+		}
+		d.results = append(d.results, result)
+		d.lineCount += result.lineCount
+		d.weight += result.weight()
+		d.score += result.matchSimilarity * result.weight()
+	}
+	for _, d := range dirs {
+		if d.weight > 0 {
+			d.score /= d.weight
+		}
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		return dirs[i].name < dirs[j].name
+	})
+	return dirs
+}
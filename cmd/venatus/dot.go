@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+)
+
+var dotPath = flag.String("dot", "", "write the mapping of target files to their matches to this Graphviz DOT file: a graph of target and source files, grouped by directory, with an edge from each target file to its match weighted by their similarity, to see the fork's structure relative to upstream in Graphviz or Gephi")
+
+// writeDot writes the mapping of target files to source files as a DOT graph. Target files are on
+// the left and source files on the right, each in a box per directory. Edges are labeled with
+// scores, and are thicker and pull harder the more alike their files are. Target files with no
+// match are left out.
+func writeDot(dotFile string, c *comparison) error {
+	f, err := os.Create(dotFile)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	fmt.Fprintf(w, "digraph venatus {\n\trankdir=LR;\n\tnode [shape=box, fontsize=10];\n")
+
+	targets := make(map[string][]string)
+	sources := make(map[string][]string)
+	seen := make(map[string]bool)
+	var edges []*findResult
+	for _, result := range c.results {
+		if result.matchedFilename == "N/A" {
+			continue
+		}
+		edges = append(edges, result)
+		rel := c.relTarget(result.filename)
+		targets[path.Dir(rel)] = append(targets[path.Dir(rel)], rel)
+		if match := c.sourceLabel(result.matchedFilename); !seen[match] {
+			seen[match] = true
+			sources[path.Dir(match)] = append(sources[path.Dir(match)], match)
+		}
+	}
+	writeDotClusters(w, "target", targets)
+	writeDotClusters(w, "source", sources)
+	for _, result := range edges {
+		score := result.matchSimilarity
+		fmt.Fprintf(w, "\t%q -> %q [label=%q, weight=%d, penwidth=%.1f];\n",
+			"target:"+c.relTarget(result.filename), "source:"+c.sourceLabel(result.matchedFilename),
+			percentage(score).String(), 1+int(score*10), 0.5+score*3)
+	}
+	fmt.Fprintf(w, "}\n")
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeDotClusters writes the files on one side of the mapping, in a cluster per directory. Node
+// IDs are prefixed with the side, since the same path is often on both.
+func writeDotClusters(w *bufio.Writer, side string, byDir map[string][]string) {
+	dirs := make([]string, 0, len(byDir))
+	for dir := range byDir {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for i, dir := range dirs {
+		fmt.Fprintf(w, "\tsubgraph cluster_%s_%d {\n\t\tlabel=%q;\n", side, i, side+": "+dir+"/")
+		files := byDir[dir]
+		sort.Strings(files)
+		for _, file := range files {
+			fmt.Fprintf(w, "\t\t%q [label=%q];\n", side+":"+file, path.Base(file))
+		}
+		fmt.Fprintf(w, "\t}\n")
+	}
+}
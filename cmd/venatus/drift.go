@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+var driftRef = flag.String("drift-ref", "", "a newer git ref of the source repo, like origin/main or v2.0: report whether each matched target file is in sync with it, behind it, or has diverged locally, to plan syncs with upstream")
+
+// How a target file stands relative to the newer upstream ref.
+const (
+	inSync          = "in sync"
+	behindUpstream  = "behind upstream"
+	locallyDiverged = "locally diverged"
+	removedUpstream = "removed upstream"
+)
+
+// A drift is how a target file stands relative to a newer upstream ref, with --drift-ref.
+type drift struct {
+	status string
+	// How similar the target file is to its match as of the ref.
+	similarity float64
+}
+
+// driftOf works out how a target file stands relative to its match as of --drift-ref: in sync if
+// it is the same as the newer file, behind if it is the same as its match but upstream has
+// changed since, and locally diverged if it has changed from its match.
+func (c *comparison) driftOf(result *findResult) (*drift, error) {
+	info, err := os.Lstat(result.matchedFilename)
+	if err != nil {
+		return nil, err
+	}
+	lang := languageOf(result.matchedFilename, info)
+	data, err := exec.Command("git", "-C", filepath.Dir(result.matchedFilename), "show", *driftRef+":./"+filepath.Base(result.matchedFilename)).Output()
+	if err != nil {
+		// Most likely the file isn't there at the ref.
+		slog.Debug("Couldn't read match at ref", "path", result.matchedFilename, "ref", *driftRef, "err", err)
+		return &drift{status: removedUpstream}, nil
+	}
+	newer, _, err := normalizeCode(result.matchedFilename, data, lang)
+	if err != nil {
+		return nil, err
+	}
+	target := c.targetContents(result.filename)
+	d := &drift{}
+	d.similarity, _ = scorerFor(lang).similarity(target, newer)
+	switch {
+	case target == newer:
+		d.status = inSync
+	case target == c.sourceFiles[result.matchedFilename]:
+		d.status = behindUpstream
+	default:
+		d.status = locallyDiverged
+	}
+	return d, nil
+}
+
+// matchDrift works out how each matched target file stands relative to --drift-ref.
+func (c *comparison) matchDrift() {
+	for _, result := range c.results {
+		if result.matchedFilename == "N/A" {
+			continue
+		}
+		d, err := c.driftOf(result)
+		if err != nil {
+			slog.Warn("Couldn't compare with upstream ref", "path", result.filename, "err", err)
+			continue
+		}
+		result.drift = d
+	}
+}
+
+// printDrift reports how the matched target files stand relative to --drift-ref.
+func printDrift(c *comparison) {
+	if *driftRef == "" {
+		return
+	}
+	tw := table.NewWriter()
+	tw.SetStyle(table.StyleDouble)
+	tw.AppendHeader(table.Row{"Path", "Status", "Score", "Score at " + *driftRef})
+	files := make(map[string]int)
+	lines := make(map[string]int)
+	for _, result := range c.results {
+		d := result.drift
+		if d == nil {
+			continue
+		}
+		files[d.status]++
+		lines[d.status] += result.lineCount
+		newer := ""
+		if d.status != removedUpstream {
+			newer = percentage(d.similarity).String()
+		}
+		tw.AppendRow(table.Row{c.relTarget(result.filename), d.status, percentage(result.matchSimilarity), newer})
+	}
+	if len(files) == 0 {
+		return
+	}
+	tw.SetColumnConfigs([]table.ColumnConfig{
+		{Number: 3, Align: text.AlignRight},
+		{Number: 4, Align: text.AlignRight},
+	})
+	fmt.Printf("\n\nDrift from %s:\n%s\n", *driftRef, tw.Render())
+	for _, status := range []string{inSync, behindUpstream, locallyDiverged, removedUpstream} {
+		if files[status] > 0 {
+			fmt.Printf("%s: %d files (%d LoC)\n", status, files[status], lines[status])
+		}
+	}
+}
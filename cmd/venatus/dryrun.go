@@ -0,0 +1,147 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+var dryRun = flag.Bool("dry-run", false, "walk both repos and pick the candidates for each target file, then print the planned workload (files, candidate pairs, and a rough estimate of how long diffing them would take) without diffing anything, to check filters and thresholds before a long run")
+
+// Roughly how long diffing a pair of files takes per square byte of the two together: diffing
+// is about quadratic in the size of files that differ much, and those dominate. Exact copies are
+// taken to cost nothing, as they're settled without a diff.
+const diffSecondsPerSquareByte = 2e-10
+
+// How many of the target files with the most candidates the plan lists.
+const planWorstLen = 10
+
+// A filePlan is the work planned for one target file.
+type filePlan struct {
+	path       string
+	candidates int
+	estimate   time.Duration
+}
+
+// A plan is the work a comparison would do, with --dry-run.
+type plan struct {
+	files []filePlan
+	pairs int
+	// The estimated time spent diffing, summed over all pairs.
+	estimate time.Duration
+}
+
+// estimatePair guesses how long diffing a pair of files would take.
+func estimatePair(contents1, contents2 string) time.Duration {
+	if contents1 == contents2 {
+		return 0
+	}
+	n := float64(len(contents1) + len(contents2))
+	d := time.Duration(n * n * diffSecondsPerSquareByte * float64(time.Second))
+	// As set up for the run, so that diffs are never cut short with --deterministic.
+	if dmp.DiffTimeout > 0 {
+		d = min(d, dmp.DiffTimeout)
+	}
+	return d
+}
+
+// planComparisons picks the candidates for each target file, as comparing would, without
+// comparing them.
+func (c *comparison) planComparisons() *plan {
+	p := &plan{}
+	for _, path := range sortedKeys(c.targetFiles) {
+		contents := c.targetFiles[path]
+		filter := c.newCandidateFilter(path, contents)
+		fp := filePlan{path: path}
+		// Comparing searches further only when nothing matched; without diffing, the best guess is
+		// that it will when no candidate gets past the filters. Pinned files checked per
+		// --map-precedence are searched both ways.
+		for _, sets := range [][]map[string]string{c.candidateSets(path), c.crossCheckSets(path)} {
+			planned := fp.candidates
+			for _, sources := range sets {
+				for sourcepath, sourceContents := range sources {
+					if filter.closeEnough(sourcepath, sourceContents) {
+						fp.candidates++
+						fp.estimate += estimatePair(contents, sourceContents)
+					}
+				}
+				if fp.candidates > planned {
+					break
+				}
+			}
+		}
+		p.files = append(p.files, fp)
+		p.pairs += fp.candidates
+		p.estimate += fp.estimate
+	}
+	return p
+}
+
+// printPlan prints the workload planned with --dry-run.
+func printPlan(c *comparison, p *plan) {
+	var sourceBytes, targetBytes int
+	for _, contents := range c.sourceFiles {
+		sourceBytes += len(contents)
+	}
+	for _, contents := range c.targetFiles {
+		targetBytes += len(contents)
+	}
+	fmt.Printf("Source files: %d (%s of normalized code)\n", len(c.sourceFiles), byteSize(int64(sourceBytes)))
+	fmt.Printf("Target files: %d (%s of normalized code)\n", len(c.targetFiles), byteSize(int64(targetBytes)))
+	for _, repo := range []struct {
+		name   string
+		report *walkReport
+	}{{"source", c.sourceReport}, {"target", c.targetReport}} {
+		left := len(repo.report.tooLarge) + len(repo.report.tooSmall) + len(repo.report.excluded) + len(repo.report.unreadable)
+		if left > 0 {
+			fmt.Printf("Left out of the %s: %d too large, %d too small, %d excluded, %d unreadable\n", repo.name,
+				len(repo.report.tooLarge), len(repo.report.tooSmall), len(repo.report.excluded), len(repo.report.unreadable))
+		}
+	}
+
+	counts := make([]int, len(p.files))
+	none := 0
+	for i, f := range p.files {
+		counts[i] = f.candidates
+		if f.candidates == 0 {
+			none++
+		}
+	}
+	sort.Ints(counts)
+	fmt.Printf("\nCandidate pairs to diff: %d\n", p.pairs)
+	if len(counts) > 0 {
+		fmt.Printf("Candidates per target file: min %d, median %d, max %d\n", counts[0], counts[len(counts)/2], counts[len(counts)-1])
+	}
+	fmt.Printf("Target files with no candidates, which would be reported as new: %d\n", none)
+	fmt.Printf("Estimated diffing time: %v with %d workers (%v in all); a rough guess, as files that differ little are diffed faster\n",
+		(p.estimate / time.Duration(max(*workers, 1))).Round(time.Millisecond), max(*workers, 1), p.estimate.Round(time.Millisecond))
+
+	worst := append([]filePlan(nil), p.files...)
+	sort.SliceStable(worst, func(i, j int) bool {
+		return worst[i].estimate > worst[j].estimate
+	})
+	if len(worst) > planWorstLen {
+		worst = worst[:planWorstLen]
+	}
+	if len(worst) == 0 || worst[0].candidates == 0 {
+		return
+	}
+	tw := table.NewWriter()
+	tw.SetStyle(table.StyleDouble)
+	tw.AppendHeader(table.Row{"Target file", "Candidates", "Estimated time"})
+	for _, f := range worst {
+		if f.candidates > 0 {
+			tw.AppendRow(table.Row{c.relTarget(f.path), f.candidates, f.estimate.Round(time.Millisecond)})
+		}
+	}
+	tw.SetColumnConfigs([]table.ColumnConfig{
+		{Number: 2, Align: text.AlignRight},
+		{Number: 3, Align: text.AlignRight},
+	})
+	tw.SetCaption("%s", "The target files that would take longest to compare.")
+	fmt.Println("\n" + tw.Render())
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var dumpDir = flag.String("dump-normalized", "", "directory to write the normalized contents of every file compared into, under source/ and target/, to check what the scores are based on")
+
+// dumpNormalized writes the normalized contents of each source and target file into dir,
+// mirroring the layouts of the repos. Files from several sources go under the names of their
+// sources. Paths that differ only by case get distinct names, as with --emit-patches.
+func dumpNormalized(dir string, c *comparison) (int, error) {
+	written := 0
+	write := func(out, contents string) error {
+		if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(out, []byte(contents), 0644); err != nil {
+			return fmt.Errorf("writing normalized contents: %w", err)
+		}
+		written++
+		return nil
+	}
+
+	suffixes := caseSuffixes(c.sourceCollisions)
+	for path := range c.sourceFiles {
+		contents, _ := c.sourceContents(path)
+		label := strings.Replace(c.sourceLabel(path), ":", "/", 1)
+		out := filepath.Join(dir, "source", label+suffixes[c.relSource(path)])
+		if err := write(out, contents); err != nil {
+			return written, err
+		}
+	}
+	suffixes = caseSuffixes(c.targetCollisions)
+	for path := range c.targetFiles {
+		rel := c.relTarget(path)
+		if err := write(filepath.Join(dir, "target", rel+suffixes[rel]), c.targetContents(path)); err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	embeddingsBackend = flag.String("embeddings", "", "where to get embeddings of code from, for --algorithm=embedding or, with another algorithm, to also report matches that mean the same despite different code: an http(s) URL to POST {\"model\": ..., \"input\": code} to, answering as OpenAI's or Ollama's embedding APIs do, or a command that reads code on standard input and prints its embedding as a JSON array of numbers")
+	embeddingsModel   = flag.String("embeddings-model", "", "with an --embeddings URL, the model to ask it for")
+	semanticGap       = flag.Float64("semantic-gap", 0.3, "with --embeddings and another --algorithm, report matches whose embeddings are at least this much more alike than their score says, as likely translated or heavily refactored")
+)
+
+var embeddingsClient = &http.Client{Timeout: 2 * time.Minute}
+
+func checkEmbeddings() error {
+	if *algorithmName == "embedding" && *embeddingsBackend == "" {
+		return fmt.Errorf("--algorithm=embedding needs --embeddings")
+	}
+	if *semanticGap < 0 || *semanticGap > 1 {
+		return fmt.Errorf("--semantic-gap must be between 0 and 1")
+	}
+	if *embeddingsBackend == "" || isURL(*embeddingsBackend) {
+		return nil
+	}
+	if _, err := exec.LookPath(strings.Fields(*embeddingsBackend)[0]); err != nil {
+		return fmt.Errorf("--embeddings: %w", err)
+	}
+	return nil
+}
+
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+// embeddingAlgorithm scores by the cosine similarity of the files' embeddings, which a model
+// makes from what code means rather than how it is written, so that code translated into another
+// language or heavily refactored can still be found. A file that can't be embedded is logged and
+// scores 0, as with --comparator.
+type embeddingAlgorithm struct{}
+
+func (embeddingAlgorithm) similarity(code1, code2 string) (float64, bool) {
+	score, err := semanticSimilarity(code1, code2)
+	if err != nil {
+		slog.Warn("Couldn't embed code", "err", err)
+		return 0, false
+	}
+	return score, false
+}
+
+// semanticSimilarity is the cosine similarity of two files' embeddings, from 0 to 1.
+func semanticSimilarity(code1, code2 string) (float64, error) {
+	e1, err := embeddings.of(code1)
+	if err != nil {
+		return 0, err
+	}
+	e2, err := embeddings.of(code2)
+	if err != nil {
+		return 0, err
+	}
+	if len(e1) != len(e2) {
+		return 0, fmt.Errorf("--embeddings gave embeddings of %d and %d dimensions", len(e1), len(e2))
+	}
+	var dot, norm1, norm2 float64
+	for i := range e1 {
+		dot += e1[i] * e2[i]
+		norm1 += e1[i] * e1[i]
+		norm2 += e2[i] * e2[i]
+	}
+	if norm1 == 0 || norm2 == 0 {
+		return 0, nil
+	}
+	// Embeddings pointing opposite ways are no more alike than unrelated ones.
+	return max(0, min(1, dot/math.Sqrt(norm1*norm2))), nil
+}
+
+// embeddingCache remembers the embedding of each file's code, since each file is compared with
+// many others and embedding is slow.
+type embeddingCache struct {
+	mu      sync.Mutex
+	vectors map[uint64][]float64
+}
+
+var embeddings = embeddingCache{vectors: make(map[uint64][]float64)}
+
+func (c *embeddingCache) of(code string) ([]float64, error) {
+	key := contentsHash(code)
+	c.mu.Lock()
+	v, ok := c.vectors[key]
+	c.mu.Unlock()
+	if ok {
+		return v, nil
+	}
+	v, err := embed(code)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.vectors[key] = v
+	c.mu.Unlock()
+	return v, nil
+}
+
+// embed asks --embeddings for the embedding of code.
+func embed(code string) ([]float64, error) {
+	if !isURL(*embeddingsBackend) {
+		args := strings.Fields(*embeddingsBackend)
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdin = strings.NewReader(code)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+		}
+		var v []float64
+		if err := json.Unmarshal(out, &v); err != nil {
+			return nil, fmt.Errorf("%s: printed %.40q, not a JSON array of numbers", args[0], out)
+		}
+		return v, nil
+	}
+	body, err := json.Marshal(map[string]string{"model": *embeddingsModel, "input": code})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := embeddingsClient.Post(*embeddingsBackend, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s: %s", *embeddingsBackend, resp.Status, strings.TrimSpace(string(data)))
+	}
+	// OpenAI's API answers with data[0].embedding, Ollama's with embeddings[0] (or, from its
+	// older API, embedding).
+	var answer struct {
+		Data []struct {
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+		Embeddings [][]float64 `json:"embeddings"`
+		Embedding  []float64   `json:"embedding"`
+	}
+	if err := json.Unmarshal(data, &answer); err != nil {
+		return nil, fmt.Errorf("%s: %w", *embeddingsBackend, err)
+	}
+	switch {
+	case len(answer.Data) > 0:
+		return answer.Data[0].Embedding, nil
+	case len(answer.Embeddings) > 0:
+		return answer.Embeddings[0], nil
+	case len(answer.Embedding) > 0:
+		return answer.Embedding, nil
+	}
+	return nil, errors.New(*embeddingsBackend + ": no embedding in the answer")
+}
+
+// matchSemantic scores each match by its embeddings too, when another algorithm chose it.
+func (c *comparison) matchSemantic() {
+	for _, result := range c.results {
+		if result.matchedFilename == "N/A" {
+			continue
+		}
+		score, err := semanticSimilarity(c.targetContents(result.filename), c.sourceFiles[result.matchedFilename])
+		if err != nil {
+			slog.Warn("Couldn't embed code", "path", result.filename, "err", err)
+			continue
+		}
+		result.semantic = &score
+	}
+}
+
+// printSemantic lists the matches whose embeddings are much more alike than their code, with
+// --embeddings.
+func printSemantic(c *comparison) {
+	var alike []*findResult
+	for _, result := range c.results {
+		if result.semantic != nil && *result.semantic-result.matchSimilarity >= *semanticGap {
+			alike = append(alike, result)
+		}
+	}
+	if len(alike) == 0 {
+		return
+	}
+	fmt.Printf("\n\n%d target files mean much the same as their matches, though their code differs:\n", len(alike))
+	for _, result := range alike {
+		fmt.Printf("  %s ~ %s (score %v, semantic %v)\n", c.relTarget(result.filename), c.sourceLabel(result.matchedFilename), percentage(result.matchSimilarity), percentage(*result.semantic))
+	}
+}
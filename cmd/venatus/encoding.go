@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// How much of a file to look at when guessing whether it is UTF-16 without a byte order mark.
+const encodingSniffLen = 4096
+
+// decodeText transcodes a file's contents to UTF-8, and says what it was transcoded from.
+// Byte order marks are believed and removed; failing those, text with NULs in every other byte
+// is taken to be UTF-16, and anything that isn't valid UTF-8 is taken to be Latin-1.
+func decodeText(data []byte) (string, string) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return string(data[3:]), "UTF-8 with BOM"
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return decodeUTF16(data[2:], binary.LittleEndian), "UTF-16LE"
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return decodeUTF16(data[2:], binary.BigEndian), "UTF-16BE"
+	}
+	if order, ok := sniffUTF16(data); ok {
+		if order == binary.ByteOrder(binary.LittleEndian) {
+			return decodeUTF16(data, order), "UTF-16LE"
+		}
+		return decodeUTF16(data, order), "UTF-16BE"
+	}
+	if utf8.Valid(data) {
+		return string(data), "UTF-8"
+	}
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes), "Latin-1"
+}
+
+// sniffUTF16 guesses whether text without a byte order mark is UTF-16, and which way round.
+// Code is mostly ASCII, which in UTF-16 means every other byte is zero.
+func sniffUTF16(data []byte) (binary.ByteOrder, bool) {
+	if len(data) < 2 || len(data)%2 != 0 {
+		return nil, false
+	}
+	head := data[:min(len(data), encodingSniffLen)]
+	var zeros [2]int
+	for i, b := range head {
+		if b == 0 {
+			zeros[i%2]++
+		}
+	}
+	pairs := len(head) / 2
+	switch {
+	case zeros[1] > pairs*3/4 && zeros[0] == 0:
+		return binary.LittleEndian, true
+	case zeros[0] > pairs*3/4 && zeros[1] == 0:
+		return binary.BigEndian, true
+	}
+	return nil, false
+}
+
+func decodeUTF16(data []byte, order binary.ByteOrder) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = order.Uint16(data[2*i:])
+	}
+	return string(utf16.Decode(units))
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestDecodeText(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		text     string
+		encoding string
+	}{
+		{"ASCII", []byte("int a;\n"), "int a;\n", "UTF-8"},
+		{"UTF-8", []byte("// café\n"), "// café\n", "UTF-8"},
+		{"UTF-8 BOM is removed", []byte("\xEF\xBB\xBFint a;\n"), "int a;\n", "UTF-8 with BOM"},
+		{"UTF-16LE BOM", []byte("\xFF\xFEi\x00n\x00t\x00\n\x00"), "int\n", "UTF-16LE"},
+		{"UTF-16BE BOM", []byte("\xFE\xFF\x00i\x00n\x00t\x00\n"), "int\n", "UTF-16BE"},
+		{"UTF-16 surrogate pair", []byte("\xFF\xFE\xE9\x00\x3D\xD8\x00\xDE"), "é😀", "UTF-16LE"},
+		{"UTF-16 unpaired surrogate", []byte("\xFF\xFEa\x00\x3D\xD8"), "a�", "UTF-16LE"},
+		{"UTF-16 BOM with a stray byte", []byte("\xFF\xFEa\x00b"), "a", "UTF-16LE"},
+		{"Latin-1", []byte("// caf\xE9\n"), "// café\n", "Latin-1"},
+		{"Latin-1 high bytes", []byte("\xA9\xFF"), "©ÿ", "Latin-1"},
+		{"empty", nil, "", "UTF-8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, encoding := decodeText(tt.data)
+			if text != tt.text || encoding != tt.encoding {
+				t.Errorf("decodeText(%q) = %q, %q; want %q, %q", tt.data, text, encoding, tt.text, tt.encoding)
+			}
+		})
+	}
+}
+
+func TestSniffUTF16(t *testing.T) {
+	le := func(s string) []byte {
+		var b []byte
+		for _, c := range []byte(s) {
+			b = append(b, c, 0)
+		}
+		return b
+	}
+	be := func(s string) []byte {
+		var b []byte
+		for _, c := range []byte(s) {
+			b = append(b, 0, c)
+		}
+		return b
+	}
+	tests := []struct {
+		name  string
+		data  []byte
+		order binary.ByteOrder // nil if the data shouldn't be taken for UTF-16
+	}{
+		{"little-endian", le("int a;"), binary.LittleEndian},
+		{"big-endian", be("int a;"), binary.BigEndian},
+		{"mostly ASCII", append(le("int a; // "), 0xE9, 0x03, 0xA9, 0x03), binary.LittleEndian},
+		{"too little ASCII", append(le("a"), 0xE9, 0x03, 0xA9, 0x03), nil},
+		{"NULs on both sides", append(le("int a;"), 0, 0), nil},
+		{"odd length", le("int a;")[:11], nil},
+		{"a single NUL", []byte{0}, nil},
+		{"plain ASCII", []byte("int a;\n"), nil},
+		// Only the start of the file is looked at.
+		{"UTF-16 after the sniffed part", append(bytes.Repeat([]byte("ab"), encodingSniffLen/2), le("int a;")...), nil},
+		{"UTF-16 before a binary tail", append(le(string(bytes.Repeat([]byte("x"), encodingSniffLen/2))), 0, 0, 1, 1), binary.LittleEndian},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order, ok := sniffUTF16(tt.data)
+			if ok != (tt.order != nil) || order != tt.order {
+				t.Errorf("sniffUTF16() = %v, %v; want %v", order, ok, tt.order)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+var equivalentExtensionList = flag.String("equivalent-extensions", ".c/.cc/.cpp/.cxx,.h/.hh/.hpp/.hxx", "comma-separated groups of /-separated extensions that the filename filter treats as the same, so that e.g. C files renamed to C++ ones are still compared (\"\" for none)")
+
+// The group of equivalent extensions each extension is in, from --equivalent-extensions, as the
+// first extension of the group.
+var equivalentExtensions map[string]string
+
+func checkEquivalentExtensions() error {
+	equivalentExtensions = make(map[string]string)
+	if *equivalentExtensionList == "" {
+		return nil
+	}
+	for _, group := range strings.Split(*equivalentExtensionList, ",") {
+		exts := strings.Split(strings.TrimSpace(group), "/")
+		for i, ext := range exts {
+			if ext == "" {
+				return fmt.Errorf("--equivalent-extensions: empty extension in %q", group)
+			}
+			if !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
+				exts[i] = ext
+			}
+			if _, ok := equivalentExtensions[ext]; ok {
+				return fmt.Errorf("--equivalent-extensions: %s is in more than one group", ext)
+			}
+			equivalentExtensions[ext] = exts[0]
+		}
+	}
+	return nil
+}
+
+// canonicalName returns a file's base name, with its extension replaced by the first of its group
+// of equivalent extensions, if it's in one.
+func canonicalName(name string) string {
+	base := filepath.Base(name)
+	ext := filepath.Ext(base)
+	if canonical, ok := equivalentExtensions[ext]; ok {
+		return strings.TrimSuffix(base, ext) + canonical
+	}
+	return base
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var filesFrom = flag.String("files-from", "", "only compare the target files listed in this file, one path per line, relative to the target repo (or - to read them from standard input), e.g. the files a pull request touches")
+
+// listedTargets are target files a command has picked out to compare, like --files-from does.
+var listedTargets []string
+
+// readFileList reads the paths listed for --files-from, resolved against root.
+func readFileList(listPath, root string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if listPath != "-" {
+		f, err := os.Open(listPath)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	var paths []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(root, line)
+		}
+		paths = append(paths, filepath.Clean(line))
+	}
+	return paths, scanner.Err()
+}
+
+// openListedFiles reads just the listed code files, rather than walking the whole repo. Listed
+// files that don't exist or aren't code, like those a pull request deletes or its docs, are skipped.
+func openListedFiles(root string, paths []string, pool *contentsPool) (map[string]string, *walkReport) {
+	result := make(map[string]string)
+	report := newWalkReport()
+	for _, path := range paths {
+		info, err := os.Lstat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			// Files deleted by a change are listed too.
+			slog.Debug("Skipping listed file that doesn't exist", "path", path)
+			continue
+		} else if err != nil {
+			report.skipped(path, nil, err)
+			continue
+		}
+		lang := languageOf(path, info)
+		if lang == nil || !info.Mode().IsRegular() {
+			slog.Debug("Skipping listed file that isn't code", "path", path)
+			continue
+		}
+		if *maxFileSize > 0 && info.Size() > *maxFileSize {
+			report.tooLarge[path] = info.Size()
+			continue
+		}
+		if size := tooSmall(info.Size(), "", false); size != "" {
+			report.tooSmall[path] = size
+			continue
+		}
+		t, name := fileTree(path)
+		if hasIgnoreFilePragma(t, name, lang) {
+			report.excluded[path] = pragmaKind
+			continue
+		}
+		if *generatedCode == "exclude" {
+			if kind := codeKind(dirTree(root), filepath.ToSlash(relTo(root, path))); kind != "" {
+				report.excluded[path] = kind
+				continue
+			}
+		}
+		code, ok := report.read(t, name, lang)
+		if size := tooSmall(info.Size(), code, ok); size != "" {
+			report.tooSmall[path] = size
+		} else if ok {
+			result[path] = pool.intern(code)
+			report.examined++
+			report.examinedBytes += info.Size()
+		} else {
+			report.unreadableSizes[path] = info.Size()
+		}
+	}
+	return result, report
+}
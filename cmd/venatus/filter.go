@@ -0,0 +1,29 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+var (
+	minScore = flag.Float64("min-score", 0, "only show files in the table whose score is at least this (0 to 1)")
+	maxScore = flag.Float64("max-score", 1, "only show files in the table whose score is at most this (0 to 1), e.g. 0.8 to see just the files that diverged")
+)
+
+func checkScoreRange() error {
+	if *minScore < 0 || *maxScore > 1 || *minScore > *maxScore {
+		return fmt.Errorf("--min-score and --max-score must be between 0 and 1, with --min-score no more than --max-score")
+	}
+	return nil
+}
+
+// shown reports whether a result is in the range of scores to show. Totals are still over all
+// the results, shown or not.
+func shown(result *findResult) bool {
+	return result.matchSimilarity >= *minScore && result.matchSimilarity <= *maxScore
+}
+
+// filtered reports whether --min-score or --max-score hides any results.
+func filtered() bool {
+	return *minScore > 0 || *maxScore < 1
+}
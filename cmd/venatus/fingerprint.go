@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"hash/fnv"
+	"sort"
+	"strings"
+)
+
+var (
+	noFilenameFilter     = flag.Bool("no-filename-filter", false, "compare files regardless of their names, to find code copied into renamed files")
+	fingerprintThreshold = flag.Float64("fingerprint-threshold", 0.1, "with --no-filename-filter, only compare files sharing more than this fraction of their distinct lines (0 to 1)")
+)
+
+// A fingerprint is the sorted set of hashes of a file's distinct non-blank lines.
+// Comparing fingerprints is much cheaper than diffing, so without the filename filter it is used
+// to rule out pairs that can't be alike before diffing the rest.
+type fingerprint []uint64
+
+func fingerprintOf(contents string) fingerprint {
+	seen := make(map[uint64]bool)
+	var fp fingerprint
+	for _, line := range strings.Split(contents, "\n") {
+		if line == "" {
+			continue
+		}
+		h := fnv.New64a()
+		h.Write([]byte(line))
+		if sum := h.Sum64(); !seen[sum] {
+			seen[sum] = true
+			fp = append(fp, sum)
+		}
+	}
+	sort.Slice(fp, func(i, j int) bool { return fp[i] < fp[j] })
+	return fp
+}
+
+// jaccard returns the size of the intersection of two fingerprints over the size of their union.
+func jaccard(a, b fingerprint) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	shared := 0
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			shared++
+			i++
+			j++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
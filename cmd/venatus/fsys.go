@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A tree is a directory tree to compare: the filesystem its files are read from, and the path
+// they are reported under. Repos on disk are read through os.DirFS, but any fs.FS will do, such as
+// an embedded filesystem, a zip file, or an in-memory fixture.
+type tree struct {
+	fsys fs.FS
+	root string
+	// Whether the tree is the directory at root on disk, so that symlinks in it can be followed.
+	onDisk bool
+}
+
+// dirTree is the tree of the directory on disk at root.
+func dirTree(root string) tree {
+	return tree{fsys: os.DirFS(root), root: root, onDisk: true}
+}
+
+// fileTree is the tree of the directory the file at path is in, and the file's name in it, for
+// reading files given by path.
+func fileTree(path string) (tree, string) {
+	return dirTree(filepath.Dir(path)), filepath.Base(path)
+}
+
+// treeFile is the tree of the directory on disk at root, and the name in it of the file reported
+// under path, for reading again the files of a tree that was walked. Paths outside root are read
+// from the directory they are in.
+func treeFile(root, path string) (tree, string) {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fileTree(path)
+	}
+	return dirTree(root), filepath.ToSlash(rel)
+}
+
+// path is the path the file with the given name in the tree is reported under.
+func (t tree) path(name string) string {
+	return filepath.Join(t.root, filepath.FromSlash(name))
+}
+
+// walk walks the tree as filepath.Walk does, but passes fn the names of files in the tree's
+// filesystem. With --follow-symlinks, trees on disk are walked through their symlinks; fs.FS
+// has no way to say where a symlink points, which following them safely needs.
+func (t tree) walk(fn func(name string, info fs.FileInfo, err error) error) error {
+	if *followSymlinks && t.onDisk {
+		return walkTree(t.root, func(path string, info fs.FileInfo, err error) error {
+			rel, relErr := filepath.Rel(t.root, path)
+			if relErr != nil {
+				return relErr
+			}
+			return fn(filepath.ToSlash(rel), info, err)
+		})
+	}
+	return fs.WalkDir(t.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		var info fs.FileInfo
+		if d != nil {
+			var infoErr error
+			if info, infoErr = d.Info(); err == nil {
+				err = infoErr
+			}
+		}
+		return fn(name, info, t.pathError(name, err))
+	})
+}
+
+// pathError makes errors about a file in the tree say the path it is reported under, rather than
+// its name in the filesystem.
+func (t tree) pathError(name string, err error) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return &fs.PathError{Op: pathErr.Op, Path: t.path(name), Err: pathErr.Err}
+	}
+	return err
+}
+
+// readFile reads a file in the tree as it is.
+func (t tree) readFile(name string) ([]byte, error) {
+	data, err := fs.ReadFile(t.fsys, name)
+	return data, t.pathError(name, err)
+}
+
+// head returns up to the first n bytes of a file, or nothing if it can't be read.
+func (t tree) head(name string, n int64) []byte {
+	f, err := t.fsys.Open(name)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	head, _ := io.ReadAll(io.LimitReader(f, n))
+	return head
+}
+
+// mapFile maps a file in the tree into memory read-only, if it is on disk, or else reads it.
+// The returned function unmaps it again; the data must not be used after that.
+func (t tree) mapFile(name string) ([]byte, func() error, error) {
+	f, err := t.fsys.Open(name)
+	if err != nil {
+		return nil, nil, t.pathError(name, err)
+	}
+	defer f.Close()
+	if file, ok := f.(*os.File); ok {
+		return mapOpened(file)
+	}
+	data, err := io.ReadAll(f)
+	return data, func() error { return nil }, t.pathError(name, err)
+}
+
+// mapFile maps the file at path into memory, as tree.mapFile does.
+func mapFile(path string) ([]byte, func() error, error) {
+	t, name := fileTree(path)
+	return t.mapFile(name)
+}
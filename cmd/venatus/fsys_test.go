@@ -0,0 +1,72 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestOpenAllCodeFilesFS(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    fstest.MapFS
+		want     map[string]string
+		excluded []string
+	}{
+		{
+			name:  "code files, reported under the root",
+			files: fstest.MapFS{"a.c": {Data: []byte("int a;\n")}, "dir/b.h": {Data: []byte("int b;\n")}},
+			want:  map[string]string{"repo/a.c": "int a;\n", "repo/dir/b.h": "int b;\n"},
+		},
+		{
+			name:  "not code",
+			files: fstest.MapFS{"a.c": {Data: []byte("int a;\n")}, "README": {Data: []byte("hello\n")}},
+			want:  map[string]string{"repo/a.c": "int a;\n"},
+		},
+		{
+			name: "script found by its shebang",
+			files: fstest.MapFS{
+				"run":     {Data: []byte("#!/bin/sh\necho hi\n"), Mode: 0755},
+				"run.txt": {Data: []byte("#!/bin/sh\necho hi\n"), Mode: 0755},
+				"notes":   {Data: []byte("#!/bin/sh\necho hi\n"), Mode: 0644},
+			},
+			want: map[string]string{"repo/run": "echo hi\n"},
+		},
+		{
+			name:     "ignore-file pragma",
+			files:    fstest.MapFS{"a.c": {Data: []byte("// venatus:ignore-file\nint a;\n")}, "b.c": {Data: []byte("int b;\n")}},
+			want:     map[string]string{"repo/b.c": "int b;\n"},
+			excluded: []string{"repo/a.c"},
+		},
+		{
+			name: "pragmas in strings",
+			files: fstest.MapFS{
+				"usage.c":  {Data: []byte("const char *usage = \"venatus:ignore-file\";\n")},
+				"region.c": {Data: []byte("puts(\"venatus:ignore-begin\");\nint a;\n/* venatus:ignore-begin */\nint b;\n// venatus:ignore-end\nint c;\n")},
+			},
+			want: map[string]string{
+				"repo/usage.c":  "const char *usage = \"venatus:ignore-file\";\n",
+				"repo/region.c": "puts(\"venatus:ignore-begin\");\nint a;\nint c;\n",
+			},
+		},
+		{
+			name:  "empty",
+			files: fstest.MapFS{},
+			want:  map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, report := openAllCodeFiles(tree{fsys: tt.files, root: "repo"}, nil)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("openAllCodeFiles() = %q, want %q", got, tt.want)
+			}
+			if got := sortedKeys(report.excluded); !reflect.DeepEqual(got, tt.excluded) && len(got)+len(tt.excluded) > 0 {
+				t.Errorf("excluded %q, want %q", got, tt.excluded)
+			}
+			if len(report.unreadable) > 0 {
+				t.Errorf("unreadable: %v", report.unreadable)
+			}
+		})
+	}
+}
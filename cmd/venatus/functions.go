@@ -0,0 +1,141 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+var (
+	matchFunctions    = flag.Bool("functions", false, "also match each function in a target file against the functions of its match, to report how much of the target's code is derived, weighted by lines of code, at the granularity of functions rather than files, and which functions appear original")
+	functionThreshold = flag.Float64("function-threshold", 0.5, "with --functions, how similar a function must be to one of its match's to count as derived, from 0 to 1")
+)
+
+// A function defined in normalized C-like code.
+type function struct {
+	name string
+	// The function's normalized code, from the start of its definition to its closing brace.
+	code      string
+	lineCount int
+}
+
+// A functionMatch is how well a function of a target file matches the functions of its match.
+type functionMatch struct {
+	name       string
+	lineCount  int
+	similarity float64
+}
+
+// functions splits normalized C-like code into the functions it defines, static or not. Like
+// callEdges, it's a rough parse that finds where top-level braces start and end.
+func functions(code string) []function {
+	var funcs []function
+	lines := strings.Split(code, "\n")
+	depth, start := 0, 0
+	var statement strings.Builder
+	name := ""
+	for n, line := range lines {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		inString := byte(0)
+		for i := 0; i < len(line); i++ {
+			c := line[i]
+			switch {
+			case inString != 0:
+				if c == '\\' {
+					i++
+				} else if c == inString {
+					inString = 0
+				}
+				continue
+			case c == '"' || c == '\'':
+				inString = c
+				continue
+			case c == '{':
+				if depth == 0 {
+					name = definedFunction(statement.String())
+					statement.Reset()
+				}
+				depth++
+				continue
+			case c == '}':
+				depth = max(0, depth-1)
+				if depth == 0 && name != "" {
+					funcs = append(funcs, function{name: name, code: strings.Join(lines[start:n+1], "\n") + "\n", lineCount: n + 1 - start})
+					name = ""
+				}
+				continue
+			}
+			if depth > 0 {
+				continue
+			}
+			if c == ';' {
+				statement.Reset()
+				continue
+			}
+			if strings.TrimSpace(statement.String()) == "" && c != ' ' && c != '\t' {
+				start = n
+			}
+			statement.WriteByte(c)
+		}
+		statement.WriteByte(' ')
+	}
+	return funcs
+}
+
+// matchFunctionsOf finds how well each function of a target file matches the functions of its
+// match, if it has one.
+func (c *comparison) matchFunctionsOf(result *findResult) []functionMatch {
+	var sourceFuncs []function
+	if contents, ok := c.sourceContents(result.matchedFilename); ok {
+		sourceFuncs = functions(contents)
+	}
+	var matches []functionMatch
+	for _, fn := range functions(c.targetContents(result.filename)) {
+		m := functionMatch{name: fn.name, lineCount: fn.lineCount}
+		for _, other := range sourceFuncs {
+			if score, _ := similarity(fn.code, other.code); score > m.similarity {
+				m.similarity = score
+			}
+		}
+		matches = append(matches, m)
+	}
+	return matches
+}
+
+// matchAllFunctions matches the functions of every target file, with --functions.
+func (c *comparison) matchAllFunctions() {
+	for _, result := range c.results {
+		result.functions = c.matchFunctionsOf(result)
+	}
+}
+
+// printFunctions reports how much of the target's code in functions is derived from its matches,
+// and lists the functions that appear original, with --functions.
+func printFunctions(c *comparison) {
+	if !*matchFunctions {
+		return
+	}
+	var count, lines, derivedLines int
+	var original []string
+	for _, result := range c.results {
+		for _, fn := range result.functions {
+			count++
+			lines += fn.lineCount
+			if fn.similarity >= *functionThreshold {
+				derivedLines += fn.lineCount
+			} else {
+				original = append(original, fmt.Sprintf("  %s: %s (%d LoC, %v)", c.relTarget(result.filename), fn.name, fn.lineCount, percentage(fn.similarity)))
+			}
+		}
+	}
+	if count == 0 {
+		return
+	}
+	fmt.Printf("\n\nDerived code by function: %v of the %d LoC in %d functions is in functions at least %v similar to one in their file's match.\n",
+		percentage(float64(derivedLines)/float64(max(lines, 1))), lines, count, percentage(*functionThreshold))
+	if len(original) > 0 {
+		fmt.Printf("%d functions appear original:\n%s\n", len(original), strings.Join(original, "\n"))
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// This is a context-triggered piecewise hash in the style of ssdeep: the data is cut into pieces
+// wherever a rolling hash of the last few bytes hits a trigger value, and each piece contributes
+// one character to the signature. Since the cut points depend only on nearby content, an edit
+// only changes the signature locally, and similar data has similar signatures.
+
+const (
+	fuzzyWindow       = 7
+	fuzzySignatureLen = 64
+	fuzzyMinBlockSize = 3
+	fuzzyHashInit     = 0x28021967
+	fuzzyHashPrime    = 0x01000193
+)
+
+const fuzzyAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// A fuzzyHash is the signature of some data at two block sizes, so that it can be compared with
+// signatures of data about half or twice as large.
+type fuzzyHash struct {
+	blockSize  int
+	sig1, sig2 string
+}
+
+func (h fuzzyHash) String() string {
+	return fmt.Sprintf("%d:%s:%s", h.blockSize, h.sig1, h.sig2)
+}
+
+type rollingHash struct {
+	window     [fuzzyWindow]byte
+	h1, h2, h3 uint32
+	n          int
+}
+
+func (r *rollingHash) roll(c byte) uint32 {
+	r.h2 -= r.h1
+	r.h2 += fuzzyWindow * uint32(c)
+	r.h1 += uint32(c)
+	r.h1 -= uint32(r.window[r.n%fuzzyWindow])
+	r.window[r.n%fuzzyWindow] = c
+	r.n++
+	r.h3 = r.h3<<5 ^ uint32(c)
+	return r.h1 + r.h2 + r.h3
+}
+
+func fuzzyHashOf(data []byte) fuzzyHash {
+	blockSize := fuzzyMinBlockSize
+	for blockSize*fuzzySignatureLen < len(data) {
+		blockSize *= 2
+	}
+	for {
+		h := fuzzyHashAt(data, blockSize)
+		// Too short a signature doesn't say much; try again with smaller pieces.
+		if blockSize > fuzzyMinBlockSize && len(h.sig1) < fuzzySignatureLen/2 {
+			blockSize /= 2
+			continue
+		}
+		return h
+	}
+}
+
+func fuzzyHashAt(data []byte, blockSize int) fuzzyHash {
+	var r rollingHash
+	var sig1, sig2 strings.Builder
+	piece1, piece2 := uint32(fuzzyHashInit), uint32(fuzzyHashInit)
+	var trigger uint32
+	for _, c := range data {
+		trigger = r.roll(c)
+		piece1 = piece1*fuzzyHashPrime ^ uint32(c)
+		piece2 = piece2*fuzzyHashPrime ^ uint32(c)
+		if trigger%uint32(blockSize) == uint32(blockSize-1) && sig1.Len() < fuzzySignatureLen-1 {
+			sig1.WriteByte(fuzzyAlphabet[piece1%64])
+			piece1 = fuzzyHashInit
+		}
+		if trigger%uint32(2*blockSize) == uint32(2*blockSize-1) && sig2.Len() < fuzzySignatureLen/2-1 {
+			sig2.WriteByte(fuzzyAlphabet[piece2%64])
+			piece2 = fuzzyHashInit
+		}
+	}
+	// The last piece counts too, even though nothing triggered its end.
+	if trigger != 0 {
+		sig1.WriteByte(fuzzyAlphabet[piece1%64])
+		sig2.WriteByte(fuzzyAlphabet[piece2%64])
+	}
+	return fuzzyHash{blockSize: blockSize, sig1: sig1.String(), sig2: sig2.String()}
+}
+
+// fuzzySimilarity compares two fuzzy hashes, from 0 (nothing in common) to 1 (alike).
+// Hashes whose block sizes aren't within a factor of two of each other can't be compared, and
+// score 0.
+func fuzzySimilarity(a, b fuzzyHash) float64 {
+	if a.blockSize > b.blockSize {
+		a, b = b, a
+	}
+	switch b.blockSize {
+	case a.blockSize:
+		return max(compareSignatures(a.sig1, b.sig1, a.blockSize), compareSignatures(a.sig2, b.sig2, 2*a.blockSize))
+	case 2 * a.blockSize:
+		return compareSignatures(a.sig2, b.sig1, b.blockSize)
+	}
+	return 0
+}
+
+func compareSignatures(s1, s2 string, blockSize int) float64 {
+	s1, s2 = squashRuns(s1), squashRuns(s2)
+	if s1 == "" || s2 == "" {
+		return 0
+	}
+	if s1 == s2 {
+		return 1
+	}
+	// A shared window's worth of pieces is required, so that unrelated signatures don't score by
+	// chance.
+	if !shareSubstring(s1, s2, fuzzyWindow) {
+		return 0
+	}
+	distance, _ := editDistance(s1, s2)
+	score := 1 - float64(distance)/float64(max(len(s1), len(s2)))
+	// With small blocks, signatures are short, and alike by chance more easily.
+	if limit := float64(blockSize/fuzzyMinBlockSize*min(len(s1), len(s2))) / 100; score > limit {
+		score = limit
+	}
+	return max(0, score)
+}
+
+// squashRuns shortens runs of more than three identical characters to three, since they come
+// from repetitive data and otherwise dominate comparisons.
+func squashRuns(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if i >= 3 && s[i] == s[i-1] && s[i] == s[i-2] && s[i] == s[i-3] {
+			continue
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+func shareSubstring(s1, s2 string, n int) bool {
+	for i := 0; i+n <= len(s1); i++ {
+		if strings.Contains(s2, s1[i:i+n]) {
+			return true
+		}
+	}
+	return false
+}
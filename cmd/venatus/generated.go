@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+var generatedCode = flag.String("generated", "keep", "what to do with files that look generated (e.g. \"DO NOT EDIT\", yacc or protobuf output) or vendored: keep, tag (mark them in the table), or exclude")
+
+func checkGenerated() error {
+	switch *generatedCode {
+	case "keep", "tag", "exclude":
+		return nil
+	}
+	return fmt.Errorf("unknown --generated %q (want keep, tag, or exclude)", *generatedCode)
+}
+
+// Things generators write near the top of their output.
+var generatedMarkers = [][]byte{
+	[]byte("DO NOT EDIT"),
+	[]byte("@generated"),
+	[]byte("Code generated by"),
+	[]byte("automatically generated"),
+	[]byte("Automatically generated"),
+	[]byte("A Bison parser"),
+	[]byte("A lexical scanner generated by flex"),
+	[]byte("Generated by the protocol buffer compiler"),
+	[]byte("generated by protoc-c"),
+	[]byte("generated by automake"),
+	[]byte("Generated by CMake"),
+}
+
+// Suffixes of the names of files that generators write.
+var generatedSuffixes = []string{".pb-c.c", ".pb-c.h", ".pb.h", ".pb.c", ".tab.c", ".tab.h", "lex.yy.c"}
+
+// Directories that conventionally hold other projects' code.
+var vendoredDirs = map[string]bool{
+	"vendor": true, "third_party": true, "third-party": true, "thirdparty": true,
+	"external": true, "deps": true, "node_modules": true,
+}
+
+// How much of the start of a file is searched for generator markers.
+const generatedHeaderLen = 4096
+
+// codeKind says whether a file in a tree is "generated", "vendored", or neither ("").
+func codeKind(t tree, name string) string {
+	path, rel := t.path(name), filepath.FromSlash(name)
+	for _, dir := range strings.Split(filepath.ToSlash(filepath.Dir(rel)), "/") {
+		if vendoredDirs[dir] {
+			return "vendored"
+		}
+	}
+	for _, suffix := range generatedSuffixes {
+		if strings.HasSuffix(path, suffix) {
+			return "generated"
+		}
+	}
+	head := t.head(name, generatedHeaderLen)
+	for _, marker := range generatedMarkers {
+		if bytes.Contains(head, marker) {
+			return "generated"
+		}
+	}
+	return ""
+}
+
+// printExcluded lists the files left out with --generated=exclude, or by venatus:ignore-file.
+func printExcluded(name string, rel func(string) string, report *walkReport) {
+	if len(report.excluded) == 0 {
+		return
+	}
+	fmt.Printf("\n\n%d %s files were excluded as generated, vendored, or by %s:\n", len(report.excluded), name, ignoreFilePragma)
+	for _, path := range sortedKeys(report.excluded) {
+		fmt.Printf("  %s (%s)\n", rel(path), report.excluded[path])
+	}
+}
+
+// tag returns what a target file is marked with in the table, with --generated=tag.
+func (c *comparison) tag(path string) string {
+	if kind := c.targetReport.tagged[path]; kind != "" {
+		return " [" + kind + "]"
+	}
+	return ""
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// A gitCommit is the last commit to touch a file, for the commit and date columns.
+type gitCommit struct {
+	hash, date string
+}
+
+var (
+	gitCommitsMu sync.Mutex
+	gitCommits   = make(map[string]gitCommit)
+)
+
+// lastCommit returns the last commit to touch a file, or nothing if the file isn't in a git
+// repo (or git isn't installed).
+func lastCommit(path string) gitCommit {
+	gitCommitsMu.Lock()
+	defer gitCommitsMu.Unlock()
+	if commit, ok := gitCommits[path]; ok {
+		return commit
+	}
+	var commit gitCommit
+	out, err := exec.Command("git", "-C", filepath.Dir(path), "log", "-1", "--format=%h %cs", "--", filepath.Base(path)).Output()
+	if err == nil {
+		commit.hash, commit.date, _ = strings.Cut(strings.TrimSpace(string(out)), " ")
+	}
+	gitCommits[path] = commit
+	return commit
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+var histogramBounds = flag.String("histogram", "50,80,95", "comma-separated percentages dividing the score ranges that the histogram after the results counts files and lines of code in (\"\" for no histogram)")
+
+// The boundaries between the histogram's buckets, from --histogram, as fractions in increasing order.
+var histogramCuts []float64
+
+// How wide the histogram's bars are at most.
+const histogramWidth = 40
+
+func checkHistogram() error {
+	histogramCuts = nil
+	if *histogramBounds == "" {
+		return nil
+	}
+	for _, bound := range strings.Split(*histogramBounds, ",") {
+		pct, err := strconv.ParseFloat(strings.TrimSpace(bound), 64)
+		if err != nil || pct <= 0 || pct >= 100 {
+			return fmt.Errorf("--histogram: %q must be a percentage between 0 and 100", bound)
+		}
+		if n := len(histogramCuts); n > 0 && pct/100 <= histogramCuts[n-1] {
+			return fmt.Errorf("--histogram: percentages must increase")
+		}
+		histogramCuts = append(histogramCuts, pct/100)
+	}
+	return nil
+}
+
+// A histogramBucket counts the target files scoring in a range.
+type histogramBucket struct {
+	low, high percentage
+	files     int
+	lineCount int
+}
+
+// histogram counts the target files, and their lines of code, in each range of scores.
+func (c *comparison) histogram() []*histogramBucket {
+	buckets := make([]*histogramBucket, len(histogramCuts)+1)
+	low := 0.0
+	for i := range buckets {
+		high := 1.0
+		if i < len(histogramCuts) {
+			high = histogramCuts[i]
+		}
+		buckets[i] = &histogramBucket{low: percentage(low), high: percentage(high)}
+		low = high
+	}
+	for _, result := range c.results {
+		i := 0
+		for i < len(histogramCuts) && result.matchSimilarity >= histogramCuts[i] {
+			i++
+		}
+		buckets[i].files++
+		buckets[i].lineCount += result.lineCount
+	}
+	return buckets
+}
+
+// renderHistogram tabularizes how many files and lines of code score in each range, with a bar
+// for the share of lines, so that it's plain at a glance how much of the target is unchanged
+// upstream code, how much is modified, and how much is new.
+func renderHistogram(c *comparison) string {
+	tw := table.NewWriter()
+	tw.SetStyle(table.StyleDouble)
+	tw.AppendHeader(table.Row{"Score", "Files", "LoC", "Share of LoC", ""})
+	for _, bucket := range c.histogram() {
+		share := 0.0
+		if c.totalLineCount > 0 {
+			share = float64(bucket.lineCount) / float64(c.totalLineCount)
+		}
+		scores := fmt.Sprintf("%v to %v", bucket.low, bucket.high)
+		if bucket.high < 1 {
+			scores = fmt.Sprintf("%v to under %v", bucket.low, bucket.high)
+		}
+		tw.AppendRow(table.Row{scores, bucket.files, bucket.lineCount, percentage(share), strings.Repeat("█", int(share*histogramWidth+0.5))})
+	}
+	tw.SetColumnConfigs([]table.ColumnConfig{
+		{Number: 2, Align: text.AlignRight},
+		{Number: 3, Align: text.AlignRight},
+		{Number: 4, Align: text.AlignRight},
+	})
+	return tw.Render()
+}
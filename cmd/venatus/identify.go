@@ -0,0 +1,205 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+// A fingerprint database records the files of releases of upstream projects, so that a target
+// tree can be searched for which releases it contains without the upstream repos at hand. It is
+// gzipped JSON. Each file is recorded only as a 64-bit hash of its normalized code, so the
+// database is small, and holds no upstream code.
+
+// Files with fewer lines of code than this, like empty headers, are in too many projects to say
+// anything about which one a tree contains.
+const minIdentifyingLines = 3
+
+type releaseFingerprint struct {
+	Project string   `json:"project"`
+	Release string   `json:"release"`
+	Hashes  []uint64 `json:"hashes"`
+}
+
+type fingerprintDB struct {
+	Releases []*releaseFingerprint `json:"releases"`
+}
+
+func readFingerprintDB(path string) (*fingerprintDB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	var db fingerprintDB
+	if err := json.NewDecoder(zr).Decode(&db); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &db, nil
+}
+
+func (db *fingerprintDB) write(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(f)
+	err = json.NewEncoder(zw).Encode(db)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// fileHashes returns the sorted, distinct hashes of the normalized code files in a tree that are
+// long enough to identify it.
+func fileHashes(root string) []uint64 {
+	files, _ := openAllCodeFiles(dirTree(root), nil)
+	seen := make(map[uint64]bool)
+	for _, contents := range files {
+		if strings.Count(contents, "\n") < minIdentifyingLines {
+			continue
+		}
+		seen[contentsHash(contents)] = true
+	}
+	hashes := make([]uint64, 0, len(seen))
+	for h := range seen {
+		hashes = append(hashes, h)
+	}
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i] < hashes[j] })
+	return hashes
+}
+
+var (
+	fingerprintFlags  = flag.NewFlagSet("fingerprint", flag.ContinueOnError)
+	fingerprintDBPath = fingerprintFlags.String("db", "", "fingerprint database to add the release to")
+	releaseProject    = fingerprintFlags.String("project", "", "name of the project")
+	releaseName       = fingerprintFlags.String("release", "", "name of the release, like its version")
+)
+
+// fingerprintRelease adds a release of a project to a fingerprint database, creating it if need
+// be, or replacing the release if it's already there:
+//
+//	venatus fingerprint --db releases.db --project zlib --release 1.3.1 path/to/zlib-1.3.1
+func fingerprintRelease(args []string) error {
+	if len(args) != 1 || *fingerprintDBPath == "" || *releaseProject == "" || *releaseName == "" {
+		return errors.New("usage: venatus fingerprint --db DB --project NAME --release VERSION [flags] DIR")
+	}
+	if err := setUp(); err != nil {
+		return err
+	}
+	db := &fingerprintDB{}
+	if _, err := os.Stat(*fingerprintDBPath); err == nil {
+		if db, err = readFingerprintDB(*fingerprintDBPath); err != nil {
+			return err
+		}
+	}
+	fp := &releaseFingerprint{Project: *releaseProject, Release: *releaseName, Hashes: fileHashes(args[0])}
+	replaced := false
+	for i, r := range db.Releases {
+		if r.Project == fp.Project && r.Release == fp.Release {
+			db.Releases[i], replaced = fp, true
+		}
+	}
+	if !replaced {
+		db.Releases = append(db.Releases, fp)
+	}
+	if err := db.write(*fingerprintDBPath); err != nil {
+		return err
+	}
+	fmt.Printf("%s %s: %d files\n", fp.Project, fp.Release, len(fp.Hashes))
+	return nil
+}
+
+// A releaseMatch is how much of a release a target tree contains.
+type releaseMatch struct {
+	release *releaseFingerprint
+	// How many of the release's files are in the tree, unchanged.
+	found int
+}
+
+func (m releaseMatch) share() float64 {
+	return float64(m.found) / float64(max(1, len(m.release.Hashes)))
+}
+
+var (
+	identifyFlags  = flag.NewFlagSet("identify", flag.ContinueOnError)
+	identifyDBPath = identifyFlags.String("db", "", "fingerprint database made with venatus fingerprint")
+	minShare       = identifyFlags.Float64("min-share", 0.2, "only report releases at least this fraction of whose files are in the tree, from 0 to 1")
+)
+
+// identify reports which releases in a fingerprint database a target tree contains, by how many
+// of each release's files are in it unchanged. The release of each project with the most of its
+// files there is the likeliest one:
+//
+//	venatus identify --db releases.db path/to/tree
+func identify(args []string) error {
+	if len(args) != 1 || *identifyDBPath == "" {
+		return errors.New("usage: venatus identify --db DB [flags] DIR")
+	}
+	if err := setUp(); err != nil {
+		return err
+	}
+	db, err := readFingerprintDB(*identifyDBPath)
+	if err != nil {
+		return err
+	}
+	present := make(map[uint64]bool)
+	for _, h := range fileHashes(args[0]) {
+		present[h] = true
+	}
+	var matches []releaseMatch
+	for _, r := range db.Releases {
+		m := releaseMatch{release: r}
+		for _, h := range r.Hashes {
+			if present[h] {
+				m.found++
+			}
+		}
+		if m.found > 0 && m.share() >= *minShare {
+			matches = append(matches, m)
+		}
+	}
+	if len(matches) == 0 {
+		fmt.Println("No release in the database is in the tree.")
+		return nil
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.release.Project != b.release.Project {
+			return a.release.Project < b.release.Project
+		}
+		return a.share() > b.share()
+	})
+	tw := table.NewWriter()
+	tw.SetStyle(table.StyleDouble)
+	tw.AppendHeader(table.Row{"Project", "Release", "Files found", "Share", "Likeliest"})
+	for i, m := range matches {
+		likeliest := ""
+		if i == 0 || matches[i-1].release.Project != m.release.Project {
+			likeliest = "*"
+		}
+		tw.AppendRow(table.Row{m.release.Project, m.release.Release, fmt.Sprintf("%d of %d", m.found, len(m.release.Hashes)), percentage(m.share()), likeliest})
+	}
+	tw.SetColumnConfigs([]table.ColumnConfig{
+		{Number: 3, Align: text.AlignRight},
+		{Number: 4, Align: text.AlignRight},
+	})
+	fmt.Println(tw.Render())
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var (
+	includeHints      = flag.Bool("include-hints", false, "match headers first, then prefer matches for other files that are in the same source directories as the matches of the headers they #include; helps repos with many files of the same name")
+	includeHintWeight = flag.Float64("include-hint-weight", 0.05, "with --include-hints, how much to add to the rank of a candidate in the same directory as the match of a header the target file includes")
+)
+
+func checkIncludeHints() error {
+	if *includeHints && (*compareMode != "code" || *preprocess) {
+		return fmt.Errorf("--include-hints needs --compare=code and no --preprocess, which both drop #include lines")
+	}
+	if *includeHintWeight < 0 {
+		return fmt.Errorf("--include-hint-weight must not be negative")
+	}
+	return nil
+}
+
+// Extensions of C and C++ headers.
+var headerExtensions = map[string]bool{".h": true, ".hh": true, ".hpp": true, ".hxx": true, ".h++": true}
+
+// headerHints are what --include-hints knows of the target repo's headers.
+type headerHints struct {
+	mu sync.Mutex
+	// Target headers by base name, to resolve #includes.
+	byBase map[string][]string
+	// The source directory of each matched target header.
+	matchDirs map[string]string
+}
+
+// phases returns the target files to compare, in batches to compare one after another. With
+// --include-hints, headers come first, so that their matches are known when the rest are compared.
+func (c *comparison) phases(targetFiles map[string]string) [][]string {
+	if !*includeHints {
+		return [][]string{sortedKeys(targetFiles)}
+	}
+	c.headers.byBase = make(map[string][]string)
+	c.headers.matchDirs = make(map[string]string)
+	var headers, rest []string
+	for _, path := range sortedKeys(targetFiles) {
+		if headerExtensions[filepath.Ext(path)] {
+			headers = append(headers, path)
+			base := filepath.Base(path)
+			c.headers.byBase[base] = append(c.headers.byBase[base], path)
+		} else {
+			rest = append(rest, path)
+		}
+	}
+	return [][]string{headers, rest}
+}
+
+// noteHeaderMatch remembers where a target header's match is, with --include-hints.
+func (c *comparison) noteHeaderMatch(result *findResult) {
+	if !*includeHints || !headerExtensions[filepath.Ext(result.filename)] || result.matchedFilename == "N/A" {
+		return
+	}
+	c.headers.mu.Lock()
+	defer c.headers.mu.Unlock()
+	c.headers.matchDirs[result.filename] = filepath.Dir(result.matchedFilename)
+}
+
+// includeHintDirs returns the source directories of the matches of the target headers that a
+// target file includes. Headers are all matched before this is called, so matchDirs no longer
+// changes.
+func (c *comparison) includeHintDirs(code string) map[string]bool {
+	if !*includeHints {
+		return nil
+	}
+	dirs := make(map[string]bool)
+	for _, inc := range includes(code) {
+		for _, header := range c.headers.byBase[path.Base(inc)] {
+			rel := c.relTarget(header)
+			if rel != inc && !strings.HasSuffix(rel, "/"+inc) {
+				continue
+			}
+			if dir, ok := c.headers.matchDirs[header]; ok {
+				dirs[dir] = true
+			}
+		}
+	}
+	return dirs
+}
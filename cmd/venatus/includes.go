@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var compareIncludes = flag.Bool("compare-includes", false, "also compare the #include graphs of the repos: report how many include relationships they share, and list target files whose includes differ from their match's, since forks often keep code identical but rewire headers")
+
+func checkIncludes() error {
+	if *compareIncludes && (*compareMode != "code" || *preprocess) {
+		return fmt.Errorf("--compare-includes needs --compare=code and no --preprocess, which both drop #include lines")
+	}
+	return nil
+}
+
+// includes returns the sorted, distinct files that normalized code #includes, as written.
+func includes(code string) []string {
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(code, "\n") {
+		if m := includePattern.FindStringSubmatch(line); m != nil {
+			seen[m[1]] = true
+		}
+	}
+	return sortedKeys(seen)
+}
+
+// includeEdges adds the include relationships of some files, as "file -> header", to a set.
+// read returns the contents of each file, which may have to be read again.
+func includeEdges(edges map[string]bool, files map[string]string, read, rel func(string) string) {
+	for path := range files {
+		for _, inc := range includes(read(path)) {
+			edges[rel(path)+" -> "+inc] = true
+		}
+	}
+}
+
+// diffIncludes returns what is in the sorted list to but not from, prefixed with +, followed by
+// what is in from but not to, prefixed with -.
+func diffIncludes(from, to []string) []string {
+	var changes, removed []string
+	for _, inc := range to {
+		if i := sort.SearchStrings(from, inc); i == len(from) || from[i] != inc {
+			changes = append(changes, "+"+inc)
+		}
+	}
+	for _, inc := range from {
+		if i := sort.SearchStrings(to, inc); i == len(to) || to[i] != inc {
+			removed = append(removed, "-"+inc)
+		}
+	}
+	return append(changes, removed...)
+}
+
+// printIncludes compares the #include graphs of the repos, with --compare-includes.
+func printIncludes(c *comparison) {
+	if !*compareIncludes {
+		return
+	}
+	targetEdges, sourceEdges := make(map[string]bool), make(map[string]bool)
+	includeEdges(targetEdges, c.targetFiles, c.targetContents, c.relTarget)
+	includeEdges(sourceEdges, c.sourceFiles, func(path string) string {
+		contents, _ := c.sourceContents(path)
+		return contents
+	}, c.relSource)
+	if len(targetEdges) == 0 && len(sourceEdges) == 0 {
+		return
+	}
+	shared, all := overlap(targetEdges, sourceEdges)
+	fmt.Printf("\n\nInclude graphs: %d of %d include relationships (%v) are in both repos.\n", shared, all, percentage(float64(shared)/float64(all)))
+
+	var diverged []string
+	for _, result := range c.results {
+		source, ok := c.sourceContents(result.matchedFilename)
+		if !ok {
+			continue
+		}
+		if changes := diffIncludes(includes(source), includes(c.targetContents(result.filename))); len(changes) > 0 {
+			diverged = append(diverged, fmt.Sprintf("  %s (vs. %s): %s", c.relTarget(result.filename), c.sourceLabel(result.matchedFilename), strings.Join(changes, " ")))
+		}
+	}
+	if len(diverged) > 0 {
+		fmt.Printf("%d target files include different files than their match:\n%s\n", len(diverged), strings.Join(diverged, "\n"))
+	}
+}
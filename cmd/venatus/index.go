@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+)
+
+// An index file holds the normalized contents of every code file in a source repo, so that the
+// source side of a comparison can be loaded without walking and normalizing the repo again.
+//
+// The layout (all integers little-endian) is:
+//
+//	header:  magic [8]byte "VENATUSI", version uint32, entry count uint32, normalization [32]byte
+//	entries: count fixed-size records (see indexEntrySize)
+//	data:    paths and DEFLATE-compressed contents, referenced by offset from the entries
+//
+// Each entry also holds the hash and SimHash of the file's normalized contents, so that they
+// needn't be worked out again each time the index is used. The normalization field is a hash of
+// the flags that change how files are normalized (see normalizeFlags): contents normalized one way
+// can't be compared with contents normalized another, so an index can only be used with the
+// flags it was built with.
+//
+// Because the entry table is fixed-size and uncompressed, a memory-mapped index can be opened
+// without touching the data section; each file's contents are only inflated when asked for.
+// Paths are stored relative to the root of the source repo, so index files can be shared.
+// Readers must reject versions they don't know about.
+const (
+	indexMagic     = "VENATUSI"
+	indexVersion   = 3
+	indexHeaderLen = len(indexMagic) + 4 + 4 + sha256.Size
+	// pathOffset uint64, pathLen uint32, dataOffset uint64, dataLen uint32, rawLen uint32,
+	// hash uint64, simhash uint64
+	indexEntrySize = 8 + 4 + 8 + 4 + 4 + 8 + 8
+)
+
+// writeIndex writes an index of the given files, whose paths are relative to root.
+func writeIndex(w io.Writer, root string, files map[string]string) error {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var data bytes.Buffer
+	entries := make([]byte, 0, indexEntrySize*len(paths))
+	dataStart := uint64(indexHeaderLen + indexEntrySize*len(paths))
+	for _, path := range paths {
+		rel := relTo(root, path)
+		pathOffset := dataStart + uint64(data.Len())
+		data.WriteString(rel)
+
+		dataOffset := dataStart + uint64(data.Len())
+		fw, err := flate.NewWriter(&data, flate.BestCompression)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, files[path]); err != nil {
+			return err
+		}
+		if err := fw.Close(); err != nil {
+			return err
+		}
+		dataLen := dataStart + uint64(data.Len()) - dataOffset
+
+		entries = binary.LittleEndian.AppendUint64(entries, pathOffset)
+		entries = binary.LittleEndian.AppendUint32(entries, uint32(len(rel)))
+		entries = binary.LittleEndian.AppendUint64(entries, dataOffset)
+		entries = binary.LittleEndian.AppendUint32(entries, uint32(dataLen))
+		entries = binary.LittleEndian.AppendUint32(entries, uint32(len(files[path])))
+		entries = binary.LittleEndian.AppendUint64(entries, contentsHash(files[path]))
+		entries = binary.LittleEndian.AppendUint64(entries, simhash(files[path]))
+	}
+
+	header := make([]byte, 0, indexHeaderLen)
+	header = append(header, indexMagic...)
+	header = binary.LittleEndian.AppendUint32(header, indexVersion)
+	header = binary.LittleEndian.AppendUint32(header, uint32(len(paths)))
+	key := normalizationKey()
+	header = append(header, key[:]...)
+	for _, b := range [][]byte{header, entries, data.Bytes()} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sourceIndex is an opened index file.
+type sourceIndex struct {
+	data  []byte
+	count int
+	close func() error
+}
+
+// openIndex maps the given index file into memory and validates its header.
+func openIndex(path string) (*sourceIndex, error) {
+	data, closer, err := mapFile(path)
+	if err != nil {
+		return nil, err
+	}
+	idx := &sourceIndex{data: data, close: closer}
+	if err := idx.validate(); err != nil {
+		idx.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return idx, nil
+}
+
+func (idx *sourceIndex) validate() error {
+	if len(idx.data) < indexHeaderLen || string(idx.data[:len(indexMagic)]) != indexMagic {
+		return errors.New("not a venatus index")
+	}
+	if v := binary.LittleEndian.Uint32(idx.data[8:]); v != indexVersion {
+		return fmt.Errorf("unsupported index version %d (want %d)", v, indexVersion)
+	}
+	idx.count = int(binary.LittleEndian.Uint32(idx.data[12:]))
+	if key := normalizationKey(); !bytes.Equal(idx.data[16:indexHeaderLen], key[:]) {
+		return errors.New("index was built with different normalization flags; rebuild it with the ones given here")
+	}
+	if len(idx.data) < indexHeaderLen+idx.count*indexEntrySize {
+		return errors.New("truncated index")
+	}
+	for i := 0; i < idx.count; i++ {
+		pathOffset, pathLen, dataOffset, dataLen, _ := idx.entry(i)
+		if pathOffset+pathLen > uint64(len(idx.data)) || dataOffset+dataLen > uint64(len(idx.data)) {
+			return fmt.Errorf("entry %d points outside the index", i)
+		}
+	}
+	return nil
+}
+
+func (idx *sourceIndex) entry(i int) (pathOffset, pathLen, dataOffset, dataLen uint64, rawLen int) {
+	e := idx.data[indexHeaderLen+i*indexEntrySize:]
+	return binary.LittleEndian.Uint64(e),
+		uint64(binary.LittleEndian.Uint32(e[8:])),
+		binary.LittleEndian.Uint64(e[12:]),
+		uint64(binary.LittleEndian.Uint32(e[20:])),
+		int(binary.LittleEndian.Uint32(e[24:]))
+}
+
+// Len returns the number of files in the index.
+func (idx *sourceIndex) Len() int {
+	return idx.count
+}
+
+// Path returns the path of the i'th file, relative to the root of the indexed repo.
+func (idx *sourceIndex) Path(i int) string {
+	pathOffset, pathLen, _, _, _ := idx.entry(i)
+	return string(idx.data[pathOffset : pathOffset+pathLen])
+}
+
+// Hash returns the hash of the i'th file's normalized contents, as contentsHash.
+func (idx *sourceIndex) Hash(i int) uint64 {
+	return binary.LittleEndian.Uint64(idx.data[indexHeaderLen+i*indexEntrySize+28:])
+}
+
+// Simhash returns the SimHash of the i'th file's normalized contents.
+func (idx *sourceIndex) Simhash(i int) uint64 {
+	return binary.LittleEndian.Uint64(idx.data[indexHeaderLen+i*indexEntrySize+36:])
+}
+
+// Contents inflates and returns the normalized contents of the i'th file.
+func (idx *sourceIndex) Contents(i int) (string, error) {
+	_, _, dataOffset, dataLen, rawLen := idx.entry(i)
+	fr := flate.NewReader(bytes.NewReader(idx.data[dataOffset : dataOffset+dataLen]))
+	defer fr.Close()
+	var sb bytes.Buffer
+	sb.Grow(rawLen)
+	if _, err := io.Copy(&sb, fr); err != nil {
+		return "", fmt.Errorf("reading %q from index: %w", idx.Path(i), err)
+	}
+	return sb.String(), nil
+}
+
+func (idx *sourceIndex) Close() error {
+	return idx.close()
+}
+
+// isIndexFile reports whether the given path is a regular file (and so, in the place of a repo, an index).
+func isIndexFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// contentsHash hashes normalized contents, to tell identical files apart from the rest cheaply.
+func contentsHash(contents string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(contents))
+	return h.Sum64()
+}
+
+var (
+	indexFlags = flag.NewFlagSet("index", flag.ContinueOnError)
+	indexPath  = indexFlags.String("o", "", "file to write the index to")
+)
+
+// indexCommand builds an index of a source repo without comparing anything against it:
+//
+//	venatus index --source path/to/repo -o repo.idx
+//
+// The index can then be given as --source (or to venatus query) in place of the repo, so that
+// comparisons against the same upstream needn't walk and normalize it each time.
+func indexCommand(args []string) error {
+	if len(sources) != 1 || *indexPath == "" || len(args) != 0 {
+		return errors.New("usage: venatus index --source REPO -o FILE [flags]")
+	}
+	if err := setUp(); err != nil {
+		return err
+	}
+	s := &sourceRepo{root: sources[0]}
+	if isPackage(s.root) {
+		dir, err := fetchPackage(s.root)
+		if err != nil {
+			return err
+		}
+		s.root = dir
+	}
+	files, _, err := openSource(s, nil)
+	if err != nil {
+		return err
+	}
+	if err := saveIndex(*indexPath, s.root, files); err != nil {
+		return err
+	}
+	slog.Info(fmt.Sprintf("Indexed %d files of %s into %s", len(files), sources[0], *indexPath))
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIndexNormalization(t *testing.T) {
+	defer func(defines multiFlag) { cppDefines = defines }(cppDefines)
+	dir := t.TempDir()
+	root := filepath.Join(dir, "zlib")
+	path := filepath.Join(dir, "zlib.vidx")
+	files := map[string]string{filepath.Join(root, "adler32.c"): "#define BASE 65521U\nuLong ZEXPORT adler32(uLong adler, const Bytef *buf, uInt len)\n"}
+
+	cppDefines = multiFlag{"Z_SOLO"}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeIndex(f, root, files); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	idx, err := openIndex(path)
+	if err != nil {
+		t.Fatalf("openIndex() with the same --define: %v", err)
+	}
+	if got, _ := idx.Contents(0); got != files[filepath.Join(root, "adler32.c")] {
+		t.Errorf("Contents(0) = %q", got)
+	}
+	idx.Close()
+
+	cppDefines = multiFlag{"Z_SOLO", "NO_GZIP"}
+	if idx, err := openIndex(path); err == nil || !strings.Contains(err.Error(), "different normalization flags") {
+		if err == nil {
+			idx.Close()
+		}
+		t.Errorf("openIndex() with another --define: error %v, want one about normalization flags", err)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import "io/fs"
+
+// inodeOf identifies the file behind info, on platforms where we don't bother, so that each
+// hard link is read as if it were a copy.
+func inodeOf(info fs.FileInfo) (inode, bool) {
+	return inode{}, false
+}
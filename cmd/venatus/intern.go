@@ -0,0 +1,131 @@
+package main
+
+import (
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"sync"
+)
+
+// contentsPool interns the normalized contents of files, so that identical files, of which a
+// fork and its upstream have many, are held in memory once rather than once per copy. Each
+// comparison has its own pool, kept only while its repos are read; the files interned keep
+// sharing their contents after it is gone.
+type contentsPool struct {
+	mu       sync.Mutex
+	contents map[string]string
+}
+
+func newContentsPool() *contentsPool {
+	return &contentsPool{contents: make(map[string]string)}
+}
+
+// intern returns the pooled copy of contents, adding it if it isn't there yet. A nil pool
+// interns nothing.
+func (p *contentsPool) intern(contents string) string {
+	if p == nil {
+		return contents
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if pooled, ok := p.contents[contents]; ok {
+		return pooled
+	}
+	p.contents[contents] = contents
+	return contents
+}
+
+// releaseTarget lets go of the normalized contents of a target file once it has been compared
+// with all its candidates, since its result is all most reports need. The few that need the
+// code again read it back with targetContents.
+func (c *comparison) releaseTarget(path string) {
+	c.releaseMu.Lock()
+	defer c.releaseMu.Unlock()
+	c.targetFiles[path] = ""
+	c.released[path] = true
+}
+
+// releaseSources lets go of the normalized contents of the source files once scoring is
+// finished. Files loaded from an index, which can't be read again, and those about to be
+// written to one with --write-index, are kept.
+func (c *comparison) releaseSources() {
+	if *indexOut != "" {
+		return
+	}
+	c.releaseMu.Lock()
+	defer c.releaseMu.Unlock()
+	for path := range c.sourceFiles {
+		if s := c.sourceOf(path); s != nil && !isIndexFile(s.root) {
+			c.sourceFiles[path] = ""
+			c.released[path] = true
+		}
+	}
+}
+
+// targetContents returns the normalized contents of a target file, reading it again if they
+// have been released.
+func (c *comparison) targetContents(path string) string {
+	c.releaseMu.Lock()
+	contents, released := c.targetFiles[path], c.released[path]
+	c.releaseMu.Unlock()
+	if !released {
+		return contents
+	}
+	return reread(c.targetRoot, path)
+}
+
+// sourceContents returns the normalized contents of a source file, reading it again if they
+// have been released, and whether it is a source file at all.
+func (c *comparison) sourceContents(path string) (string, bool) {
+	c.releaseMu.Lock()
+	contents, ok := c.sourceFiles[path]
+	released := c.released[path]
+	c.releaseMu.Unlock()
+	if !released {
+		return contents, ok
+	}
+	return reread(c.sourceOf(path).root, path), true
+}
+
+// reread reads and normalizes a file again through the tree it was read from, by the path it
+// is reported under.
+func reread(root, path string) string {
+	t, name := treeFile(root, path)
+	info, err := fs.Stat(t.fsys, name)
+	if err != nil {
+		slog.Warn("Couldn't read file again", "path", path, "err", err)
+		return ""
+	}
+	code, err := t.readNormalized(name, t.languageOf(name, info))
+	if err != nil {
+		slog.Warn("Couldn't read file again", "path", path, "err", err)
+		return ""
+	}
+	return code
+}
+
+// targetLanguage returns the language of a target file, or nil if it can't tell.
+func (c *comparison) targetLanguage(path string) *language {
+	t, name := treeFile(c.targetRoot, path)
+	info, err := fs.Stat(t.fsys, name)
+	if err != nil {
+		return nil
+	}
+	return t.languageOf(name, info)
+}
+
+// readTargetFile reads a target file as it is on disk, through the target tree.
+func (c *comparison) readTargetFile(path string) ([]byte, error) {
+	t, name := treeFile(c.targetRoot, path)
+	return t.readFile(name)
+}
+
+// readSourceFile reads a source file as it is on disk, through the tree of its source repo.
+func (c *comparison) readSourceFile(path string) ([]byte, error) {
+	root := filepath.Dir(path)
+	if s := c.sourceOf(path); s != nil {
+		root = s.root
+	}
+	t, name := treeFile(root, path)
+	return t.readFile(name)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+)
+
+var (
+	junitPath      = flag.String("junit", "", "write the results to this JUnit XML file, a test case per target file, for CI systems like Jenkins and GitLab to show as test results")
+	junitFailBelow = flag.Float64("junit-fail-below", 0.8, "with --junit, fail the test cases of target files scoring below this, from 0 to 1")
+)
+
+func checkJUnit() error {
+	if *junitFailBelow < 0 || *junitFailBelow > 1 {
+		return fmt.Errorf("--junit-fail-below must be between 0 and 1")
+	}
+	return nil
+}
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	// The target file's directory, which CI systems group cases by, and its name.
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnit writes a test case per target file, failing those that score below
+// --junit-fail-below, so that they show up as failed tests.
+func writeJUnit(file string, c *comparison) error {
+	suite := junitSuite{Name: "venatus " + c.targetRoot}
+	for _, result := range c.results {
+		rel := c.relTarget(result.filename)
+		tc := junitCase{ClassName: path.Dir(rel), Name: path.Base(rel)}
+		message := "no similar upstream file"
+		if result.matchedFilename != "N/A" {
+			message = fmt.Sprintf("%v similar to %s", percentage(result.matchSimilarity), c.sourceLabel(result.matchedFilename))
+		}
+		if result.matchSimilarity < *junitFailBelow {
+			tc.Failure = &junitFailure{
+				Message: message,
+				Type:    "divergence",
+				Text:    fmt.Sprintf("%s scores %v, below %v", rel, percentage(result.matchSimilarity), percentage(*junitFailBelow)),
+			}
+			suite.Failures++
+		} else {
+			tc.SystemOut = message
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	suite.Tests = len(suite.Cases)
+
+	data, err := xml.MarshalIndent(junitSuites{Suites: []junitSuite{suite}}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, append([]byte(xml.Header), append(data, '\n')...), 0644)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+var keepGoing = flag.Bool("keep-going", false, "if a target file can't be compared, leave it out and carry on with the rest, listing it under Errors at the end, instead of abandoning the run")
+
+// compareFile is findBestCandidate, turning a panic (as from a diff of some unusual file) into
+// an error for that file, so that it can be reported with the file's name.
+func (c *comparison) compareFile(path, fileContents string) (result *findResult, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("comparing %s: %v", c.relTarget(path), r)
+		}
+	}()
+	return c.findBestCandidate(path, fileContents)
+}
+
+// failures are the target files that couldn't be compared, with --keep-going.
+type failures struct {
+	mu     sync.Mutex
+	errors map[string]error
+}
+
+// add records a file that couldn't be compared, returning the error to stop the run with, if
+// it should stop.
+func (f *failures) add(path string, err error) error {
+	if !*keepGoing {
+		return err
+	}
+	slog.Error("Couldn't compare file, carrying on", "path", path, "err", err)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.errors == nil {
+		f.errors = make(map[string]error)
+	}
+	f.errors[path] = err
+	return nil
+}
+
+// printErrors lists the target files that couldn't be compared, with --keep-going.
+func printErrors(c *comparison) {
+	if len(c.failed.errors) == 0 {
+		return
+	}
+	fmt.Printf("\n\nErrors: %d target files couldn't be compared, and are left out of the results:\n", len(c.failed.errors))
+	for _, path := range sortedKeys(c.failed.errors) {
+		fmt.Printf("  %s: %v\n", c.relTarget(path), c.failed.errors[path])
+	}
+}
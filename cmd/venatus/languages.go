@@ -0,0 +1,261 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// A language describes how comments are written in a kind of code file, and how else to treat
+// its files.
+type language struct {
+	name string
+	// Prefixes that start a comment running to the end of the line.
+	lineComments []string
+	// Delimiters of block comments, if the language has them.
+	blockStart, blockEnd string
+	// A command line to run files through before they are normalized, as with --normalizers, which
+	// takes precedence.
+	normalizer []string
+	// How much files count in totals, as with --extension-weights, which takes precedence. 0 means 1.
+	weight float64
+	// Whether the language has C++'s raw string literals, like R"(...)", which may span lines.
+	rawStrings bool
+	// Whether the language has C's digraphs, like <% for {.
+	digraphs bool
+	// The --algorithm to score files of the language with, if not the one in use.
+	algorithm string
+	// A regular expression matching each token of the language's code, for the algorithms that
+	// compare tokens, if not forEachToken's. It's kept as a string so that languages print the same
+	// each run, as the normalized cache's keys need.
+	tokens string
+}
+
+// The compiled tokens patterns of languages, by pattern.
+var tokenPatterns sync.Map
+
+// tokenizer returns how to split the language's code into tokens.
+func (l *language) tokenizer() tokenizer {
+	if l == nil || l.tokens == "" {
+		return nil
+	}
+	re, ok := tokenPatterns.Load(l.tokens)
+	if !ok {
+		re, _ = tokenPatterns.LoadOrStore(l.tokens, regexp.MustCompile(l.tokens))
+	}
+	return func(code string, fn func(token string)) {
+		for _, token := range re.(*regexp.Regexp).FindAllString(code, -1) {
+			fn(token)
+		}
+	}
+}
+
+var (
+	cLanguage      = &language{name: "C", lineComments: []string{"//"}, blockStart: "/*", blockEnd: "*/", digraphs: true}
+	cppLanguage    = &language{name: "C++", lineComments: []string{"//"}, blockStart: "/*", blockEnd: "*/", rawStrings: true, digraphs: true}
+	goLanguage     = &language{name: "Go", lineComments: []string{"//"}, blockStart: "/*", blockEnd: "*/"}
+	pythonLanguage = &language{name: "Python", lineComments: []string{"#"}}
+	javaLanguage   = &language{name: "Java", lineComments: []string{"//"}, blockStart: "/*", blockEnd: "*/"}
+	rustLanguage   = &language{name: "Rust", lineComments: []string{"//"}, blockStart: "/*", blockEnd: "*/"}
+	jsLanguage     = &language{name: "JavaScript", lineComments: []string{"//"}, blockStart: "/*", blockEnd: "*/"}
+	scriptLanguage = &language{name: "script", lineComments: []string{"#"}}
+	// Assembly's line comments depend on the dialect, per --asm-dialect.
+	asmLanguage          = &language{name: "assembly", blockStart: "/*", blockEnd: "*/"}
+	asmCppLanguage       = &language{name: "preprocessed assembly", blockStart: "/*", blockEnd: "*/"}
+	intelAsmLanguage     = &language{name: "Intel assembly", lineComments: []string{";"}}
+	linkerScriptLanguage = &language{name: "linker script", blockStart: "/*", blockEnd: "*/"}
+	makeLanguage         = &language{name: "Make", lineComments: []string{"#"}}
+	// CMake also has bracket comments, like #[[ ... ]].
+	cmakeLanguage   = &language{name: "CMake", lineComments: []string{"#"}, blockStart: "#[[", blockEnd: "]]"}
+	kconfigLanguage = &language{name: "Kconfig", lineComments: []string{"#"}}
+)
+
+// Interpreters whose scripts we know how to compare, all of which use # for comments.
+var scriptInterpreters = map[string]bool{
+	"sh": true, "bash": true, "dash": true, "ksh": true, "zsh": true,
+	"python": true, "python2": true, "python3": true,
+	"perl": true, "ruby": true, "awk": true, "gawk": true, "tclsh": true,
+}
+
+// The languages of code files, by extension. Languages from the "languages" section of the config
+// file and --extra-languages are added to these, and replace those of the same name.
+var languages = map[string]*language{
+	".c": cLanguage, ".h": cLanguage,
+	".s":   asmLanguage,
+	".S":   asmCppLanguage,
+	".asm": intelAsmLanguage,
+	".ld":  linkerScriptLanguage, ".lds": linkerScriptLanguage,
+	".mk":    makeLanguage,
+	".cmake": cmakeLanguage,
+}
+
+// The languages of code files that are known by their whole name, like build files. These take
+// precedence over languages by extension. Files whose names start with one of these and a dot,
+// like Kconfig.debug, are of the same language.
+var languagesByName = map[string]*language{
+	"Makefile": makeLanguage, "makefile": makeLanguage, "GNUmakefile": makeLanguage, "Kbuild": makeLanguage,
+	"CMakeLists.txt": cmakeLanguage,
+	"Kconfig":        kconfigLanguage,
+}
+
+var asmDialect = flag.String("asm-dialect", "", "dialect of .s and .S assembly files, which says how their line comments start: att (x86, #), arm (@), or aarch64 (//); without it, only /* */ comments are stripped from them")
+
+// The prefixes of line comments in each --asm-dialect. Each is code in the others: # starts an
+// ARM immediate, @ an x86 symbol type like @function, and ; separates statements in all three.
+var asmDialects = map[string][]string{
+	"att":     {"#"},
+	"arm":     {"@"},
+	"aarch64": {"//"},
+}
+
+// checkAsmDialect sets the line comments of assembly files per --asm-dialect. Files named .S are
+// run through the C preprocessor, so a # in them starts a directive instead.
+func checkAsmDialect() error {
+	comments, ok := asmDialects[*asmDialect]
+	if !ok && *asmDialect != "" {
+		return fmt.Errorf("--asm-dialect: unknown dialect %q (want att, arm, or aarch64)", *asmDialect)
+	}
+	asmLanguage.lineComments = comments
+	asmCppLanguage.lineComments = nil
+	for _, prefix := range comments {
+		if prefix != "#" {
+			asmCppLanguage.lineComments = append(asmCppLanguage.lineComments, prefix)
+		}
+	}
+	return nil
+}
+
+var extraLanguageList = flag.String("extra-languages", "", "comma-separated languages to compare files of too, which aren't by default: C++, Go, Python, Java, Rust, and JavaScript, or \"all\" of them")
+
+// Languages that are only compared with --extra-languages, and their extensions.
+var extraLanguages = []struct {
+	lang       *language
+	extensions []string
+}{
+	{cppLanguage, []string{".cc", ".cpp", ".cxx", ".c++", ".hh", ".hpp", ".hxx", ".h++"}},
+	{goLanguage, []string{".go"}},
+	{pythonLanguage, []string{".py", ".pyw"}},
+	{javaLanguage, []string{".java"}},
+	{rustLanguage, []string{".rs"}},
+	{jsLanguage, []string{".js", ".mjs", ".cjs", ".jsx"}},
+}
+
+// checkExtraLanguages adds the languages asked for with --extra-languages. Languages that the
+// config file describes are left as it describes them.
+func checkExtraLanguages() error {
+	if *extraLanguageList == "" {
+		return nil
+	}
+	described := make(map[string]bool)
+	for _, byKey := range []map[string]*language{languages, languagesByName} {
+		for _, lang := range byKey {
+			described[strings.ToLower(lang.name)] = true
+		}
+	}
+	for _, name := range strings.Split(*extraLanguageList, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		found := false
+		for _, extra := range extraLanguages {
+			if name != "all" && name != strings.ToLower(extra.lang.name) {
+				continue
+			}
+			found = true
+			if described[strings.ToLower(extra.lang.name)] {
+				continue
+			}
+			for _, ext := range extra.extensions {
+				languages[ext] = extra.lang
+			}
+		}
+		if !found {
+			return fmt.Errorf("--extra-languages: unknown language %q", name)
+		}
+	}
+	return nil
+}
+
+// setLanguage makes files with the given extensions or names be of a language, in place of any
+// other language of the same name.
+func setLanguage(lang *language, extensions, names []string) {
+	for _, byKey := range []map[string]*language{languages, languagesByName} {
+		for key, old := range byKey {
+			if old.name == lang.name {
+				delete(byKey, key)
+			}
+		}
+	}
+	for _, ext := range extensions {
+		if ext != "" && !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		languages[ext] = lang
+	}
+	for _, name := range names {
+		languagesByName[name] = lang
+	}
+	if lang.name == cLanguage.name {
+		cLanguage = lang
+	}
+}
+
+// languageOf returns the language of the given file, or nil if it isn't a code file.
+func languageOf(path string, info fs.FileInfo) *language {
+	t, name := fileTree(path)
+	return t.languageOf(name, info)
+}
+
+// languageOf returns the language of a file in the tree, or nil if it isn't a code file.
+func (t tree) languageOf(name string, info fs.FileInfo) *language {
+	path := t.path(name)
+	base := filepath.Base(path)
+	if lang, ok := languagesByName[base]; ok {
+		return lang
+	}
+	if prefix, _, ok := strings.Cut(base, "."); ok {
+		if lang, ok := languagesByName[prefix]; ok {
+			return lang
+		}
+	}
+	ext := filepath.Ext(path)
+	if lang, ok := languages[ext]; ok {
+		return lang
+	}
+	// Scripts are often named without an extension, but are executable and start with a shebang.
+	if ext == "" && info.Mode().IsRegular() && info.Mode().Perm()&0111 != 0 && scriptInterpreters[shebangInterpreter(t.head(name, maxShebangLen))] {
+		return scriptLanguage
+	}
+	return nil
+}
+
+// How much of a file's first line is looked at for a #! line.
+const maxShebangLen = 256
+
+// shebangInterpreter returns the name of the interpreter in the #! line at the start of head, if
+// there is one. "#!/usr/bin/env python3" and "#!/usr/bin/python3" both give "python3".
+func shebangInterpreter(head []byte) string {
+	line, _, _ := strings.Cut(string(head), "\n")
+	rest, ok := strings.CutPrefix(line, "#!")
+	if !ok {
+		return ""
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return ""
+	}
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" {
+		interpreter = ""
+		for _, arg := range fields[1:] {
+			// Skip over env's own options (e.g., -S) and variable assignments.
+			if !strings.HasPrefix(arg, "-") && !strings.Contains(arg, "=") {
+				interpreter = filepath.Base(arg)
+				break
+			}
+		}
+	}
+	return interpreter
+}
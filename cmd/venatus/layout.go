@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path"
+)
+
+var compareLayout = flag.Bool("compare-layout", false, "also report how similar the layouts of the repos are, regardless of their contents: how many relative paths of code files and directories they share, and which directories seem to have moved")
+
+// A layout is the code files and directories of a repo, relative to its root.
+type layout struct {
+	files, dirs map[string]bool
+}
+
+func newLayout(paths []string) *layout {
+	l := &layout{files: make(map[string]bool), dirs: make(map[string]bool)}
+	for _, p := range paths {
+		l.files[p] = true
+		for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+			l.dirs[dir] = true
+		}
+	}
+	return l
+}
+
+// overlap returns how many of the keys of two sets are in both, and in either.
+func overlap(a, b map[string]bool) (both, either int) {
+	for k := range a {
+		if b[k] {
+			both++
+		}
+	}
+	return both, len(a) + len(b) - both
+}
+
+// movedDirs finds target directories whose files' matches are mostly in a single source directory
+// with a different relative path, mapping each to that directory.
+func (c *comparison) movedDirs() map[string]string {
+	counts := make(map[string]map[string]int)
+	for _, result := range c.results {
+		if result.matchSimilarity <= 0 {
+			continue
+		}
+		dir := path.Dir(c.relTarget(result.filename))
+		if counts[dir] == nil {
+			counts[dir] = make(map[string]int)
+		}
+		counts[dir][path.Dir(c.relSource(result.matchedFilename))]++
+	}
+	moved := make(map[string]string)
+	for dir, bySource := range counts {
+		best, bestCount, total := "", 0, 0
+		for _, sourceDir := range sortedKeys(bySource) {
+			total += bySource[sourceDir]
+			if bySource[sourceDir] > bestCount {
+				best, bestCount = sourceDir, bySource[sourceDir]
+			}
+		}
+		if best != dir && 2*bestCount > total {
+			moved[dir] = best
+		}
+	}
+	return moved
+}
+
+// printLayout reports how similar the layouts of the repos are, with --compare-layout.
+func printLayout(c *comparison) {
+	if !*compareLayout {
+		return
+	}
+	var targetPaths, sourcePaths []string
+	for _, result := range c.results {
+		targetPaths = append(targetPaths, c.relTarget(result.filename))
+	}
+	for p := range c.sourceFiles {
+		sourcePaths = append(sourcePaths, c.relSource(p))
+	}
+	target, source := newLayout(targetPaths), newLayout(sourcePaths)
+	sharedFiles, allFiles := overlap(target.files, source.files)
+	sharedDirs, allDirs := overlap(target.dirs, source.dirs)
+	fmt.Printf("\n\nLayout: %d of %d code file paths (%v) and %d of %d directories (%v) are in both repos.\n",
+		sharedFiles, allFiles, percentage(float64(sharedFiles)/float64(max(allFiles, 1))),
+		sharedDirs, allDirs, percentage(float64(sharedDirs)/float64(max(allDirs, 1))))
+	moved := c.movedDirs()
+	if len(moved) == 0 {
+		return
+	}
+	fmt.Printf("%d directories seem to have moved, going by where most of their files' matches are:\n", len(moved))
+	for _, dir := range sortedKeys(moved) {
+		fmt.Printf("  %s <- %s\n", dirLabel(dir), dirLabel(moved[dir]))
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// A lexState is what a line of code leaves unfinished for the next: a block comment, or a raw
+// string literal.
+type lexState struct {
+	blockComment bool
+	// The end of the raw string literal the line ended in, like )delim".
+	rawStringEnd string
+}
+
+// The start of a C++ raw string literal, like R"delim( or u8R"(.
+var rawStringPattern = regexp.MustCompile(`^(?:u8|[uUL])?R"([^()\\ \t]{0,16})\(`)
+
+// Digraphs and the tokens they stand for, longest first.
+var digraphs = []struct{ digraph, token string }{
+	{"%:%:", "##"}, {"<%", "{"}, {"%>", "}"}, {"<:", "["}, {":>", "]"}, {"%:", "#"},
+}
+
+func isIdentifierByte(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+// lexLine works out whether a line of code is entirely comment (ignoring whitespace), skipping
+// over string literals so that comment markers in them aren't mistaken for comments, and what
+// the line is with any digraphs replaced by the tokens they stand for, in languages that have
+// them. A blank line is a comment if it's within a block comment. state is what the previous
+// line left unfinished, and next is what this one does.
+func lexLine(line string, lang *language, state lexState) (comment bool, code string, next lexState) {
+	comment, code, _, next = lexLineComments(line, lang, state)
+	return comment, code, next
+}
+
+// lexLineComments is lexLine, also returning the text of the comments on the line, markers and
+// all, one after another.
+func lexLineComments(line string, lang *language, state lexState) (comment bool, code, comments string, next lexState) {
+	var sb, cb strings.Builder
+	hasCode, hasComment := false, state.blockComment
+	for i := 0; i < len(line); {
+		rest := line[i:]
+		if state.rawStringEnd != "" {
+			hasCode = true
+			end := strings.Index(rest, state.rawStringEnd)
+			if end < 0 {
+				sb.WriteString(rest)
+				break
+			}
+			end += len(state.rawStringEnd)
+			sb.WriteString(rest[:end])
+			i += end
+			state.rawStringEnd = ""
+			continue
+		}
+		if state.blockComment {
+			hasComment = true
+			end := strings.Index(rest, lang.blockEnd)
+			if end < 0 {
+				sb.WriteString(rest)
+				cb.WriteString(rest)
+				break
+			}
+			end += len(lang.blockEnd)
+			sb.WriteString(rest[:end])
+			cb.WriteString(rest[:end])
+			i += end
+			state.blockComment = false
+			continue
+		}
+		c := line[i]
+		if c == ' ' || c == '\t' {
+			sb.WriteByte(c)
+			i++
+			continue
+		}
+		// Block comments come first, in case they start with a line comment's prefix, as in CMake.
+		if lang.blockStart != "" && strings.HasPrefix(rest, lang.blockStart) {
+			hasComment = true
+			state.blockComment = true
+			sb.WriteString(lang.blockStart)
+			cb.WriteString(lang.blockStart)
+			i += len(lang.blockStart)
+			continue
+		}
+		if hasAnyPrefix(rest, lang.lineComments) {
+			hasComment = true
+			sb.WriteString(rest)
+			cb.WriteString(rest)
+			break
+		}
+		hasCode = true
+		if lang.rawStrings && (i == 0 || !isIdentifierByte(line[i-1])) {
+			if m := rawStringPattern.FindStringSubmatch(rest); m != nil {
+				state.rawStringEnd = ")" + m[1] + `"`
+				sb.WriteString(m[0])
+				i += len(m[0])
+				continue
+			}
+		}
+		if c == '"' || c == '\'' {
+			// Ordinary literals end on the line they start on.
+			end := i + 1
+			for end < len(line) && line[end] != c {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end = min(end+1, len(line))
+			sb.WriteString(line[i:end])
+			i = end
+			continue
+		}
+		if lang.digraphs {
+			if token, n := digraphAt(rest); n > 0 {
+				sb.WriteString(token)
+				i += n
+				continue
+			}
+		}
+		sb.WriteByte(c)
+		i++
+	}
+	return !hasCode && hasComment, sb.String(), cb.String(), state
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// digraphAt returns the token that a digraph at the start of s stands for, and how long the
+// digraph is, or 0 if there isn't one. As in C++, <:: is < followed by :: unless it's followed by
+// : or >, so that templates of names in the global namespace like a<::b> aren't mangled.
+func digraphAt(s string) (string, int) {
+	if strings.HasPrefix(s, "<::") && !strings.HasPrefix(s, "<:::") && !strings.HasPrefix(s, "<::>") {
+		return "", 0
+	}
+	for _, d := range digraphs {
+		if strings.HasPrefix(s, d.digraph) {
+			return d.token, len(d.digraph)
+		}
+	}
+	return "", 0
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLexLineLiterals(t *testing.T) {
+	tests := []struct {
+		line    string
+		lang    *language
+		comment bool
+	}{
+		{`// "a string"`, cLanguage, true},
+		{`"// not a comment"`, cLanguage, false},
+		{`'/' '*' '/'`, cLanguage, false},
+		{`"a\"/*"`, cLanguage, false},
+		{`"a\\" // the string ended`, cLanguage, false},
+		{`'\'' /* comment */`, cLanguage, false},
+		{`"unterminated // still in the string`, cLanguage, false},
+		{`"# not a comment"`, pythonLanguage, false},
+		{`# "a string"`, pythonLanguage, true},
+	}
+	for _, tt := range tests {
+		comment, code, next := lexLine(tt.line, tt.lang, lexState{})
+		if comment != tt.comment || code != tt.line || next != (lexState{}) {
+			t.Errorf("lexLine(%q, %s) = %v, %q, %+v; want %v, the line, no state", tt.line, tt.lang.name, comment, code, next, tt.comment)
+		}
+	}
+}
+
+func TestLexLineRawStrings(t *testing.T) {
+	tests := []struct {
+		name string
+		lang *language
+		// Lines that are entirely comment start with !.
+		lines []string
+	}{
+		{"on one line", cppLanguage, []string{`auto s = R"(/* not a comment)";`, "!// comment"}},
+		{"over lines", cppLanguage, []string{`const char *s = R"(`, "// not a comment", "/* nor this", `)";`, "!// but this is"}},
+		{"with a delimiter", cppLanguage, []string{`auto s = u8R"sql(`, `)" // still in the string`, `)sql"; // comment`, "!/* comment */"}},
+		{"every prefix", cppLanguage, []string{`uR"(`, `)" LR"(`, `)" UR"x(`, `)x"`, "!//"}},
+		{"delimiter too long", cppLanguage, []string{`R"12345678901234567(`, "!// comment"}},
+		{"prefix inside an identifier", cppLanguage, []string{`FOOR"(" /* comment */`, "int a;"}},
+		{"not in C", cLanguage, []string{`R"(`, "!// comment"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var state lexState
+			for _, line := range tt.lines {
+				want := line != "" && line[0] == '!'
+				if want {
+					line = line[1:]
+				}
+				var comment bool
+				comment, _, state = lexLine(line, tt.lang, state)
+				if comment != want {
+					t.Errorf("lexLine(%q) comment = %v, want %v", line, comment, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLexLineDigraphs(t *testing.T) {
+	tests := []struct {
+		line, code string
+		lang       *language
+	}{
+		{"%:define A <% a<:0:> %>", "#define A { a[0] }", cLanguage},
+		{"%:%:", "##", cLanguage},
+		{"a<::b> c<:::d:>", "a<::b> c[::d]", cppLanguage},
+		{"a<::>", "a[]", cppLanguage},
+		{`"<%" <%`, `"<%" {`, cLanguage},
+		{"x; // <% %>", "x; // <% %>", cLanguage},
+		{`R"(<:)" <:`, `R"(<:)" [`, cppLanguage},
+		{"x := m<:0:>", "x := m<:0:>", goLanguage},
+	}
+	for _, tt := range tests {
+		if _, code, _ := lexLine(tt.line, tt.lang, lexState{}); code != tt.code {
+			t.Errorf("lexLine(%q, %s) code = %q, want %q", tt.line, tt.lang.name, code, tt.code)
+		}
+	}
+}
+
+func TestLexLineAsmDialects(t *testing.T) {
+	defer func(dialect string) {
+		*asmDialect = dialect
+		checkAsmDialect()
+	}(*asmDialect)
+	tests := []struct {
+		dialect string
+		lang    *language
+		// Lines that are entirely comment start with !.
+		lines []string
+	}{
+		{"arm", asmLanguage, []string{
+			"\tmov\tr0, #0\t\t@ zero the counter",
+			"#1",
+			"!@ r1 holds the base address",
+			"\tldr\tr2, [r1, #4]!",
+			"!/* push the frame */",
+		}},
+		{"att", asmLanguage, []string{
+			"\t.type\tmain, @function",
+			"@function",
+			"!# set up the frame",
+			"\tmovl\t$0, %eax\t# zero it",
+			"\tpushq\t%rbp; movq\t%rsp, %rbp",
+		}},
+		{"aarch64", asmLanguage, []string{
+			"\tmov\tx0, #0\t// zero it",
+			"!// x1 holds the base address",
+			"#0",
+			"; not a comment here",
+		}},
+		{"att", asmCppLanguage, []string{
+			"#include <asm/linkage.h>",
+			"!/* SYM_FUNC_START(memcpy) */",
+		}},
+		{"arm", asmCppLanguage, []string{
+			"#define STACK_TOP 0x20001000",
+			"!@ reset handler",
+		}},
+		{"", asmLanguage, []string{
+			"# not known to be a comment",
+			"@ nor this",
+			"!/* but this is */",
+		}},
+	}
+	for _, tt := range tests {
+		*asmDialect = tt.dialect
+		if err := checkAsmDialect(); err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range tt.lines {
+			want := strings.HasPrefix(line, "!")
+			line = strings.TrimPrefix(line, "!")
+			if comment, _, _ := lexLine(line, tt.lang, lexState{}); comment != want {
+				t.Errorf("--asm-dialect=%s: lexLine(%q, %s) is a comment: %v, want %v", tt.dialect, line, tt.lang.name, comment, want)
+			}
+		}
+	}
+	*asmDialect = "z80"
+	if err := checkAsmDialect(); err == nil {
+		t.Error("checkAsmDialect() accepted an unknown dialect")
+	}
+}
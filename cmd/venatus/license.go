@@ -0,0 +1,154 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	stripLicenseHeaders  = flag.Bool("strip-license-headers", false, "drop leading copyright/license comment blocks, and the blank lines around them, before comparing")
+	ignoreCopyrightYears = flag.Bool("ignore-copyright-years", true, "ignore the years and ranges of years, like 2019-2024, in copyright lines, so that yearly bumps of headers don't lower scores")
+)
+
+// Years and ranges of years, like 2019, 2019-2024, 2019, 2021, or 2019-present.
+var copyrightYearsPattern = regexp.MustCompile(`\b(19|20)\d\d(\s*(-|–|,)\s*((19|20)\d\d|present))*\b`)
+
+// normalizeCopyrightYears replaces the years in a copyright line with YEAR, for --ignore-copyright-years.
+func normalizeCopyrightYears(line string) string {
+	if !*ignoreCopyrightYears {
+		return line
+	}
+	lower := strings.ToLower(line)
+	if !strings.Contains(lower, "copyright") && !strings.Contains(lower, "(c)") && !strings.Contains(line, "©") {
+		return line
+	}
+	return copyrightYearsPattern.ReplaceAllString(line, "YEAR")
+}
+
+// Words that mark a comment block as license boilerplate.
+var licenseWords = []string{"copyright", "license", "licence", "spdx-license-identifier", "all rights reserved", "(c)"}
+
+// licenseHeaderLen returns how many lines at the start of a file are a license header: the run
+// of comments and blank lines before the first line of code, if any of it mentions a license.
+// Returns 0 if there is no such header.
+func licenseHeaderLen(lines []string, lang *language) int {
+	var isLicense bool
+	var state lexState
+	n := 0
+	for ; n < len(lines); n++ {
+		var comment bool
+		comment, _, state = lexLine(lines[n], lang, state)
+		if !comment && strings.TrimSpace(lines[n]) != "" {
+			break
+		}
+		lower := strings.ToLower(lines[n])
+		for _, word := range licenseWords {
+			if strings.Contains(lower, word) {
+				isLicense = true
+			}
+		}
+	}
+	if !isLicense {
+		return 0
+	}
+	return n
+}
+
+// Names of the files that say what license a directory's contents are under.
+var licenseFileNames = []string{"LICENSE", "LICENSE.txt", "LICENSE.md", "LICENCE", "COPYING", "COPYING.txt"}
+
+// Phrases by which to recognize common license texts that don't have an SPDX identifier in them.
+// More specific licenses come before the ones whose phrases they share.
+var licenseTexts = []struct {
+	id      string
+	phrases []string
+}{
+	{"Apache-2.0", []string{"apache license", "version 2.0"}},
+	{"LGPL-2.1", []string{"gnu lesser general public license", "version 2.1"}},
+	{"LGPL-3.0", []string{"gnu lesser general public license", "version 3"}},
+	{"AGPL-3.0", []string{"gnu affero general public license", "version 3"}},
+	{"GPL-2.0", []string{"gnu general public license", "version 2"}},
+	{"GPL-3.0", []string{"gnu general public license", "version 3"}},
+	{"MPL-2.0", []string{"mozilla public license", "2.0"}},
+	{"BSD-3-Clause", []string{"redistribution and use in source and binary forms", "neither the name"}},
+	{"BSD-2-Clause", []string{"redistribution and use in source and binary forms"}},
+	{"MIT", []string{"permission is hereby granted, free of charge"}},
+	{"ISC", []string{"permission to use, copy, modify, and/or distribute this software for any purpose"}},
+	{"Unlicense", []string{"this is free and unencumbered software released into the public domain"}},
+}
+
+// spdxIdentifier returns the license named by the first SPDX-License-Identifier tag in text.
+func spdxIdentifier(text string) (string, bool) {
+	const tag = "SPDX-License-Identifier:"
+	i := strings.Index(text, tag)
+	if i < 0 {
+		return "", false
+	}
+	id, _, _ := strings.Cut(text[i+len(tag):], "\n")
+	id = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(id), "*/"))
+	return id, id != ""
+}
+
+// identifyLicense works out which license a license file contains.
+func identifyLicense(text string) string {
+	if id, ok := spdxIdentifier(text); ok {
+		return id
+	}
+	lower := strings.Join(strings.Fields(strings.ToLower(text)), " ")
+	for _, l := range licenseTexts {
+		found := true
+		for _, phrase := range l.phrases {
+			if !strings.Contains(lower, phrase) {
+				found = false
+				break
+			}
+		}
+		if found {
+			return l.id
+		}
+	}
+	return "unrecognized"
+}
+
+// licenseFinder finds the licenses of source files, remembering what it found in each directory.
+type licenseFinder struct {
+	// Maps a directory to the license of the nearest license file at or above it, or "".
+	dirs map[string]string
+}
+
+func newLicenseFinder() *licenseFinder {
+	return &licenseFinder{dirs: make(map[string]string)}
+}
+
+// licenseOf returns the license of a file in the given repo: the file's own SPDX tag if it has
+// one, or else that of the nearest license file in its directory or those above it, up to the
+// root of the repo. Returns "" if neither can be found.
+func (f *licenseFinder) licenseOf(root, path string) string {
+	if contents, err := os.ReadFile(path); err == nil {
+		if id, ok := spdxIdentifier(string(contents)); ok {
+			return id
+		}
+	}
+	return f.dirLicense(filepath.Clean(root), filepath.Dir(path))
+}
+
+func (f *licenseFinder) dirLicense(root, dir string) string {
+	if license, ok := f.dirs[dir]; ok {
+		return license
+	}
+	license := ""
+	for _, name := range licenseFileNames {
+		if contents, err := os.ReadFile(filepath.Join(dir, name)); err == nil {
+			license = identifyLicense(string(contents))
+			break
+		}
+	}
+	if parent := filepath.Dir(dir); license == "" && dir != root && parent != dir && strings.HasPrefix(dir, root) {
+		license = f.dirLicense(root, parent)
+	}
+	f.dirs[dir] = license
+	return license
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// stringLiterals returns the sorted, distinct string literals in normalized code, without their
+// quotes. Single-quoted literals of one character are left out, since in C they are characters,
+// which say nothing about where code came from.
+func stringLiterals(code string) []string {
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(code, "\n") {
+		for i := 0; i < len(line); i++ {
+			quote := line[i]
+			if quote != '"' && quote != '\'' && quote != '`' {
+				continue
+			}
+			end := i + 1
+			for end < len(line) && line[end] != quote {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(line) {
+				// Unterminated on this line, like an apostrophe in a shell script; not a literal.
+				continue
+			}
+			literal := line[i+1 : end]
+			if literal != "" && !(quote == '\'' && len([]rune(strings.TrimPrefix(literal, "\\"))) == 1) {
+				seen[literal] = true
+			}
+			i = end
+		}
+	}
+
+	literals := make([]string, 0, len(seen))
+	for literal := range seen {
+		literals = append(literals, literal)
+	}
+	sort.Strings(literals)
+	return literals
+}
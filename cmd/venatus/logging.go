@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	quiet     = flag.Bool("quiet", false, "only log warnings and errors")
+	verbose   = flag.Bool("verbose", false, "also log debugging detail, such as every match as it is made")
+	logFormat = flag.String("log-format", "text", "format of log messages on stderr: \"text\" or \"json\"")
+)
+
+// setUpLogging directs log messages to stderr, at the level and in the format given by the flags.
+// Only the report itself is written to stdout.
+func setUpLogging() error {
+	if *quiet && *verbose {
+		return fmt.Errorf("--quiet and --verbose are mutually exclusive")
+	}
+	level := slog.LevelInfo
+	if *quiet {
+		level = slog.LevelWarn
+	}
+	if *verbose {
+		level = slog.LevelDebug
+	}
+	var h slog.Handler
+	switch *logFormat {
+	case "text":
+		h = &plainHandler{level: level, w: os.Stderr, mu: new(sync.Mutex)}
+	case "json":
+		h = slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level})
+	default:
+		return fmt.Errorf("unknown --log-format %q (want \"text\" or \"json\")", *logFormat)
+	}
+	slog.SetDefault(slog.New(h))
+	return nil
+}
+
+// showProgress reports whether to draw progress bars, which only make sense for a human watching.
+func showProgress() bool {
+	return !*quiet && *logFormat == "text"
+}
+
+// plainHandler writes log messages for humans: just the message, followed by any attributes.
+type plainHandler struct {
+	level slog.Leveler
+	w     io.Writer
+	mu    *sync.Mutex
+	attrs []slog.Attr
+}
+
+func (h *plainHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *plainHandler) Handle(_ context.Context, r slog.Record) error {
+	var sb strings.Builder
+	if r.Level >= slog.LevelWarn {
+		sb.WriteString(r.Level.String())
+		sb.WriteString(": ")
+	}
+	sb.WriteString(r.Message)
+	write := func(a slog.Attr) bool {
+		fmt.Fprintf(&sb, " %s=%v", a.Key, a.Value)
+		return true
+	}
+	for _, a := range h.attrs {
+		write(a)
+	}
+	r.Attrs(write)
+	sb.WriteByte('\n')
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, sb.String())
+	return err
+}
+
+func (h *plainHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.attrs = append(h.attrs[:len(h.attrs):len(h.attrs)], attrs...)
+	return &h2
+}
+
+// Groups aren't worth the trouble for human consumption.
+func (h *plainHandler) WithGroup(string) slog.Handler {
+	return h
+}
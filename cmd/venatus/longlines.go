@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+var maxLineLength = flag.Int("max-line-length", 1000, "split lines of code longer than this many bytes, as in minified or amalgamated files, into pieces at token boundaries, so that they are diffed piece by piece rather than as one huge line (0 to never split)")
+
+func checkMaxLineLength() error {
+	if *maxLineLength < 0 {
+		return fmt.Errorf("--max-line-length must not be negative")
+	}
+	return nil
+}
+
+// splitLongLines splits text into lines, however long they are, dropping the \r of \r\n as
+// bufio.Scanner does.
+func splitLongLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return lines
+}
+
+// chunkLine splits a line of code longer than --max-line-length into pieces no longer than that,
+// breaking after a statement, brace, or comma where possible, or else at a space. A piece with
+// nowhere to break is left as long as it has to be.
+func chunkLine(line string) []string {
+	if *maxLineLength == 0 || len(line) <= *maxLineLength {
+		return []string{line}
+	}
+	var pieces []string
+	for len(line) > *maxLineLength {
+		window := line[:*maxLineLength]
+		cut := strings.LastIndexAny(window, ";{},") + 1
+		if cut == 0 {
+			cut = strings.LastIndexByte(window, ' ') + 1
+		}
+		if cut == 0 {
+			// A single huge token: break at the next boundary after it.
+			next := strings.IndexAny(line[*maxLineLength:], ";{}, ")
+			if next < 0 {
+				break
+			}
+			cut = *maxLineLength + next + 1
+		}
+		pieces = append(pieces, strings.TrimSpace(line[:cut]))
+		line = line[cut:]
+	}
+	if line = strings.TrimSpace(line); line != "" {
+		pieces = append(pieces, line)
+	}
+	return pieces
+}
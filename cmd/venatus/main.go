@@ -1,41 +1,58 @@
 package main
 
 import (
-	"bufio"
 	"errors"
 	"flag"
 	"fmt"
 	"io/fs"
+	"log/slog"
 	"os"
+	"os/exec"
+	"path"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/jedib0t/go-pretty/v6/table"
-	"github.com/jedib0t/go-pretty/v6/text"
 	"github.com/schollz/progressbar/v3"
 	"github.com/sergi/go-diff/diffmatchpatch"
 	"golang.org/x/sync/errgroup"
 )
 
 var (
-	source = flag.String("source", "", "path to source repo")
 	target = flag.String("target", "", "path to target repo")
+	self = flag.String("self", "", "path to a repo to find files duplicated within, instead of comparing two repos")
 	skip = flag.String("skip", "", "comma-separated files to skip")
+	workers = flag.Int("workers", runtime.NumCPU(), "number of target files to compare at once")
+	readers = flag.Int("readers", 16, "number of files to read at once while walking each repo")
+	maxFileSize = flag.Int64("max-file-size", 4<<20, "skip code files larger than this many bytes, like generated tables and amalgamations, listing them after the results (0 for no limit)")
+	chunkSize = flag.Int("chunk-size", 1<<20, "compare files larger than this many bytes in line-aligned sections, to bound memory use")
+	conflictMargin = flag.Float64("conflict-margin", 0.005, "report a conflict when other candidates score within this much of the best match")
+	patchDir = flag.String("emit-patches", "", "directory to write patches re-syncing each target file to its match into")
+	indexOut = flag.String("write-index", "", "file to write an index of the source repo to, for reuse as --source")
+	cacheDir = flag.String("cache-dir", "", "directory to cache comparison results and normalized files in between runs")
+	remoteCacheURL = flag.String("remote-cache", "", "http(s):// or s3:// URL of a comparison result cache shared between machines")
+	groupBy = flag.String("group-by", "", "set to \"dir\" to group results under their directories, with subtotals")
+	rollupDepth = flag.Int("rollup-depth", 1, "how many levels of target directory to summarize scores by after the results (0 for no summary)")
+	showDiff = flag.String("show-diff", "", "target file whose diff against its best match should be printed after the results")
+	// Tuning: This is set so that we don't spend too long comparing very dissimilar files.
+	// If files that are supposed to be alike are not getting scored highly, try increasing this.
+	diffTimeout = flag.Duration("diff-timeout", 4*time.Second, "how long to spend diffing any one pair of files before settling for a rougher diff")
+	deterministic = flag.Bool("deterministic", false, "never cut diffs short (ignoring --diff-timeout), so that scores don't depend on how busy the machine is; slower, but reports can be diffed run to run")
+	editCost = flag.Int("edit-cost", 0, "if nonzero, fold matching runs shorter than about this many characters into the surrounding edits before scoring")
 	dmp = &diffmatchpatch.DiffMatchPatch{
-		// Tuning: This variable is set so that we don't spend too long comparing very dissimilar files.
-		// If files that are supposed to be alike are not getting scored highly, try increasing this.
-		DiffTimeout:          4 * time.Second,
-		DiffEditCost:         4,
 		MatchThreshold:       0.5,
 		MatchDistance:        1000,
 		PatchDeleteThreshold: 0.5,
 		PatchMargin:          4,
 		MatchMaxBits:         32,		
 	}
+	pathWeight = flag.Float64("path-weight", 0.02, "how much to favor candidates in similarly-named directories when choosing a match")
 	// Don't bother comparing files whose basenames are more than this different.
-	filenameSimilarityThreshold = 0.5
+	filenameSimilarityThreshold = flag.Float64("filename-threshold", 0.5, "only compare files whose basenames are more similar than this (0 to 1)")
 )
 
 func main() {
@@ -46,116 +63,725 @@ func main() {
 }
 
 func mainErr() error {
-	flag.Parse()
-	if *source == "" {
+	args := os.Args[1:]
+	cmd := lookupCommand("compare")
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		if cmd = lookupCommand(args[0]); cmd == nil {
+			return fmt.Errorf("unknown command %q; run \"venatus help\" for the list", args[0])
+		}
+		args = args[1:]
+	}
+	if err := parseFlags(cmd, args); errors.Is(err, flag.ErrHelp) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return cmd.run(cmd.flagSet().Args())
+}
+
+// runComparison compares --target against --source, once the flags have been parsed and set up, and
+// reports on it.
+func runComparison() error {
+	start := time.Now()
+	stopProfiling, err := startProfiling()
+	if err != nil {
+		return err
+	}
+	if err := checkRepoFlags(); err != nil {
+		return err
+	}
+
+	c, err := compare(sources, *target)
+	if err != nil {
+		return err
+	}
+	if c.plan != nil {
+		printPlan(c, c.plan)
+		printTiming(time.Since(start))
+		return stopProfiling()
+	}
+	if *indexOut != "" {
+		if err := saveIndex(*indexOut, c.sources[0].root, c.sourceFiles); err != nil {
+			return err
+		}
+	}
+
+	if *outputFormat == "jsonl" {
+		printSummaryLine(c)
+	} else if reportTemplate != nil {
+		if err := printTemplate(c); err != nil {
+			return fmt.Errorf("--template: %w", err)
+		}
+	} else {
+		printReport(c)
+	}
+
+	if *dumpDir != "" {
+		n, err := dumpNormalized(*dumpDir, c)
+		if err != nil {
+			return err
+		}
+		slog.Info("Wrote normalized files", "count", n, "dir", *dumpDir)
+	}
+
+	if *alignmentsPath != "" {
+		if err := writeAlignments(*alignmentsPath, c); err != nil {
+			return err
+		}
+		slog.Info("Wrote alignments", "path", *alignmentsPath)
+	}
+
+	if *redactPath != "" {
+		if err := writeRedacted(*redactPath, c); err != nil {
+			return err
+		}
+		slog.Info("Wrote redacted report", "path", *redactPath)
+	}
+
+	if *badgePath != "" {
+		if err := writeBadge(*badgePath, c); err != nil {
+			return err
+		}
+		slog.Info("Wrote badge", "path", *badgePath)
+	}
+
+	if *dotPath != "" {
+		if err := writeDot(*dotPath, c); err != nil {
+			return err
+		}
+		slog.Info("Wrote mapping graph", "path", *dotPath)
+	}
+
+	if *xlsxPath != "" {
+		if err := writeXLSX(*xlsxPath, c); err != nil {
+			return err
+		}
+		slog.Info("Wrote workbook", "path", *xlsxPath)
+	}
+
+	if *junitPath != "" {
+		if err := writeJUnit(*junitPath, c); err != nil {
+			return err
+		}
+		slog.Info("Wrote JUnit report", "path", *junitPath)
+	}
+
+	if *patchDir != "" {
+		n, err := emitPatches(*patchDir, c)
+		if err != nil {
+			return err
+		}
+		slog.Info("Wrote patches", "count", n, "dir", *patchDir)
+	}
+
+	if *mergeDir != "" {
+		if err := writeMerges(c); err != nil {
+			return err
+		}
+	}
+
+	if *showDiff != "" {
+		fmt.Println()
+		d, err := c.diff(*showDiff)
+		if err != nil {
+			return err
+		}
+		fmt.Print(d)
+	}
+
+	if err := report(c); err != nil {
+		return err
+	}
+
+	printTiming(time.Since(start))
+	return stopProfiling()
+}
+
+// printReport prints the results table, and the other sections of the report that apply.
+func printReport(c *comparison) {
+	fmt.Print(renderTable(c))
+	if newFiles := c.newFiles(); len(newFiles) > 0 {
+		fmt.Print("\n\n", renderNewFiles(c, newFiles))
+	}
+	if len(c.binaryResults) > 0 {
+		fmt.Print("\n\n", renderBinaryTable(c))
+	}
+	if *rollupDepth > 0 {
+		if dirs := c.rollup(*rollupDepth); len(dirs) > 1 {
+			fmt.Print("\n\n", renderRollup(dirs))
+		}
+	}
+	if len(histogramCuts) > 0 && len(c.results) > 0 {
+		fmt.Print("\n\n", renderHistogram(c))
+	}
+
+	printConflicts(c)
+	printMapDisagreements(c)
+	printVersus(c)
+	printRenames(c)
+	printCopiedDirs(c)
+	printLayout(c)
+	printIncludes(c)
+	printSnippets(c)
+	printThirdParty(c)
+	printFunctions(c)
+	printEmbedded(c)
+	printRegions(c)
+	printDrift(c)
+	printSemantic(c)
+	printUpstream(c)
+	printLicenseFiles(c)
+	printCollisions(c)
+	printReadIssues(c)
+	printErrors(c)
+	printApproximate(c)
+	printTimedOut(c)
+	printEstimate(c)
+	printAnnotations(c)
+}
+
+// setUp validates the flags shared by all modes and sets up what they describe.
+func setUp() error {
+	if err := applyConfig(); err != nil {
+		return err
+	}
+	if err := setUpLogging(); err != nil {
+		return err
+	}
+	dmp.DiffTimeout = *diffTimeout
+	if *deterministic {
+		dmp.DiffTimeout = 0
+	}
+	dmp.DiffEditCost = *editCost
+	if *filenameSimilarityThreshold < 0 || *filenameSimilarityThreshold > 1 {
+		return fmt.Errorf("--filename-threshold must be between 0 and 1")
+	}
+	if *fingerprintThreshold < 0 || *fingerprintThreshold > 1 {
+		return fmt.Errorf("--fingerprint-threshold must be between 0 and 1")
+	}
+	if *dirCopyThreshold < 0 {
+		return fmt.Errorf("--dir-copy-threshold must not be negative")
+	}
+	if *functionThreshold < 0 || *functionThreshold > 1 {
+		return fmt.Errorf("--function-threshold must be between 0 and 1")
+	}
+	if *weightBy != "lines" && *weightBy != "tokens" && *weightBy != "bytes" && *weightBy != "complexity" {
+		return fmt.Errorf("unknown --weight-by %q (want lines, tokens, bytes, or complexity)", *weightBy)
+	}
+	if *groupBy != "" && *groupBy != "dir" {
+		return fmt.Errorf("unknown --group-by %q (want \"dir\")", *groupBy)
+	}
+	if err := checkGenerated(); err != nil {
+		return err
+	}
+	if err := checkColors(); err != nil {
+		return err
+	}
+	if err := checkTop(); err != nil {
+		return err
+	}
+	if err := checkJUnit(); err != nil {
+		return err
+	}
+	if err := checkColumns(); err != nil {
+		return err
+	}
+	if err := checkSortOrder(); err != nil {
+		return err
+	}
+	if err := checkScoreRange(); err != nil {
+		return err
+	}
+	if err := checkCalibration(); err != nil {
+		return err
+	}
+	if err := checkSimhash(); err != nil {
+		return err
+	}
+	if err := checkTokenOverlap(); err != nil {
+		return err
+	}
+	if err := checkAlgorithm(); err != nil {
+		return err
+	}
+	if err := checkCompareMode(); err != nil {
+		return err
+	}
+	if err := checkNormalizers(); err != nil {
+		return err
+	}
+	if err := checkComparator(); err != nil {
+		return err
+	}
+	if err := checkEmbeddings(); err != nil {
+		return err
+	}
+	if err := checkHistogram(); err != nil {
+		return err
+	}
+	if err := checkAnnotations(); err != nil {
+		return err
+	}
+	if err := checkNotify(); err != nil {
+		return err
+	}
+	if err := checkIncludes(); err != nil {
+		return err
+	}
+	if err := checkMapPrecedence(); err != nil {
+		return err
+	}
+	if err := checkAsmDialect(); err != nil {
+		return err
+	}
+	if err := checkExtraLanguages(); err != nil {
+		return err
+	}
+	if err := checkEquivalentExtensions(); err != nil {
+		return err
+	}
+	if err := checkFormat(); err != nil {
+		return err
+	}
+	if err := checkTemplate(); err != nil {
+		return err
+	}
+	if err := checkMaxLineLength(); err != nil {
+		return err
+	}
+	if err := checkBudget(); err != nil {
+		return err
+	}
+	if err := checkRetryTimeout(); err != nil {
+		return err
+	}
+	if err := checkSample(); err != nil {
+		return err
+	}
+	if err := checkMatchDirs(); err != nil {
+		return err
+	}
+	if err := checkIncludeHints(); err != nil {
+		return err
+	}
+	if err := checkMemoryBudget(); err != nil {
+		return err
+	}
+	if err := checkNice(); err != nil {
+		return err
+	}
+	if err := checkContainment(); err != nil {
+		return err
+	}
+	if err := checkMerge(); err != nil {
+		return err
+	}
+	if err := checkPatches(); err != nil {
+		return err
+	}
+	if err := compileIgnorePatterns(); err != nil {
+		return err
+	}
+	if *preprocess {
+		if _, err := exec.LookPath(*cppCommand); err != nil {
+			return fmt.Errorf("--preprocess: %w", err)
+		}
+	}
+	var err error
+	pairs, err = openPairCache(*cacheDir, *remoteCacheURL)
+	return err
+}
+
+func checkRepoFlags() error {
+	if *self != "" {
+		if len(sources) > 0 || *target != "" {
+			return errors.New("--self can't be used with --source or --target")
+		}
+		sources = repoList{*self}
+		*target = *self
+	}
+	if len(sources) == 0 {
 		return errors.New("--source not specified")
 	}
+	if *versus && len(sources) != 2 {
+		return errors.New("--versus needs exactly two --source repos")
+	}
+	if len(sources) > 1 && *indexOut != "" {
+		return errors.New("--write-index needs a single --source")
+	}
 	if *target == "" {
 		return errors.New("--target not specified")
 	}
+	return nil
+}
+
+// comparison is the outcome of comparing a target repo against a source repo.
+type comparison struct {
+	sources                  []*sourceRepo
+	targetRoot               string
+	sourceFiles, targetFiles map[string]string
+	// Sorted per --sort.
+	results        []*findResult
+	totalLineCount int
+	// Weighted per --weight-by.
+	overallScore float64
+	// How the comparison was run, for reports.
+	metadata *runMetadata
+	// Weighted by line count, whatever --weight-by says.
+	lineScore float64
+	// Groups of relative paths that differ only by case.
+	sourceCollisions, targetCollisions [][]string
+	sourceReport, targetReport         *walkReport
+	// Best matches for binary target files, with --binaries.
+	binaryResults []*binaryResult
+	// Fingerprints of each source file, if the filename filter is off.
+	sourcePrints map[string]fingerprint
+	// SimHashes of each source file, with --max-simhash-distance.
+	sourceSimhashes map[string]uint64
+	// Bloom filters of each source file's tokens, with --min-token-overlap.
+	sourceBlooms map[string]*bloomFilter
+	// What is compared of each source file, if it isn't the code itself.
+	sourceFeatures map[string]fingerprint
+	// Source files that target files are pinned to with --map.
+	pinned map[string]string
+	// Line numbers of the code in each file, read as needed with --snippets.
+	lineNumbers map[string][]int
+	// Source directories matched with target directories, and the source files in each
+	// directory, with --match-dirs.
+	dirMatches  map[string]string
+	sourceByDir map[string]map[string]string
+	// Where the target headers' matches are, with --include-hints.
+	headers headerHints
+	// The licenses of the --known-snippets found, by path.
+	snippetLicenses map[string]string
+	// The license files of the repos, with --license-files.
+	licenseFiles []*licenseFile
+	// How many target files there were to --sample from.
+	population int
+	// When comparing started, and how many pairs have been compared, for --budget and
+	// --max-comparisons.
+	compareStart time.Time
+	comparisons  atomic.Int64
+	// Target files that couldn't be compared, with --keep-going.
+	failed failures
+	// The progress bar shown while comparing, cleared to make way for --live rows.
+	progress *progressbar.ProgressBar
+	// Which files have the same contents, so that each pair of contents is only compared once.
+	dupes *duplicates
+	// The work planned, with --dry-run, which compares nothing.
+	plan *plan
+	// The files whose contents have been released, to be read again if needed.
+	released  map[string]bool
+	releaseMu sync.Mutex
+}
+
+// compare finds the best match in the source repo for each code file in the target repo.
+func compare(sourceRoots []string, targetRoot string) (*comparison, error) {
+	c := &comparison{
+		targetRoot:   targetRoot,
+		sourceFiles:  make(map[string]string),
+		sourceReport: newWalkReport(),
+		released:     make(map[string]bool),
+	}
+	names := nameSources(sourceRoots)
+	for i, sourceRoot := range sourceRoots {
+		if isPackage(sourceRoot) {
+			dir, err := fetchPackage(sourceRoot)
+			if err != nil {
+				return nil, err
+			}
+			sourceRoot = dir
+		}
+		c.sources = append(c.sources, &sourceRepo{name: names[i], root: sourceRoot})
+	}
+	c.metadata = c.newRunMetadata()
 
-	fmt.Println("Opening code files...")
-	sourceFiles := openAllCodeFiles(*source)
-	targetFiles := openAllCodeFiles(*target)
+	slog.Info("Opening code files...")
+	walkStart := time.Now()
+	// Walk all the repos at once.
+	sourceFiles := make([]map[string]string, len(c.sources))
+	sourceReports := make([]*walkReport, len(c.sources))
+	var targetFiles map[string]string
+	var targetReport *walkReport
+	pool := newContentsPool()
+	var walks errgroup.Group
+	for i, s := range c.sources {
+		i, s := i, s
+		walks.Go(func() error {
+			var err error
+			sourceFiles[i], sourceReports[i], err = openSource(s, pool)
+			return err
+		})
+	}
+	walks.Go(func() error {
+		if listedTargets != nil {
+			targetFiles, targetReport = openListedFiles(targetRoot, listedTargets, pool)
+			return nil
+		}
+		if *filesFrom != "" {
+			paths, err := readFileList(*filesFrom, targetRoot)
+			if err != nil {
+				return fmt.Errorf("--files-from: %w", err)
+			}
+			targetFiles, targetReport = openListedFiles(targetRoot, paths, pool)
+			return nil
+		}
+		targetFiles, targetReport = openAllCodeFiles(dirTree(targetRoot), pool)
+		return nil
+	})
+	err := walks.Wait()
+	if err != nil {
+		return nil, err
+	}
+	for i, s := range c.sources {
+		for path, contents := range sourceFiles[i] {
+			c.sourceFiles[path] = contents
+		}
+		c.sourceReport.merge(sourceReports[i])
+		c.sourceCollisions = append(c.sourceCollisions, caseCollisions(s.root, sourceFiles[i])...)
+	}
 	skippedFiles := strings.Split(*skip, ",")
 	for file := range targetFiles {
 		for _, skippedFile := range skippedFiles {
 			if strings.EqualFold(filepath.Base(file), skippedFile) {
-				fmt.Printf("Skipping target file %q\n", file)
+				slog.Info("Skipping target file", "path", file)
 				delete(targetFiles, file)
 				break
 			}
 		}
 	}
+	targetFiles = c.sample(targetFiles)
+	c.targetFiles = targetFiles
+	timeSince(walking, walkStart)
+	c.targetReport = targetReport
+	c.targetCollisions = caseCollisions(targetRoot, targetFiles)
+	logCollisions("source", c.sourceCollisions)
+	logCollisions("target", c.targetCollisions)
+	c.findDuplicates()
+	if *noFilenameFilter {
+		c.sourcePrints = make(map[string]fingerprint, len(c.sourceFiles))
+		for path, contents := range c.sourceFiles {
+			c.sourcePrints[path] = fingerprintOf(contents)
+		}
+	}
+	if filtersBySimhash() {
+		c.sourceSimhashes = make(map[string]uint64, len(c.sourceFiles))
+		for _, s := range c.sources {
+			for path, hash := range s.simhashes {
+				c.sourceSimhashes[path] = hash
+			}
+		}
+		for path, contents := range c.sourceFiles {
+			if _, ok := c.sourceSimhashes[path]; !ok {
+				c.sourceSimhashes[path] = simhash(contents)
+			}
+		}
+	}
+	if filtersByTokens() {
+		c.sourceBlooms = make(map[string]*bloomFilter, len(c.sourceFiles))
+		for path, contents := range c.sourceFiles {
+			c.sourceBlooms[path] = bloomOf(contents)
+		}
+	}
+	if !comparesCode() {
+		c.sourceFeatures = make(map[string]fingerprint, len(c.sourceFiles))
+		for path, contents := range c.sourceFiles {
+			c.sourceFeatures[path] = features(contents)
+		}
+	}
+
+	if *matchDirs {
+		c.matchDirectories()
+	}
+	if err := c.pinMappings(); err != nil {
+		return nil, err
+	}
+	if *dryRun {
+		c.plan = c.planComparisons()
+		return c, nil
+	}
+	cp, err := openCheckpoint(c.sourceFiles, targetFiles)
+	if err != nil {
+		return nil, err
+	}
+	defer cp.close()
 	results := make(chan *findResult, len(targetFiles))
 
-	fmt.Println("Comparing code files...")
+	slog.Info("Comparing code files...")
 	pb := progressbar.NewOptions(len(targetFiles),
+	progressbar.OptionSetWriter(os.Stderr),
+	progressbar.OptionSetVisibility(showProgress()),
 	progressbar.OptionEnableColorCodes(true),
 	progressbar.OptionFullWidth(),
 	progressbar.OptionClearOnFinish())
-	var errs errgroup.Group
-	for path, fileContents := range targetFiles {
-		path := path
-		fileContents := fileContents
-		errs.Go(func() error {
-			result, err := findBestCandidate(path, fileContents, sourceFiles)
-			if err != nil {
-				return err
+	c.progress = pb
+	compareStart := time.Now()
+	c.compareStart = compareStart
+	gate := newMemoryGate()
+	for _, phase := range c.phases(targetFiles) {
+		var errs errgroup.Group
+		errs.SetLimit(max(*workers, 1))
+		for _, path := range phase {
+			fileContents := c.targetContents(path)
+			if result, ok := cp.resumed(path, fileContents); ok {
+				c.releaseTarget(path)
+				c.noteHeaderMatch(result)
+				c.stream(result)
+				results <- result
+				pb.Add(1)
+				continue
 			}
-			results <- result
-			pb.Add(1)
-			return nil
-		})
+			path := path
+			errs.Go(func() error {
+				release := gate.acquire(fileContents)
+				result, err := c.compareFile(path, fileContents)
+				release()
+				c.releaseTarget(path)
+				if err != nil {
+					pb.Add(1)
+					return c.failed.add(path, err)
+				}
+				// Approximate scores aren't kept, so that resuming with more budget compares them fully.
+				if !result.approximate {
+					cp.save(result, fileContents)
+				}
+				c.noteHeaderMatch(result)
+				c.stream(result)
+				results <- result
+				pb.Add(1)
+				return nil
+			})
+		}
+		if err = errs.Wait(); err != nil {
+			break
+		}
 	}
-	err := errs.Wait()
 	pb.Finish()
+	timeSince(comparing, compareStart)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	cp.finish()
 	close(results)
 
-	totalLineCount := 0
-
 	// Read the results into a slice and sort them
-	resultSlice := make([]*findResult, 0, len(targetFiles))
+	c.results = make([]*findResult, 0, len(targetFiles))
 	for result := range results {
-		resultSlice = append(resultSlice, result)
-		totalLineCount += result.lineCount
+		c.results = append(c.results, result)
+		c.totalLineCount += result.lineCount
 	}
-	sort.Slice(resultSlice, func (i, j int) bool {
-		return resultSlice[i].lineCount > resultSlice[j].lineCount
-		// return strings.Compare(resultSlice[i].filename, resultSlice[j].filename) < 0
-	})
-
-	overallScore := 0.0
-
-	for _, result := range resultSlice {
-		overallScore += result.matchSimilarity * (float64(result.lineCount) / float64(totalLineCount))
+	if *retryTimeout > 0 && comparesCode() {
+		c.retryTimedOut()
 	}
-
-	// Tabularize the results real nice
-	tw := table.NewWriter()
-	tw.SetStyle(table.StyleDouble)
-	prefix := greatestCommonPrefix(*source, *target)
-	tw.AppendHeader(table.Row{
-		fmt.Sprintf("Path in %s", strings.TrimPrefix(*target, prefix)),
-		fmt.Sprintf("Best match from %s", strings.TrimPrefix(*source, prefix)),
-		"Score",
-		"LoC",
-	})
-	for _, result := range resultSlice {
-		tw.AppendRow(table.Row{
-			strings.TrimPrefix(strings.TrimPrefix(result.filename, *target), "/"),
-			strings.TrimPrefix(strings.TrimPrefix(result.matchedFilename, *source), "/"),
-			percentage(result.matchSimilarity),
-			result.lineCount,
-		})
+	if *compareBinaries {
+		c.matchBinaries()
 	}
-	tw.AppendFooter(table.Row{
-			"Total",
-			"",
-			percentage(overallScore),
-			totalLineCount,
-	})
-	tw.SetRowPainter(func(row table.Row) text.Colors {
-		pct := row[2].(percentage)
-		if pct > 0.9 {
-			return text.Colors{text.FgGreen}
+	if *findSnippets {
+		c.matchSnippets()
+	}
+	if *knownSnippets != "" {
+		if err := c.matchKnownSnippets(); err != nil {
+			return nil, err
 		}
-		if pct > 0.8 {
-			return text.Colors{text.FgHiGreen}
+	}
+	if *findUpstream {
+		c.matchUpstream()
+	}
+	if *matchFunctions {
+		c.matchAllFunctions()
+	}
+	if *findEmbedded {
+		c.matchEmbedded()
+	}
+	if *bestRegions {
+		c.matchRegions()
+	}
+	if *driftRef != "" {
+		c.matchDrift()
+	}
+	if *embeddingsBackend != "" && *algorithmName != "embedding" {
+		c.matchSemantic()
+	}
+	licenses := newLicenseFinder()
+	for _, result := range c.results {
+		if s := c.sourceOf(result.matchedFilename); s != nil {
+			result.license = licenses.licenseOf(s.root, result.matchedFilename)
 		}
-		if pct > 0.6 {
-			return text.Colors{text.FgHiYellow}
+	}
+	if *compareLicenseFiles {
+		c.matchLicenseFiles()
+	}
+	sortResults(c.results)
+
+	scored := c.results
+	if *excludeNew {
+		scored = nil
+		for _, result := range c.results {
+			if !isNew(result) {
+				scored = append(scored, result)
+			}
 		}
-		return text.Colors{text.FgWhite}
-	})
-	fmt.Print(tw.Render())
+	}
+	scoredLines := 0
+	for _, result := range scored {
+		scoredLines += result.lineCount
+	}
+	if scoredLines > 0 {
+		for _, result := range scored {
+			c.lineScore += result.matchSimilarity * (float64(result.lineCount) / float64(scoredLines))
+		}
+	}
+	totalWeight := 0.0
+	for _, result := range scored {
+		totalWeight += result.weight()
+	}
+	if totalWeight > 0 {
+		for _, result := range scored {
+			c.overallScore += result.matchSimilarity * (result.weight() / totalWeight)
+		}
+	}
+	c.releaseSources()
+	return c, nil
+}
 
+// relSource returns the given source path relative to the source repo it is in.
+func (c *comparison) relSource(path string) string {
+	if s := c.sourceOf(path); s != nil {
+		return relTo(s.root, path)
+	}
+	return path
+}
+
+// relTarget returns the given target path relative to the target repo.
+func (c *comparison) relTarget(path string) string {
+	return relTo(c.targetRoot, path)
+}
+
+// lookup returns the result for the given target file, which may be given either relative to
+// the target repo or as it was found while walking it.
+func (c *comparison) lookup(path string) *findResult {
+	for _, result := range c.results {
+		if result.filename == filepath.Clean(path) || result.filename == filepath.Join(c.targetRoot, path) {
+			return result
+		}
+	}
 	return nil
 }
 
+// diff returns the diff between a target file and its best match, as they were compared
+// (i.e., after normalization).
+func (c *comparison) diff(path string) (string, error) {
+	result := c.lookup(path)
+	if result == nil {
+		return "", fmt.Errorf("%q is not one of the compared target files", path)
+	}
+	if result.matchedFilename == "N/A" {
+		return "", fmt.Errorf("%q has no match to diff against", path)
+	}
+	source, _ := c.sourceContents(result.matchedFilename)
+	return unifiedDiff(c.relSource(result.matchedFilename), c.relTarget(result.filename), source, c.targetContents(result.filename)), nil
+}
+
 type percentage float64
 
 func (p percentage) String() string {
@@ -175,66 +801,477 @@ func greatestCommonPrefix(a, b string) string {
 	return sb.String()
 }
 
+// relTo returns the given path relative to root, which it is assumed to be under.
+func relTo(root, path string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(path, root), "/")
+}
+
 type findResult struct {
 	filename string
 	matchedFilename string
 	matchSimilarity float64
+	// How sure we are that the match is the right one, from 0 to 1.
+	confidence float64
+	// The license the matched source file is under, if known.
+	license string
 	lineCount int
+	// Sizes of the normalized file, for --weight-by.
+	tokenCount, byteCount int
+	// How much logic the normalized file has, for --weight-by=complexity.
+	complexity int
+	// Other candidates that scored (nearly) as well as the chosen match, if any.
+	conflicts []candidate
+	// Where the file's --map pin and automatic matching disagree, with --map-precedence best or
+	// warn, the one that isn't the match.
+	overruled *candidate
+	// Whether diffing the matched file took too long, so its score is only an estimate.
+	timedOut bool
+	// Whether the match was scored from fingerprints, once --budget was spent.
+	approximate bool
+	// Regions copied from source files, with --snippets.
+	snippets []snippet
+	// Regions copied from --known-snippets.
+	thirdParty []snippet
+	// The revision of the matched file this is most like, with --find-upstream-commit.
+	upstream *upstreamRevision
+	// The best score against each source repo, in order, with --versus.
+	bySource []float64
+	// How well each function matches those of the matched file, with --functions.
+	functions []functionMatch
+	// Source files contained in this one, with --find-embedded.
+	embedded []embeddedCopy
+	// The region that best matches the matched file, with --best-region.
+	region *snippet
+	// How the file stands relative to a newer upstream, with --drift-ref.
+	drift *drift
+	// How alike the embeddings of the file and its match are, with --embeddings.
+	semantic *float64
+}
+
+type candidate struct {
+	filename   string
+	similarity float64
+}
+
+// printConflicts lists each target file for which more than one candidate was a plausible best match,
+// so that the choice venatus made can be checked by a human.
+func printConflicts(c *comparison) {
+	var conflicted []*findResult
+	for _, result := range c.results {
+		if len(result.conflicts) > 0 {
+			conflicted = append(conflicted, result)
+		}
+	}
+	if len(conflicted) == 0 {
+		return
+	}
+	fmt.Printf("\n\n%d target files had conflicting candidates:\n", len(conflicted))
+	for _, result := range conflicted {
+		fmt.Printf("%s\n", c.relTarget(result.filename))
+		fmt.Printf("  * %s (%v, chosen)\n", c.sourceLabel(result.matchedFilename), percentage(result.matchSimilarity))
+		for _, other := range result.conflicts {
+			fmt.Printf("    %s (%v)\n", c.sourceLabel(other.filename), percentage(other.similarity))
+		}
+	}
 }
 
 func filenamesCloseEnough(name1, name2 string) bool {
-	bname1 := filepath.Base(name1)
-	bname2 := filepath.Base(name2)
+	bname1 := canonicalName(name1)
+	bname2 := canonicalName(name2)
 	d := diff(bname1, bname2)
-	return d.asPercentage() > filenameSimilarityThreshold
+	return d.asPercentage() > *filenameSimilarityThreshold
+}
+
+// dirSimilarity scores how alike the directories of two relative paths are, from 0 to 1, by
+// how many of their innermost directory components they share. For example, drivers/usb/core.c
+// and usb/core.c score 0.5, while drivers/usb/core.c and net/core.c score 0.
+func dirSimilarity(rel1, rel2 string) float64 {
+	dirs := func(rel string) []string {
+		dir := path.Dir(filepath.ToSlash(rel))
+		if dir == "." {
+			return nil
+		}
+		return strings.Split(dir, "/")
+	}
+	dirs1, dirs2 := dirs(rel1), dirs(rel2)
+	longest := max(len(dirs1), len(dirs2))
+	if longest == 0 {
+		return 1
+	}
+	shared := 0
+	for shared < len(dirs1) && shared < len(dirs2) && dirs1[len(dirs1)-1-shared] == dirs2[len(dirs2)-1-shared] {
+		shared++
+	}
+	return float64(shared) / float64(longest)
+}
+
+// findBestCandidate finds the source file most similar to the given target file.
+// Candidates are ranked by their content similarity plus a bonus, of up to --path-weight, for
+// living in similarly-named directories, so that e.g. drivers/usb/core.c prefers
+// drivers/usb/core.c over net/core.c when both are about as similar.
+// With --match-dirs, the source directory matched with the file's is searched first.
+// With --map-precedence best or warn, a file's --map pin and automatic matching are both searched,
+// and where they disagree, the match is chosen per --map-precedence and the other one noted.
+func (c *comparison) findBestCandidate(path, fileContents string) (*findResult, error) {
+	result, err := c.findBestCandidateAmong(path, fileContents, c.candidateSets(path))
+	checkSets := c.crossCheckSets(path)
+	if err != nil || checkSets == nil {
+		return result, err
+	}
+	other, err := c.findBestCandidateAmong(path, fileContents, checkSets)
+	if err != nil || other.matchedFilename == result.matchedFilename || other.matchedFilename == "N/A" {
+		return result, err
+	}
+	// With best, the other search was of the pin, which wins ties.
+	if *mapPrecedence == "best" && other.matchSimilarity >= result.matchSimilarity {
+		result, other = other, result
+	}
+	if *mapPrecedence == "warn" {
+		slog.Warn("Automatic matching disagrees with --map", "target", c.relTarget(path),
+			"pinned", c.sourceLabel(result.matchedFilename), "score", percentage(result.matchSimilarity),
+			"automatic", c.sourceLabel(other.matchedFilename), "automatic score", percentage(other.matchSimilarity))
+	}
+	result.overruled = &candidate{other.matchedFilename, other.matchSimilarity}
+	return result, nil
+}
+
+// findBestCandidateAmong searches each set of source files in turn for a target file's match,
+// until one is found.
+func (c *comparison) findBestCandidateAmong(path, fileContents string, sets []map[string]string) (*findResult, error) {
+	var result *findResult
+	var err error
+	for _, sources := range sets {
+		result, err = c.findBestCandidateIn(path, fileContents, sources)
+		if err != nil || result.matchSimilarity > 0 {
+			break
+		}
+	}
+	return result, err
 }
 
-func findBestCandidate(path, fileContents string, source map[string]string) (*findResult, error) {
+// candidateSets returns the sets of source files to search for a target file's match, one after
+// another until a match is found: just the file it is pinned to with --map (unless
+// --map-precedence is best), or else the source directory matched with its own with
+// --match-dirs, then all of them.
+func (c *comparison) candidateSets(path string) []map[string]string {
+	if pinnedTo, ok := c.pinned[path]; ok && *mapPrecedence != "best" {
+		return []map[string]string{{pinnedTo: c.sourceFiles[pinnedTo]}}
+	}
+	return c.automaticSets(path)
+}
+
+// automaticSets returns the sets of source files automatic matching searches for a target
+// file's match.
+func (c *comparison) automaticSets(path string) []map[string]string {
+	if sources, ok := c.dirCandidates(path); ok {
+		return []map[string]string{sources, c.sourceFiles}
+	}
+	return []map[string]string{c.sourceFiles}
+}
+
+// findBestCandidateIn finds the best match for a target file among the given source files.
+func (c *comparison) findBestCandidateIn(path, fileContents string, sources map[string]string) (*findResult, error) {
 	bestResult := findResult{
 		filename: path,
 		matchedFilename: "N/A",
 		matchSimilarity: 0,
 		lineCount: strings.Count(fileContents, "\n"),
+		tokenCount: tokenCount(fileContents),
+		complexity: complexity(fileContents),
+		byteCount: len(fileContents),
 	}
-	for sourcepath, contents := range source {
-		if !filenamesCloseEnough(path, sourcepath) {
+	rel := c.relTarget(path)
+	bestRank := 0.0
+	bestTimedOut := false
+	bestApproximate := false
+	var candidates []candidate
+	var feats fingerprint
+	// Timed for --timing; adding up locally saves contending with the other workers.
+	var filterTime, diffTime time.Duration
+	defer func() {
+		phaseTimes[filtering].Add(int64(filterTime))
+		phaseTimes[diffing].Add(int64(diffTime))
+	}()
+	filter := c.newCandidateFilter(path, fileContents)
+	print := filter.print
+	if !comparesCode() {
+		feats = features(fileContents)
+	}
+	hintDirs := c.includeHintDirs(fileContents)
+	pinnedTo, pinned := c.pinned[path]
+	scorer := scorerFor(c.targetLanguage(path))
+	for sourcepath, contents := range sources {
+		filterStart := time.Now()
+		closeEnough := filter.closeEnough(sourcepath, contents)
+		filterTime += time.Since(filterStart)
+		if !closeEnough {
 			continue
 		}
-		d := diff(fileContents, contents)
-		thisSimilarity := d.asPercentage()
-		if thisSimilarity > bestResult.matchSimilarity {
+		diffStart := time.Now()
+		var thisSimilarity float64
+		timedOut, approximate := false, false
+		if comparesCode() && c.overBudget() {
+			if print == nil {
+				print = fingerprintOf(fileContents)
+			}
+			thisSimilarity, approximate = roughSimilarity(print, contents), true
+		} else if comparesCode() {
+			score := c.dupes.score(path, sourcepath, func() pairScore {
+				s, t := scorer.similarity(fileContents, contents)
+				return pairScore{s, t}
+			})
+			thisSimilarity, timedOut = score.similarity, score.timedOut
+		} else if len(feats) > 0 || len(c.sourceFeatures[sourcepath]) > 0 {
+			thisSimilarity = jaccard(feats, c.sourceFeatures[sourcepath])
+		}
+		diffTime += time.Since(diffStart)
+		candidates = append(candidates, candidate{sourcepath, thisSimilarity})
+		if thisSimilarity <= 0 {
+			continue
+		}
+		rank := thisSimilarity + *pathWeight*dirSimilarity(rel, c.relSource(sourcepath))
+		if hintDirs[filepath.Dir(sourcepath)] {
+			rank += *includeHintWeight
+		}
+		if rank > bestRank || rank == bestRank && c.winsTie(rel, sourcepath, bestResult.matchedFilename) {
+			bestRank = rank
 			bestResult.matchSimilarity = thisSimilarity
 			bestResult.matchedFilename = sourcepath
+			bestTimedOut = timedOut
+			bestApproximate = approximate
+		}
+	}
+	bestResult.timedOut = bestTimedOut
+	bestResult.approximate = bestApproximate
+	bestResult.confidence = bestResult.confidenceAmong(candidates, bestTimedOut || bestApproximate)
+	if pinned && bestResult.matchedFilename == pinnedTo {
+		// Someone has said this is the right match.
+		bestResult.confidence = 1
+	}
+	for _, other := range candidates {
+		if other.filename != bestResult.matchedFilename && other.similarity > 0 && bestResult.matchSimilarity-other.similarity <= *conflictMargin {
+			bestResult.conflicts = append(bestResult.conflicts, other)
 		}
 	}
+	if *versus {
+		bestResult.bySource = c.bestBySource(path, candidates)
+	}
+	// Scores are calibrated once the match is chosen, since calibration doesn't change the order.
+	bestResult.matchSimilarity = calibrate(path, bestResult.matchSimilarity)
+	sort.Slice(bestResult.conflicts, func(i, j int) bool {
+		a, b := bestResult.conflicts[i], bestResult.conflicts[j]
+		if a.similarity != b.similarity {
+			return a.similarity > b.similarity
+		}
+		return a.filename < b.filename
+	})
+	slog.Debug("Compared", "target", path, "candidates", len(candidates), "match", bestResult.matchedFilename, "score", percentage(bestResult.matchSimilarity))
 	return &bestResult, nil
 }
 
-func openAllCodeFiles(path string) map[string]string {
+// winsTie reports whether a source file should be chosen over another that ranks the same as a
+// match for the target file at rel: the one at the same relative path wins, then the one with
+// the same name, and then the first in order, so that the same match is chosen every run.
+func (c *comparison) winsTie(rel, sourcepath, best string) bool {
+	if best == "N/A" {
+		return true
+	}
+	likeness := func(path string) int {
+		switch sourceRel := c.relSource(path); {
+		case sourceRel == rel:
+			return 2
+		case filepath.Base(sourceRel) == filepath.Base(rel):
+			return 1
+		}
+		return 0
+	}
+	if a, b := likeness(sourcepath), likeness(best); a != b {
+		return a > b
+	}
+	return sourcepath < best
+}
+
+// A candidateFilter picks out the source files worth comparing a target file with, cheaply, so
+// that the rest needn't be diffed.
+type candidateFilter struct {
+	c              *comparison
+	path, contents string
+	pinned         bool
+	// Fingerprints of the target file for the filters that are on.
+	print fingerprint
+	hash  uint64
+	bloom *bloomFilter
+}
+
+func (c *comparison) newCandidateFilter(path, fileContents string) *candidateFilter {
+	f := &candidateFilter{c: c, path: path, contents: fileContents}
+	_, f.pinned = c.pinned[path]
+	if *noFilenameFilter {
+		f.print = fingerprintOf(fileContents)
+	}
+	if filtersBySimhash() {
+		f.hash = simhash(fileContents)
+	}
+	if filtersByTokens() {
+		f.bloom = bloomOf(fileContents)
+	}
+	return f
+}
+
+// closeEnough reports whether the source file is worth comparing with the target file.
+func (f *candidateFilter) closeEnough(sourcepath, contents string) bool {
+	// A file is always a perfect match for itself, which is no news (e.g., with --self).
+	if sourcepath == f.path {
+		return false
+	}
+	if f.pinned {
+		return true
+	}
+	c := f.c
+	var closeEnough bool
+	if *noFilenameFilter {
+		closeEnough = jaccard(f.print, c.sourcePrints[sourcepath]) > *fingerprintThreshold
+	} else {
+		// Exact copies are always compared, so that renamed files are found too.
+		closeEnough = filenamesCloseEnough(f.path, sourcepath) || len(contents) > 0 && contents == f.contents
+	}
+	if closeEnough && filtersBySimhash() {
+		closeEnough = simhashDistance(f.hash, c.sourceSimhashes[sourcepath]) <= *maxSimhashDistance
+	}
+	if closeEnough && filtersByTokens() {
+		closeEnough = f.bloom.overlap(c.sourceBlooms[sourcepath]) >= *minTokenOverlap
+	}
+	return closeEnough
+}
+
+// openSource opens all the code files of the source repo, or loads them from an index of it.
+// Files loaded from an index are keyed as if the index file were the root of the repo.
+func openSource(s *sourceRepo, pool *contentsPool) (map[string]string, *walkReport, error) {
+	path := s.root
+	if !isIndexFile(path) {
+		files, report := openAllCodeFiles(dirTree(path), pool)
+		return files, report, nil
+	}
+	idx, err := openIndex(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer idx.Close()
+	result := make(map[string]string, idx.Len())
+	s.simhashes = make(map[string]uint64, idx.Len())
+	report := newWalkReport()
+	for i := 0; i < idx.Len(); i++ {
+		contents, err := idx.Contents(i)
+		if err != nil {
+			return nil, nil, err
+		}
+		result[filepath.Join(path, idx.Path(i))] = pool.intern(contents)
+		s.simhashes[filepath.Join(path, idx.Path(i))] = idx.Simhash(i)
+		report.examined++
+		report.examinedBytes += int64(len(contents))
+	}
+	return result, report, nil
+}
+
+func saveIndex(path, root string, files map[string]string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeIndex(f, root, files); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// openAllCodeFiles reads and normalizes all the code files in a tree, keyed by the paths they are
+// reported under, interning their contents in pool.
+func openAllCodeFiles(t tree, pool *contentsPool) (map[string]string, *walkReport) {
 	result := make(map[string]string)
-	filepath.Walk(path, func(path string, info fs.FileInfo, err error) error {
-		// Don't try to read into errors.
+	report := newWalkReport()
+	// Files are read while the walk goes on, several at a time, since on network filesystems
+	// most of the time is spent waiting.
+	var mu sync.Mutex
+	var reads errgroup.Group
+	reads.SetLimit(max(*readers, 1))
+	links := newHardLinks()
+	t.walk(func(name string, info fs.FileInfo, err error) error {
+		path := t.path(name)
+		// Don't try to read into errors, but remember what we couldn't look at.
 		if err != nil {
+			mu.Lock()
+			report.skipped(path, info, err)
+			mu.Unlock()
 			return nil
 		}
 		// Don't try to read non-code files.
-		if !(strings.HasSuffix(path, ".c") || strings.HasSuffix(path, ".h")) {
+		lang := t.languageOf(name, info)
+		if lang == nil {
 			return nil
 		}
-		code, err := readCodeFileNormalized(path)
-		if err != nil {
-			return err
+		if *maxFileSize > 0 && info.Size() > *maxFileSize {
+			slog.Info("Skipping large file", "path", path, "size", info.Size())
+			mu.Lock()
+			report.tooLarge[path] = info.Size()
+			mu.Unlock()
+			return nil
+		}
+		if size := tooSmall(info.Size(), "", false); size != "" {
+			mu.Lock()
+			report.tooSmall[path] = size
+			mu.Unlock()
+			return nil
+		}
+		if links.linked(path, info) {
+			return nil
 		}
-		result[path] = code
+		reads.Go(func() error {
+			fileReport := newWalkReport()
+			kind := ""
+			if *generatedCode != "keep" {
+				kind = codeKind(t, name)
+			}
+			if hasIgnoreFilePragma(t, name, lang) {
+				kind = pragmaKind
+			}
+			if kind != "" && (*generatedCode == "exclude" || kind == pragmaKind) {
+				fileReport.excluded[path] = kind
+				mu.Lock()
+				defer mu.Unlock()
+				report.merge(fileReport)
+				return nil
+			} else if kind != "" {
+				fileReport.tagged[path] = kind
+			}
+			code, ok := fileReport.read(t, name, lang)
+			mu.Lock()
+			defer mu.Unlock()
+			report.merge(fileReport)
+			if size := tooSmall(info.Size(), code, ok); size != "" {
+				report.tooSmall[path] = size
+			} else if ok {
+				result[path] = pool.intern(code)
+				report.examined++
+				report.examinedBytes += info.Size()
+			} else {
+				report.unreadableSizes[path] = info.Size()
+			}
+			return nil
+		})
 		return nil
 	})
-	return result
+	reads.Wait()
+	links.fill(result, report)
+	return result, report
 }
 
 
 type result struct {
 	levenshtein int
 	length int
+	// Whether the diff hit --diff-timeout, so that the score is only an estimate.
+	timedOut bool
 }
 
 func (r result) asPercentage() float64 {
@@ -242,8 +1279,17 @@ func (r result) asPercentage() float64 {
 }
 
 func diff(contents1, contents2 string) *result {
-	d := dmp.DiffMain(contents1, contents2, false)
-	levenshtein := dmp.DiffLevenshtein(d)
+	return diffWith(dmp, contents1, contents2)
+}
+
+// diffWith is diff with the given settings, such as a longer timeout than the usual ones.
+func diffWith(differ *diffmatchpatch.DiffMatchPatch, contents1, contents2 string) *result {
+	if len(contents1) > *chunkSize || len(contents2) > *chunkSize {
+		// Reading from memory can't fail.
+		r, _ := chunkedDiff(differ, strings.NewReader(contents1), strings.NewReader(contents2))
+		return r
+	}
+	levenshtein, timedOut := editDistanceWith(differ, contents1, contents2)
 	maxLen := len(contents1)
 	if len(contents2) > maxLen {
 		maxLen = len(contents2)
@@ -251,49 +1297,140 @@ func diff(contents1, contents2 string) *result {
 	return &result{
 		levenshtein: levenshtein,
 		length: maxLen,
+		timedOut: timedOut,
 	}
 }
 
+// editDistance returns the number of characters inserted, deleted, or changed between the texts,
+// and whether diffing them ran out of time.
+func editDistance(text1, text2 string) (int, bool) {
+	return editDistanceWith(dmp, text1, text2)
+}
+
+func editDistanceWith(differ *diffmatchpatch.DiffMatchPatch, text1, text2 string) (int, bool) {
+	start := time.Now()
+	d := differ.DiffMain(text1, text2, false)
+	// DiffMain doesn't say whether it gave up, but it only takes this long if it did.
+	timedOut := differ.DiffTimeout > 0 && time.Since(start) >= differ.DiffTimeout
+	if differ.DiffEditCost > 0 {
+		d = differ.DiffCleanupEfficiency(d)
+	}
+	return differ.DiffLevenshtein(d), timedOut
+}
+
 func normalizeLine(line string) string {
-	return strings.Join(strings.Fields(line), " ")
+	if *normalizeTabs {
+		line = expandTabs(line)
+	}
+	if *collapseWhitespace {
+		line = strings.Join(strings.Fields(line), " ")
+	}
+	if *ignoreCase {
+		line = strings.ToLower(line)
+	}
+	return line
+}
+
+// readCodeFileNormalized reads and normalizes a code file.
+func readCodeFileNormalized(filename string, lang *language) (string, error) {
+	t, name := fileTree(filename)
+	return t.readNormalized(name, lang)
 }
 
-func readCodeFileNormalized(filename string) (string, error) {
-	f, err := os.Open(filename)
+// readNormalized reads and normalizes a code file in the tree. Files on disk are mapped into
+// memory rather than read, since only the normalized code is kept.
+func (t tree) readNormalized(name string, lang *language) (string, error) {
+	defer timeSince(normalizing, time.Now())
+	data, unmap, err := t.mapFile(name)
 	if err != nil {
 		return "", err
 	}
-	defer f.Close()
+	defer unmap()
+	readPace.wait(len(data))
+	if code, ok := normalized.get(data, lang); ok {
+		return code, nil
+	}
+	code, _, err := normalizeCode(t.path(name), data, lang)
+	if err == nil {
+		normalized.put(data, lang, code)
+	}
+	return code, err
+}
 
-	var sb strings.Builder
-	scanner := bufio.NewScanner(f)
-	var comment, block bool
-	for scanner.Scan() {
-		comment, block = isComment(scanner.Text(), block)
-		if !comment {
-			sb.WriteString(normalizeLine(scanner.Text()))
-			sb.WriteRune('\n')
-		}
+// readCodeFileNumbered is readCodeFileNormalized, also returning the number of the line in the
+// file (or, with --preprocess, in the preprocessed file) that each line of normalized code is from.
+func readCodeFileNumbered(filename string, lang *language) (string, []int, error) {
+	data, unmap, err := mapFile(filename)
+	if err != nil {
+		return "", nil, err
 	}
-	return sb.String(), nil
+	defer unmap()
+	readPace.wait(len(data))
+	return normalizeCode(filename, data, lang)
 }
 
-func isComment(line string, blockComment bool) (isComment, stillInBlockComment bool) {
-	line = strings.Trim(line, " \t")
-	if len(line) == 0 {
-		return blockComment, blockComment
+// normalizeCode normalizes the contents of a code file, returning the line number of each line
+// of normalized code as readCodeFileNumbered does.
+func normalizeCode(filename string, data []byte, lang *language) (string, []int, error) {
+	if *strictWhitespace {
+		text, numbers := verbatim(data)
+		return text, numbers, nil
+	}
+	text, encoding := decodeText(data)
+	if encoding != "UTF-8" {
+		slog.Debug("Transcoded file", "path", filename, "from", encoding)
+	}
+	text = normalizeUnicode(text)
+	if out, ok, err := runNormalizer(filename, text, lang); err != nil {
+		slog.Warn("Couldn't run normalizer, comparing file as it is", "path", filename, "err", err)
+	} else if ok {
+		text = out
 	}
-	isComment = blockComment
-	stillInBlockComment = blockComment
-	if strings.HasPrefix(line, "/*") {
-		isComment = true
-		stillInBlockComment = true
+	if *preprocess && lang == cLanguage {
+		if preprocessed, err := runPreprocessor(filename, text); err != nil {
+			slog.Warn("Couldn't preprocess file, comparing it as it is", "path", filename, "err", err)
+		} else {
+			text = preprocessed
+		}
+	}
+
+	lines := splitLongLines(normalizeLineEndings(text))
+	start := 0
+	if *stripLicenseHeaders {
+		start = licenseHeaderLen(lines, lang)
 	}
-	if strings.Contains(line, "*/") {
-		stillInBlockComment = false
+	var dead []bool
+	if *stripIfZero && lang == cLanguage {
+		dead = ifZeroLines(lines)
 	}
-	if strings.HasPrefix(line, "//") {
-		isComment = true
+
+	var sb strings.Builder
+	var numbers []int
+	var comment, ignoring, skip bool
+	var state lexState
+	for i := start; i < len(lines); i++ {
+		line := lines[i]
+		if dead != nil && dead[i] {
+			continue
+		}
+		// The lexer still sees ignored lines, since comments and strings may span into them.
+		var comments string
+		comment, line, comments, state = lexLineComments(line, lang, state)
+		skip, ignoring = ignoredRegion(comments, ignoring)
+		if skip {
+			continue
+		}
+		if comment == comparesComments() {
+			line = normalizeCopyrightYears(removeIgnored(line))
+			if *normalizeIncludes && lang == cLanguage {
+				line = normalizeInclude(line)
+			}
+			for _, piece := range chunkLine(normalizeLine(line)) {
+				numbers = append(numbers, i+1)
+				sb.WriteString(piece)
+				sb.WriteRune('\n')
+			}
+		}
 	}
-	return
+	return sb.String(), numbers, nil
 }
\ No newline at end of file
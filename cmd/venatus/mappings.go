@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	mappingsPath  = flag.String("map", "", "file pinning target files to the source files they must be compared with, one \"target/path: source/path\" per line (a YAML mapping), for renames no heuristic will find")
+	mapPrecedence = flag.String("map-precedence", "pin", "how --map pins weigh against automatic matching: pin (pinned files are only compared with what they're pinned to), best (the pin competes with automatic matching, and wins ties), or warn (the pin is the match, but automatic matching is checked against it); disagreements are reported")
+)
+
+func checkMapPrecedence() error {
+	switch *mapPrecedence {
+	case "pin", "best", "warn":
+		return nil
+	}
+	return fmt.Errorf("--map-precedence: unknown value %q (want pin, best, or warn)", *mapPrecedence)
+}
+
+// readMappings reads a mappings file: lines of "target: source", with paths relative to the target
+// and source repos (as source paths are shown in the report, so "name:path" with several
+// sources). Blank lines and # comments are ignored, and paths may be quoted.
+func readMappings(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	mappings := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		target, source, ok := strings.Cut(line, ": ")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: want \"target: source\"", path, n)
+		}
+		mappings[unquote(target)] = unquote(source)
+	}
+	return mappings, scanner.Err()
+}
+
+func unquote(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// pinMappings works out which source file each target file in the --map file is pinned to.
+func (c *comparison) pinMappings() error {
+	if *mappingsPath == "" {
+		return nil
+	}
+	mappings, err := readMappings(*mappingsPath)
+	if err != nil {
+		return err
+	}
+	byLabel := make(map[string]string, len(c.sourceFiles))
+	for path := range c.sourceFiles {
+		byLabel[c.sourceLabel(path)] = path
+	}
+	c.pinned = make(map[string]string, len(mappings))
+	for target, source := range mappings {
+		targetPath := filepath.Join(c.targetRoot, target)
+		if _, ok := c.targetFiles[targetPath]; !ok {
+			return fmt.Errorf("%s: no target file %q", *mappingsPath, target)
+		}
+		sourcePath, ok := byLabel[filepath.ToSlash(filepath.Clean(source))]
+		if !ok {
+			return fmt.Errorf("%s: no source file %q", *mappingsPath, source)
+		}
+		c.pinned[targetPath] = sourcePath
+	}
+	return nil
+}
+
+// crossCheckSets returns the sets of source files to search to check a pinned target file's
+// match, with --map-precedence best or warn, or nil if it isn't checked: the file it's pinned to
+// with best, whose match comes from automatic matching, or those of automatic matching with warn,
+// whose match is the pin.
+func (c *comparison) crossCheckSets(path string) []map[string]string {
+	pinnedTo, ok := c.pinned[path]
+	switch {
+	case !ok || *mapPrecedence == "pin":
+		return nil
+	case *mapPrecedence == "best":
+		return []map[string]string{{pinnedTo: c.sourceFiles[pinnedTo]}}
+	}
+	return c.automaticSets(path)
+}
+
+// printMapDisagreements lists the target files whose --map pins automatic matching disagreed
+// with, with --map-precedence best or warn, and which of the two was taken as the match.
+func printMapDisagreements(c *comparison) {
+	var disagreed []*findResult
+	for _, result := range c.results {
+		if result.overruled != nil {
+			disagreed = append(disagreed, result)
+		}
+	}
+	if len(disagreed) == 0 {
+		return
+	}
+	fmt.Printf("\n\n%d target files' --map pins disagree with automatic matching:\n", len(disagreed))
+	for _, result := range disagreed {
+		chosen, other := "automatic", "pinned"
+		if c.pinned[result.filename] == result.matchedFilename {
+			chosen, other = other, chosen
+		}
+		fmt.Printf("%s\n", c.relTarget(result.filename))
+		fmt.Printf("  * %s (%v, %s, chosen)\n", c.sourceLabel(result.matchedFilename), percentage(result.matchSimilarity), chosen)
+		fmt.Printf("    %s (%v, %s)\n", c.sourceLabel(result.overruled.filename), percentage(result.overruled.similarity), other)
+	}
+}
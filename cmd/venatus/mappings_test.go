@@ -0,0 +1,52 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMapPrecedence(t *testing.T) {
+	defer func(precedence string) { *mapPrecedence = precedence }(*mapPrecedence)
+	crc := "static unsigned long crc_table[256];\nunsigned long crc32(unsigned long crc, const unsigned char *buf, unsigned len)\n{\n    crc = crc ^ 0xffffffffUL;\n    while (len--)\n        crc = crc_table[(crc ^ *buf++) & 0xff] ^ (crc >> 8);\n    return crc ^ 0xffffffffUL;\n}\n"
+	oldCRC := "unsigned long crc32(crc, buf, len)\n    unsigned long crc;\n    const unsigned char *buf;\n    unsigned len;\n{\n    return crc;\n}\n"
+	source, target := filepath.FromSlash("/zlib"), filepath.FromSlash("/fork")
+	newC, oldC := filepath.Join(source, "crc32.c"), filepath.Join(source, "old", "crc32.c")
+	forkC := filepath.Join(target, "crc32.c")
+	tests := []struct {
+		precedence, pinnedTo string
+		wantMatch            string
+		// The source file that isn't the match, where the pin and automatic matching disagree.
+		wantOverruled string
+	}{
+		{"pin", oldC, oldC, ""},
+		{"best", oldC, newC, oldC},
+		{"warn", oldC, oldC, newC},
+		{"best", newC, newC, ""},
+		{"warn", newC, newC, ""},
+	}
+	for _, tt := range tests {
+		*mapPrecedence = tt.precedence
+		c := &comparison{
+			sources:     []*sourceRepo{{name: "zlib", root: source}},
+			targetRoot:  target,
+			sourceFiles: map[string]string{newC: crc, oldC: oldCRC},
+			targetFiles: map[string]string{forkC: crc},
+			pinned:      map[string]string{forkC: tt.pinnedTo},
+		}
+		result, err := c.findBestCandidate(forkC, crc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		overruled := ""
+		if result.overruled != nil {
+			overruled = result.overruled.filename
+		}
+		if result.matchedFilename != tt.wantMatch || overruled != tt.wantOverruled {
+			t.Errorf("--map-precedence=%s pinned to %s: match %s, overruled %q; want %s, %q", tt.precedence, tt.pinnedTo, result.matchedFilename, overruled, tt.wantMatch, tt.wantOverruled)
+		}
+	}
+	*mapPrecedence = "first"
+	if err := checkMapPrecedence(); err == nil {
+		t.Error("checkMapPrecedence() accepted --map-precedence=first")
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sort"
+)
+
+var (
+	matchDirs         = flag.Bool("match-dirs", false, "match directories first, by the lines their files share, and only look for each target file's match in its directory's match, falling back to all source files if nothing there matches; much quicker on large trees")
+	dirMatchThreshold = flag.Float64("dir-match-threshold", 0.2, "with --match-dirs, the fraction of their distinct lines directories must share to be matched (0 to 1)")
+)
+
+func checkMatchDirs() error {
+	if *dirMatchThreshold < 0 || *dirMatchThreshold > 1 {
+		return fmt.Errorf("--dir-match-threshold must be between 0 and 1")
+	}
+	return nil
+}
+
+// dirFingerprints fingerprints each directory by the distinct lines of all the files directly in
+// it, also returning the files of each.
+func dirFingerprints(files map[string]string) (map[string]fingerprint, map[string]map[string]string) {
+	seen := make(map[string]map[uint64]bool)
+	byDir := make(map[string]map[string]string)
+	for path, contents := range files {
+		dir := filepath.Dir(path)
+		if seen[dir] == nil {
+			seen[dir] = make(map[uint64]bool)
+			byDir[dir] = make(map[string]string)
+		}
+		byDir[dir][path] = contents
+		for _, h := range fingerprintOf(contents) {
+			seen[dir][h] = true
+		}
+	}
+	prints := make(map[string]fingerprint, len(seen))
+	for dir, hashes := range seen {
+		fp := make(fingerprint, 0, len(hashes))
+		for h := range hashes {
+			fp = append(fp, h)
+		}
+		sort.Slice(fp, func(i, j int) bool { return fp[i] < fp[j] })
+		prints[dir] = fp
+	}
+	return prints, byDir
+}
+
+// matchDirectories matches each target directory with the source directory that shares the most
+// lines with it, if enough do, with --match-dirs.
+func (c *comparison) matchDirectories() {
+	sourcePrints, sourceByDir := dirFingerprints(c.sourceFiles)
+	targetPrints, _ := dirFingerprints(c.targetFiles)
+	c.sourceByDir = sourceByDir
+	c.dirMatches = make(map[string]string)
+	sourceDirs := sortedKeys(sourcePrints)
+	for targetDir, targetPrint := range targetPrints {
+		best, bestScore := "", *dirMatchThreshold
+		for _, sourceDir := range sourceDirs {
+			if score := jaccard(targetPrint, sourcePrints[sourceDir]); score > bestScore {
+				best, bestScore = sourceDir, score
+			}
+		}
+		if best != "" {
+			c.dirMatches[targetDir] = best
+		}
+	}
+	slog.Info("Matched directories", "matched", len(c.dirMatches), "of", len(targetPrints))
+}
+
+// dirCandidates returns the source files in the directory matched with the target file's, with
+// --match-dirs, or false if there isn't one.
+func (c *comparison) dirCandidates(path string) (map[string]string, bool) {
+	sourceDir, ok := c.dirMatches[filepath.Dir(path)]
+	if !ok {
+		return nil, false
+	}
+	return c.sourceByDir[sourceDir], true
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"sort"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+// A repoPair is two of the repos compared by matrix, with how much of each is found in the other.
+type repoPair struct {
+	a, b string
+	// The scores of a as the target, against b as the source, and the other way around.
+	aInB, bInA float64
+}
+
+// similarity is how alike the repos are, either way around.
+func (p repoPair) similarity() float64 {
+	return (p.aInB + p.bInA) / 2
+}
+
+var (
+	matrixFlags = flag.NewFlagSet("matrix", flag.ContinueOnError)
+	minDerived  = matrixFlags.Float64("min-derived", 0.5, "only say which of a pair of repos is likely derived from the other if they are at least this alike, from 0 to 1")
+)
+
+// matrix compares every repo given with every other, both ways around, and prints the overall
+// scores as a matrix, then the pairs from most to least alike. Among a set of forks, the pairs
+// that are most alike are likely derived one from the other; since a fork adds to what it takes,
+// more of the original is usually found in the fork than the other way around.
+func matrix(args []string) error {
+	if len(args) < 2 {
+		return errors.New("usage: venatus matrix [flags] REPO REPO...")
+	}
+	if err := setUp(); err != nil {
+		return err
+	}
+	repos := args
+	names := nameSources(repos)
+	roots := make([]string, len(repos))
+	for i, repo := range repos {
+		roots[i] = repo
+		if isPackage(repo) {
+			dir, err := fetchPackage(repo)
+			if err != nil {
+				return err
+			}
+			roots[i] = dir
+		}
+	}
+
+	// scores[i][j] is the score of repo i as the target, against repo j as the source.
+	scores := make([][]float64, len(roots))
+	for i := range roots {
+		scores[i] = make([]float64, len(roots))
+		for j := range roots {
+			if i == j {
+				continue
+			}
+			slog.Info("Comparing repos", "target", names[i], "source", names[j])
+			c, err := compare([]string{roots[j]}, roots[i])
+			if err != nil {
+				return fmt.Errorf("%s against %s: %w", names[i], names[j], err)
+			}
+			scores[i][j] = c.overallScore
+		}
+	}
+
+	tw := table.NewWriter()
+	tw.SetStyle(table.StyleDouble)
+	header := table.Row{"Target \\ Source"}
+	var configs []table.ColumnConfig
+	for j, name := range names {
+		header = append(header, name)
+		configs = append(configs, table.ColumnConfig{Number: j + 2, Align: text.AlignRight})
+	}
+	tw.AppendHeader(header)
+	for i, name := range names {
+		row := table.Row{name}
+		for j := range names {
+			if i == j {
+				row = append(row, "-")
+			} else {
+				row = append(row, percentage(scores[i][j]))
+			}
+		}
+		tw.AppendRow(row)
+	}
+	tw.SetColumnConfigs(configs)
+	fmt.Println(tw.Render())
+
+	var pairs []repoPair
+	for i := range names {
+		for j := i + 1; j < len(names); j++ {
+			pairs = append(pairs, repoPair{a: names[i], b: names[j], aInB: scores[i][j], bInA: scores[j][i]})
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i].similarity() > pairs[j].similarity()
+	})
+	tw = table.NewWriter()
+	tw.SetStyle(table.StyleDouble)
+	tw.AppendHeader(table.Row{"Rank", "Repos", "Similarity", "Likely derived"})
+	for i, p := range pairs {
+		derived := ""
+		if p.similarity() >= *minDerived && p.aInB < p.bInA {
+			derived = fmt.Sprintf("%s from %s", p.a, p.b)
+		} else if p.similarity() >= *minDerived && p.aInB > p.bInA {
+			derived = fmt.Sprintf("%s from %s", p.b, p.a)
+		}
+		tw.AppendRow(table.Row{i + 1, p.a + " ~ " + p.b, percentage(p.similarity()), derived})
+	}
+	tw.SetColumnConfigs([]table.ColumnConfig{
+		{Number: 1, Align: text.AlignRight},
+		{Number: 3, Align: text.AlignRight},
+	})
+	tw.SetCaption("%s", "Similarity is the mean of the scores of each repo against the other.")
+	fmt.Println("\n" + tw.Render())
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"golang.org/x/sync/semaphore"
+)
+
+var memoryBudget = flag.Int64("memory-budget", 0, "roughly how many bytes of memory comparisons in flight may use at once; large files wait for room rather than being compared side by side and exhausting memory (0 for no limit)")
+
+// How many bytes diffing a pair of files takes per byte of the target file, roughly: the files
+// as runes, and the diff's intermediate slices.
+const pairCostFactor = 32
+
+func checkMemoryBudget() error {
+	if *memoryBudget < 0 {
+		return fmt.Errorf("--memory-budget must not be negative")
+	}
+	return nil
+}
+
+// A memoryGate holds back comparisons until there's room for them in --memory-budget.
+type memoryGate struct {
+	sem *semaphore.Weighted
+}
+
+func newMemoryGate() memoryGate {
+	if *memoryBudget == 0 {
+		return memoryGate{}
+	}
+	return memoryGate{semaphore.NewWeighted(*memoryBudget)}
+}
+
+// cost estimates the memory needed to compare a target file with its candidates, which are
+// mostly of about its size. A file too large for the budget takes all of it, to be compared alone.
+func (g memoryGate) cost(contents string) int64 {
+	return min(*memoryBudget, max(1, pairCostFactor*int64(len(contents))))
+}
+
+// acquire waits for room to compare a target file, returning a function to give it back.
+func (g memoryGate) acquire(contents string) func() {
+	if g.sem == nil {
+		return func() {}
+	}
+	n := g.cost(contents)
+	// The context is never cancelled, so this can't fail.
+	g.sem.Acquire(context.Background(), n)
+	return func() { g.sem.Release(n) }
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+var (
+	mergeUpstream = flag.String("merge-upstream", "", "a newer version of the source repo: with --merge-dir, merge the changes made to each matched source file since into its target file")
+	mergeDir      = flag.String("merge-dir", "", "directory to write the three-way merges of --merge-upstream into, mirroring the layout of the target repo, with conflict markers where the target and upstream changed the same lines")
+)
+
+func checkMerge() error {
+	if (*mergeUpstream == "") != (*mergeDir == "") {
+		return errors.New("--merge-upstream and --merge-dir must be given together")
+	}
+	if *mergeUpstream == "" {
+		return nil
+	}
+	// Merging needs the files as they are, which an index doesn't keep.
+	for _, source := range sources {
+		if !isPackage(source) && isIndexFile(source) {
+			return fmt.Errorf("--merge-upstream needs the source repo itself, not an index of it (%s)", source)
+		}
+	}
+	if isIndexFile(*mergeUpstream) {
+		return fmt.Errorf("--merge-upstream needs a directory, not an index (%s)", *mergeUpstream)
+	}
+	return nil
+}
+
+// A hunk replaces the lines [start, end) of a base file with others.
+type hunk struct {
+	start, end int
+	lines      []string
+	// Which side of the merge the hunk is from: 0 for the target, 1 for upstream.
+	side int
+}
+
+// hunks returns the changes that turn base into other, as hunks of whole lines.
+func hunks(base, other string, side int) []hunk {
+	var hs []hunk
+	var current *hunk
+	i := 0
+	for _, line := range lineDiff(base, other) {
+		if line.op == diffmatchpatch.DiffEqual {
+			if current != nil {
+				hs = append(hs, *current)
+				current = nil
+			}
+			i++
+			continue
+		}
+		if current == nil {
+			current = &hunk{start: i, end: i, side: side}
+		}
+		if line.op == diffmatchpatch.DiffDelete {
+			i++
+			current.end = i
+		} else {
+			current.lines = append(current.lines, line.text)
+		}
+	}
+	if current != nil {
+		hs = append(hs, *current)
+	}
+	return hs
+}
+
+// applyHunks returns the lines [start, end) of base with the given hunks, all within them, applied.
+func applyHunks(base []string, start, end int, hs []hunk) []string {
+	var out []string
+	i := start
+	for _, h := range hs {
+		out = append(out, base[i:h.start]...)
+		out = append(out, h.lines...)
+		i = h.end
+	}
+	return append(out, base[i:end]...)
+}
+
+// withNewline makes sure that lines end with a newline, so that a conflict marker can follow.
+func withNewline(lines []string) []string {
+	if n := len(lines); n > 0 && !strings.HasSuffix(lines[n-1], "\n") {
+		lines = append(lines[:n-1:n-1], lines[n-1]+"\n")
+	}
+	return lines
+}
+
+// merge3 merges the changes from base to target with those from base to upstream. Changes to the
+// same or adjacent lines that differ are conflicts, and are marked as git marks them.
+// It returns the merge and how many conflicts it has.
+func merge3(base, target, upstream string) (string, int) {
+	baseLines := splitLines(base)
+	all := append(hunks(base, target, 0), hunks(base, upstream, 1)...)
+	sort.SliceStable(all, func(i, j int) bool { return all[i].start < all[j].start })
+
+	var out []string
+	conflicts := 0
+	i := 0
+	for g := 0; g < len(all); {
+		// Gather the hunks that overlap or touch into one region of base.
+		start, end := all[g].start, all[g].end
+		var sides [2][]hunk
+		for ; g < len(all) && all[g].start <= end; g++ {
+			end = max(end, all[g].end)
+			sides[all[g].side] = append(sides[all[g].side], all[g])
+		}
+		out = append(out, baseLines[i:start]...)
+		ours := applyHunks(baseLines, start, end, sides[0])
+		theirs := applyHunks(baseLines, start, end, sides[1])
+		switch {
+		case len(sides[1]) == 0:
+			out = append(out, ours...)
+		case len(sides[0]) == 0 || strings.Join(ours, "") == strings.Join(theirs, ""):
+			out = append(out, theirs...)
+		default:
+			conflicts++
+			out = append(out, "<<<<<<< target\n")
+			out = append(out, withNewline(ours)...)
+			out = append(out, "||||||| source\n")
+			out = append(out, withNewline(baseLines[start:end])...)
+			out = append(out, "=======\n")
+			out = append(out, withNewline(theirs)...)
+			out = append(out, ">>>>>>> upstream\n")
+		}
+		i = end
+	}
+	out = append(out, baseLines[i:]...)
+	return strings.Join(out, ""), conflicts
+}
+
+// writeMerges merges the changes upstream has made to each matched source file since into its
+// target file, with --merge-upstream. Like patches, merges are of the files as they are, read
+// through the trees they were found in.
+func writeMerges(c *comparison) error {
+	merged, conflicted := 0, 0
+	for _, result := range c.results {
+		if result.matchedFilename == "N/A" {
+			continue
+		}
+		upstreamPath := filepath.Join(*mergeUpstream, c.relSource(result.matchedFilename))
+		t, name := treeFile(*mergeUpstream, upstreamPath)
+		upstream, err := t.readFile(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			slog.Info("Match is gone upstream, not merging", "path", result.filename, "match", upstreamPath)
+			continue
+		} else if err != nil {
+			return err
+		}
+		base, err := c.readSourceFile(result.matchedFilename)
+		if err != nil {
+			return err
+		}
+		target, err := c.readTargetFile(result.filename)
+		if err != nil {
+			return err
+		}
+		text, conflicts := merge3(string(base), string(target), string(upstream))
+		rel := c.relTarget(result.filename)
+		out := filepath.Join(*mergeDir, rel)
+		if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(out, []byte(text), 0644); err != nil {
+			return fmt.Errorf("writing merge of %q: %w", rel, err)
+		}
+		merged++
+		if conflicts > 0 {
+			conflicted++
+			slog.Warn("Merge has conflicts", "path", rel, "conflicts", conflicts)
+		}
+	}
+	slog.Info("Wrote merges", "count", merged, "conflicted", conflicted, "dir", *mergeDir)
+	return nil
+}
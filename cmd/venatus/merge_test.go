@@ -0,0 +1,143 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// lines joins lines of a file, each ending in a newline.
+func lines(ls ...string) string {
+	if len(ls) == 0 {
+		return ""
+	}
+	return strings.Join(ls, "\n") + "\n"
+}
+
+func TestMerge3(t *testing.T) {
+	base := lines("a", "b", "c", "d", "e")
+	tests := map[string]struct {
+		target, upstream string
+		want             string
+		conflicts        int
+	}{
+		"no changes": {
+			target: base, upstream: base, want: base,
+		},
+		"only the target changed": {
+			target: lines("a", "B", "c", "d", "e"), upstream: base,
+			want: lines("a", "B", "c", "d", "e"),
+		},
+		"only upstream changed": {
+			target: base, upstream: lines("a", "b", "c", "D", "e"),
+			want: lines("a", "b", "c", "D", "e"),
+		},
+		"changes to lines apart": {
+			target: lines("a", "B", "c", "d", "e"), upstream: lines("a", "b", "c", "d", "E"),
+			want: lines("a", "B", "c", "d", "E"),
+		},
+		"the same change on both sides": {
+			target: lines("a", "c", "d", "e"), upstream: lines("a", "c", "d", "e"),
+			want: lines("a", "c", "d", "e"),
+		},
+		"upstream appended": {
+			target: lines("A", "b", "c", "d", "e"), upstream: lines("a", "b", "c", "d", "e", "f"),
+			want: lines("A", "b", "c", "d", "e", "f"),
+		},
+		"both changed a line": {
+			target: lines("a", "B", "c", "d", "e"), upstream: lines("a", "X", "c", "d", "e"),
+			want:      lines("a", "<<<<<<< target", "B", "||||||| source", "b", "=======", "X", ">>>>>>> upstream", "c", "d", "e"),
+			conflicts: 1,
+		},
+		"changes to adjacent lines": {
+			target: lines("a", "B", "c", "d", "e"), upstream: lines("a", "b", "C", "d", "e"),
+			want:      lines("a", "<<<<<<< target", "B", "c", "||||||| source", "b", "c", "=======", "b", "C", ">>>>>>> upstream", "d", "e"),
+			conflicts: 1,
+		},
+		"different insertions in the same place": {
+			target: lines("a", "b", "T", "c", "d", "e"), upstream: lines("a", "b", "U", "c", "d", "e"),
+			want:      lines("a", "b", "<<<<<<< target", "T", "||||||| source", "=======", "U", ">>>>>>> upstream", "c", "d", "e"),
+			conflicts: 1,
+		},
+		"target deleted what upstream changed": {
+			target: lines("a", "c", "d", "e"), upstream: lines("a", "X", "c", "d", "e"),
+			want:      lines("a", "<<<<<<< target", "||||||| source", "b", "=======", "X", ">>>>>>> upstream", "c", "d", "e"),
+			conflicts: 1,
+		},
+		"last lines without a newline": {
+			target: "a\nb\nc\nd\nT", upstream: "a\nb\nc\nd\nU",
+			want:      lines("a", "b", "c", "d", "<<<<<<< target", "T", "||||||| source", "e", "=======", "U", ">>>>>>> upstream"),
+			conflicts: 1,
+		},
+	}
+	for name, tt := range tests {
+		got, conflicts := merge3(base, tt.target, tt.upstream)
+		if got != tt.want || conflicts != tt.conflicts {
+			t.Errorf("%s: merge3() = %d conflicts\n%s\nwant %d conflicts\n%s", name, conflicts, got, tt.conflicts, tt.want)
+		}
+	}
+}
+
+func TestHunks(t *testing.T) {
+	base := lines("a", "b", "c")
+	tests := []struct {
+		other string
+		want  []hunk
+	}{
+		{base, nil},
+		{lines("a", "B", "c"), []hunk{{start: 1, end: 2, lines: []string{"B\n"}, side: 1}}},
+		{lines("x", "a", "b", "c"), []hunk{{start: 0, end: 0, lines: []string{"x\n"}, side: 1}}},
+		{lines("a", "c"), []hunk{{start: 1, end: 2, side: 1}}},
+		{lines("A", "b", "C"), []hunk{{start: 0, end: 1, lines: []string{"A\n"}, side: 1}, {start: 2, end: 3, lines: []string{"C\n"}, side: 1}}},
+		{"", []hunk{{start: 0, end: 3, side: 1}}},
+	}
+	for _, tt := range tests {
+		if got := hunks(base, tt.other, 1); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("hunks(%q, %q) = %+v, want %+v", base, tt.other, got, tt.want)
+		}
+	}
+}
+
+func TestWriteMerges(t *testing.T) {
+	defer func(upstream, dir string) { *mergeUpstream, *mergeDir = upstream, dir }(*mergeUpstream, *mergeDir)
+	dir := t.TempDir()
+	source, target, newer := filepath.Join(dir, "upstream"), filepath.Join(dir, "fork"), filepath.Join(dir, "newer")
+	*mergeUpstream, *mergeDir = newer, filepath.Join(dir, "merges")
+	files := map[string]string{
+		filepath.Join(source, "lib", "util.c"): lines("/* upstream */", "int add(int a, int b)", "{", "\treturn a + b;", "}"),
+		filepath.Join(target, "lib", "util.c"): lines("/* fork */", "int add(int a, int b)", "{", "\treturn a + b;", "}"),
+		filepath.Join(newer, "lib", "util.c"):  lines("/* upstream */", "int add(int a, int b)", "{", "\treturn b + a;", "}"),
+	}
+	for path, contents := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := &comparison{
+		sources:    []*sourceRepo{{name: "upstream", root: source}},
+		targetRoot: target,
+		results: []*findResult{
+			{filename: filepath.Join(target, "lib", "util.c"), matchedFilename: filepath.Join(source, "lib", "util.c")},
+			{filename: filepath.Join(target, "lib", "gone.c"), matchedFilename: filepath.Join(source, "lib", "gone.c")},
+		},
+	}
+	if err := writeMerges(c); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filepath.Join(*mergeDir, "lib", "util.c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The fork's comment and upstream's change both make it in.
+	if want := lines("/* fork */", "int add(int a, int b)", "{", "\treturn b + a;", "}"); string(got) != want {
+		t.Errorf("merge = %q, want %q", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(*mergeDir, "lib", "gone.c")); !os.IsNotExist(err) {
+		t.Errorf("wrote a merge for a file gone upstream: %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"net/url"
+	"os/exec"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// runMetadata describes how a comparison was run, so that a report says where it came from when
+// it turns up again long after.
+type runMetadata struct {
+	Version string            `json:"version"`
+	Started time.Time         `json:"started"`
+	Flags   map[string]string `json:"flags,omitempty"`
+	Sources []repoMetadata    `json:"sources"`
+	Target  repoMetadata      `json:"target"`
+}
+
+// repoMetadata describes one of the repos compared.
+type repoMetadata struct {
+	Name string `json:"name,omitempty"`
+	Path string `json:"path,omitempty"`
+	// The commit checked out, if the repo is a git repo.
+	Commit string `json:"commit,omitempty"`
+}
+
+// version is the version of venatus that is running, as recorded when it was built.
+func version() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(unknown)"
+}
+
+// headCommit returns the commit checked out in a git repo, or "" if it isn't one.
+func headCommit(dir string) string {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// Flags whose whole value is a secret, such as a Slack webhook URL, which is a credential.
+var secretFlags = map[string]bool{"notify-webhook": true}
+
+// redactFlag returns a flag's value as recorded in metadata, which ends up in summaries, webhook
+// payloads, and the serve API. Secret flags are left out, and so are the credentials and query
+// strings of URLs, such as those of a --remote-cache.
+func redactFlag(name, value string) string {
+	if secretFlags[name] {
+		return "<redacted>"
+	}
+	if !strings.Contains(value, "://") {
+		return value
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		// Whatever it is, it may hold a secret.
+		return "<redacted>"
+	}
+	if u.User != nil {
+		u.User = url.User("redacted")
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = "redacted"
+	}
+	return u.String()
+}
+
+// newRunMetadata describes a comparison starting now.
+func (c *comparison) newRunMetadata() *runMetadata {
+	m := &runMetadata{
+		Version: version(),
+		Started: time.Now().UTC().Truncate(time.Second),
+		Flags:   make(map[string]string),
+		Target:  repoMetadata{Path: c.targetRoot, Commit: headCommit(c.targetRoot)},
+	}
+	flag.Visit(func(f *flag.Flag) {
+		m.Flags[f.Name] = redactFlag(f.Name, f.Value.String())
+	})
+	for _, s := range c.sources {
+		m.Sources = append(m.Sources, repoMetadata{Name: s.name, Path: s.root, Commit: headCommit(s.root)})
+	}
+	return m
+}
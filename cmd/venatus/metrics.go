@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Counters of the pair cache's effectiveness, for /metrics.
+var pairCacheHits, pairCacheMisses atomic.Int64
+
+// A metricHistogram counts observations in cumulative buckets, as Prometheus histograms do.
+type metricHistogram struct {
+	mu     sync.Mutex
+	bounds []float64
+	counts []int64
+	sum    float64
+	count  int64
+}
+
+func newMetricHistogram(bounds ...float64) *metricHistogram {
+	return &metricHistogram{bounds: bounds, counts: make([]int64, len(bounds))}
+}
+
+func (h *metricHistogram) observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, bound := range h.bounds {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+func (h *metricHistogram) write(w io.Writer, name, help string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	for i, bound := range h.bounds {
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", name, strconv.FormatFloat(bound, 'g', -1, 64), h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n%s_sum %g\n%s_count %d\n", name, h.count, name, h.sum, name, h.count)
+}
+
+// serverMetrics are what the server exposes at /metrics, in Prometheus's text format.
+type serverMetrics struct {
+	jobsStarted, jobsFailed atomic.Int64
+	filesCompared           atomic.Int64
+	duration                *metricHistogram
+	scores                  *metricHistogram
+}
+
+func newServerMetrics() *serverMetrics {
+	return &serverMetrics{
+		duration: newMetricHistogram(1, 5, 15, 60, 300, 900, 3600, 4*3600),
+		scores:   newMetricHistogram(0, 0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9, 0.95, 0.99, 1),
+	}
+}
+
+// finished records a comparison that finished, successfully or not, after running for elapsed.
+func (m *serverMetrics) finished(c *comparison, err error, elapsed time.Duration) {
+	m.duration.observe(elapsed.Seconds())
+	if err != nil {
+		m.jobsFailed.Add(1)
+		return
+	}
+	m.filesCompared.Add(int64(len(c.results)))
+	for _, result := range c.results {
+		m.scores.observe(result.matchSimilarity)
+	}
+}
+
+func counter(w io.Writer, name, help string, v int64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, v)
+}
+
+func (m *serverMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	counter(w, "venatus_jobs_started_total", "Comparisons started.", m.jobsStarted.Load())
+	counter(w, "venatus_jobs_failed_total", "Comparisons that failed.", m.jobsFailed.Load())
+	counter(w, "venatus_files_compared_total", "Target files compared by finished comparisons.", m.filesCompared.Load())
+	counter(w, "venatus_pair_cache_hits_total", "Pairs of files whose score was found in the --cache-dir or --remote-cache.", pairCacheHits.Load())
+	counter(w, "venatus_pair_cache_misses_total", "Pairs of files whose score had to be worked out despite a cache.", pairCacheMisses.Load())
+	m.duration.write(w, "venatus_comparison_duration_seconds", "How long comparisons took, whether or not they succeeded.")
+	m.scores.write(w, "venatus_file_score", "Scores of target files, from 0 to 1.")
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+var (
+	minLines = flag.Int("min-lines", 0, "skip code files with fewer than this many lines of code once normalized, like empty headers and stubs, whose 100% or 0% matches are noise, listing them after the results")
+	minBytes = flag.Int64("min-bytes", 0, "skip code files smaller than this many bytes, listing them after the results")
+)
+
+// tooSmall says why a file is too small to be worth comparing, per --min-bytes and --min-lines,
+// or returns "" if it isn't. The size is known before the file is read, but its lines of code
+// only after; code is "" until then.
+func tooSmall(size int64, code string, read bool) string {
+	if *minBytes > 0 && size < *minBytes {
+		return byteSize(size)
+	}
+	if !read || *minLines <= 0 {
+		return ""
+	}
+	if n := strings.Count(code, "\n"); n < *minLines {
+		return fmt.Sprintf("%d lines", n)
+	}
+	return ""
+}
+
+// printTooSmall lists the files skipped for being smaller than --min-bytes or --min-lines.
+func printTooSmall(name string, rel func(string) string, report *walkReport) {
+	if len(report.tooSmall) == 0 {
+		return
+	}
+	fmt.Printf("\n\n%d %s files were skipped as smaller than --min-bytes or --min-lines:\n", len(report.tooSmall), name)
+	for _, path := range sortedKeys(report.tooSmall) {
+		fmt.Printf("  %s (%s)\n", rel(path), report.tooSmall[path])
+	}
+}
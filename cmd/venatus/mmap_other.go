@@ -0,0 +1,14 @@
+//go:build !unix
+
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// mapOpened reads the given open file into memory, on platforms where we don't bother with mmap.
+func mapOpened(f *os.File) ([]byte, func() error, error) {
+	data, err := io.ReadAll(f)
+	return data, func() error { return nil }, err
+}
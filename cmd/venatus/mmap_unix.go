@@ -0,0 +1,29 @@
+//go:build unix
+
+package main
+
+import (
+	"io"
+	"os"
+	"syscall"
+)
+
+// mapOpened maps the given open file into memory read-only. The file may be closed afterwards.
+// The returned function unmaps it again; the data must not be used after that.
+func mapOpened(f *os.File) ([]byte, func() error, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	// Zero-length mappings aren't allowed, but there's nothing to map anyway.
+	if info.Size() == 0 {
+		return nil, func() error { return nil }, nil
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		// Some filesystems don't support mmap; fall back to reading the whole thing.
+		data, err := io.ReadAll(f)
+		return data, func() error { return nil }, err
+	}
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
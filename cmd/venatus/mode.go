@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+var compareMode = flag.String("compare", "code", "what to compare between files: code, symbols (the names of the functions, globals, and macros each declares), strings (the string literals each contains, like error messages and format strings), calls (which functions call which, to catch ports whose function bodies were rewritten but whose structure was copied), or comments (the comments that are otherwise ignored, which are copied along with code, typos and all)")
+
+func checkCompareMode() error {
+	switch *compareMode {
+	case "comments":
+		if *preprocess {
+			return fmt.Errorf("--compare=comments can't be used with --preprocess, which removes comments")
+		}
+		return nil
+	case "code", "symbols", "strings", "calls":
+		return nil
+	}
+	return fmt.Errorf("unknown --compare %q (want code, symbols, strings, calls, or comments)", *compareMode)
+}
+
+// comparesCode reports whether files are compared by diffing their normalized contents: their
+// code, or with --compare=comments, their comments. Other modes compare sets of features
+// extracted from the code instead.
+func comparesCode() bool {
+	return *compareMode == "code" || *compareMode == "comments"
+}
+
+// comparesComments reports whether files are normalized to their comments, rather than their code.
+func comparesComments() bool {
+	return *compareMode == "comments"
+}
+
+// features extracts what --compare says to compare from a file's normalized contents, for modes
+// other than code.
+func features(contents string) fingerprint {
+	switch *compareMode {
+	case "symbols":
+		return fingerprintOf(strings.Join(declaredSymbols(contents), "\n"))
+	case "strings":
+		return fingerprintOf(strings.Join(stringLiterals(contents), "\n"))
+	case "calls":
+		return fingerprintOf(strings.Join(callEdges(contents), "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+var excludeNew = flag.Bool("exclude-new", false, "leave new files (target files with no match) out of the overall score, so that it says how closely the code that came from the source tracks it")
+
+// isNew reports whether a target file is new: that is, nothing in the source is like it.
+func isNew(result *findResult) bool {
+	return result.matchSimilarity <= 0
+}
+
+// newFiles returns the new target files to show, in the order of the results.
+func (c *comparison) newFiles() []*findResult {
+	var files []*findResult
+	for _, result := range c.results {
+		if isNew(result) && shown(result) {
+			files = append(files, result)
+		}
+	}
+	return files
+}
+
+// renderNewFiles tabularizes the new target files, apart from the results table, so that they
+// don't crowd out the files that do have matches.
+func renderNewFiles(c *comparison, files []*findResult) string {
+	tw := table.NewWriter()
+	tw.SetStyle(table.StyleDouble)
+	tw.AppendHeader(table.Row{"New file", "LoC"})
+	lines := 0
+	for _, result := range files {
+		tw.AppendRow(table.Row{c.relTarget(result.filename) + c.tag(result.filename), result.lineCount})
+		lines += result.lineCount
+	}
+	tw.AppendFooter(table.Row{"Total", lines})
+	tw.SetColumnConfigs([]table.ColumnConfig{{Number: 2, Align: text.AlignRight, AlignFooter: text.AlignRight}})
+	return tw.Render()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"sync"
+	"time"
+)
+
+var (
+	niceMode     = flag.Bool("nice", false, "run in the background without starving other work on the machine: lower venatus's CPU priority, compare half as many files at once and read two at a time (unless --workers or --readers say otherwise), and read at most --nice-read-rate")
+	niceReadRate = flag.Int64("nice-read-rate", 16<<20, "with --nice, the most bytes of code files to read per second")
+)
+
+// Nice processes have this priority, as with nice(1)'s default.
+const niceness = 10
+
+func checkNice() error {
+	if !*niceMode {
+		return nil
+	}
+	if *niceReadRate <= 0 {
+		return fmt.Errorf("--nice-read-rate must be positive")
+	}
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	if !set["workers"] {
+		*workers = max(runtime.NumCPU()/2, 1)
+	}
+	if !set["readers"] {
+		*readers = 2
+	}
+	if err := lowerPriority(niceness); err != nil {
+		// Running at full priority is still better than not running.
+		slog.Warn("Couldn't lower priority", "err", err)
+	}
+	readPace.rate = *niceReadRate
+	return nil
+}
+
+// A readThrottle spaces out reads so that they average at most rate bytes a second.
+type readThrottle struct {
+	mu   sync.Mutex
+	rate int64
+	// When the next read may start.
+	next time.Time
+}
+
+// readPace throttles reading code files, with --nice.
+var readPace readThrottle
+
+// wait waits until n more bytes may be read.
+func (t *readThrottle) wait(n int) {
+	if t.rate == 0 {
+		return
+	}
+	t.mu.Lock()
+	now := time.Now()
+	if t.next.Before(now) {
+		t.next = now
+	}
+	start := t.next
+	t.next = t.next.Add(time.Duration(int64(n) * int64(time.Second) / t.rate))
+	t.mu.Unlock()
+	time.Sleep(start.Sub(now))
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// lowerPriority makes the process nicer, so that the scheduler favours other work over it. On
+// Linux, priorities are per thread, so each of the runtime's threads so far is made nicer; the
+// threads they start later inherit it.
+func lowerPriority(niceness int) error {
+	tasks, err := os.ReadDir("/proc/self/task")
+	if err != nil {
+		return syscall.Setpriority(syscall.PRIO_PROCESS, 0, niceness)
+	}
+	for _, task := range tasks {
+		tid, err := strconv.Atoi(task.Name())
+		if err != nil {
+			continue
+		}
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, tid, niceness); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+// lowerPriority does nothing, on platforms where we don't bother; --nice only throttles work there.
+func lowerPriority(niceness int) error {
+	return nil
+}
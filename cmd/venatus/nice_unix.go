@@ -0,0 +1,10 @@
+//go:build unix && !linux
+
+package main
+
+import "syscall"
+
+// lowerPriority makes the process nicer, so that the scheduler favours other work over it.
+func lowerPriority(niceness int) error {
+	return syscall.Setpriority(syscall.PRIO_PROCESS, 0, niceness)
+}
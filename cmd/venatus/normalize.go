@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Flags controlling how code is normalized before it is compared.
+var (
+	normalizeCRLF      = flag.Bool("normalize-crlf", true, "treat CRLF and lone CR line endings as LF")
+	normalizeTabs      = flag.Bool("normalize-tabs", false, "expand tabs to spaces, with tab stops every 8 columns (only matters with --collapse-whitespace=false)")
+	collapseWhitespace = flag.Bool("collapse-whitespace", true, "ignore leading and trailing whitespace, and treat runs of whitespace within lines as a single space")
+	ignoreCase         = flag.Bool("ignore-case", false, "ignore differences in letter case, e.g. for case-insensitive languages like Fortran, Pascal, SQL, and assembler, where forks often change the case of identifiers")
+	strictWhitespace   = flag.Bool("strict-whitespace", false, "compare files byte for byte, with no normalization at all (overriding the flags above), e.g. to check that files were shipped unmodified")
+)
+
+var (
+	ignorePatternFlags multiFlag
+	// Compiled from --ignore-pattern by compileIgnorePatterns.
+	ignorePatterns []*regexp.Regexp
+)
+
+func init() {
+	flag.Var(&ignorePatternFlags, "ignore-pattern", "regular expression whose matches in each line of code are ignored, e.g. version strings or build timestamps (can be repeated)")
+}
+
+func compileIgnorePatterns() error {
+	ignorePatterns = nil
+	for _, pattern := range ignorePatternFlags {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("--ignore-pattern: %w", err)
+		}
+		ignorePatterns = append(ignorePatterns, re)
+	}
+	return nil
+}
+
+// verbatim is a file's contents without normalization, for --strict-whitespace, with its lines
+// numbered as normalizeCode numbers them.
+func verbatim(data []byte) (string, []int) {
+	text := string(data)
+	numbers := make([]int, strings.Count(text, "\n"))
+	for i := range numbers {
+		numbers[i] = i + 1
+	}
+	return text, numbers
+}
+
+// removeIgnored removes whatever --ignore-pattern matches from a line.
+func removeIgnored(line string) string {
+	for _, re := range ignorePatterns {
+		line = re.ReplaceAllString(line, "")
+	}
+	return line
+}
+
+const tabWidth = 8
+
+// normalizeLineEndings turns all line endings into LF, if --normalize-crlf says to.
+// Without this, a file with old Mac-style CR line endings reads as a single line.
+func normalizeLineEndings(text string) string {
+	if !*normalizeCRLF || !strings.Contains(text, "\r") {
+		return text
+	}
+	return strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\r", "\n")
+}
+
+func expandTabs(line string) string {
+	if !strings.Contains(line, "\t") {
+		return line
+	}
+	var sb strings.Builder
+	col := 0
+	for _, r := range line {
+		if r == '\t' {
+			n := tabWidth - col%tabWidth
+			sb.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		}
+		sb.WriteRune(r)
+		col++
+	}
+	return sb.String()
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+var normalizerList = flag.String("normalizers", "", "comma-separated ext=command pairs of programs to run files with each extension through before they are normalized, e.g. \".c=strip-banners --all\"; each gets a file's contents on standard input and its path as its last argument, and writes what should be compared to standard output (in a config file, this can be a list)")
+
+// The external normalizers from --normalizers, by extension, as command lines split into words.
+var normalizers map[string][]string
+
+func checkNormalizers() error {
+	normalizers = make(map[string][]string)
+	if *normalizerList == "" {
+		return nil
+	}
+	for _, pair := range strings.Split(*normalizerList, ",") {
+		ext, command, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		args := strings.Fields(command)
+		if !ok || len(args) == 0 {
+			return fmt.Errorf("--normalizers: %q isn't ext=command", pair)
+		}
+		if _, err := exec.LookPath(args[0]); err != nil {
+			return fmt.Errorf("--normalizers: %w", err)
+		}
+		if ext != "" && !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		normalizers[ext] = args
+	}
+	return nil
+}
+
+// runNormalizer runs a file's contents through the external normalizer for its extension or
+// language, if there is one, reporting whether there was.
+func runNormalizer(filename, text string, lang *language) (string, bool, error) {
+	args, ok := normalizers[filepath.Ext(filename)]
+	if !ok {
+		args = lang.normalizer
+	}
+	if len(args) == 0 {
+		return text, false, nil
+	}
+	cmd := exec.Command(args[0], append(args[1:], filename)...)
+	cmd.Stdin = strings.NewReader(text)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", true, fmt.Errorf("%s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), true, nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// The flags that change how files are normalized, and so which normalized files can be reused.
+var normalizeFlags = []string{
+	"normalize-crlf", "normalize-tabs", "collapse-whitespace", "ignore-case", "ignore-pattern",
+	"strip-if0", "normalize-includes", "strip-license-headers", "compare", "strict-whitespace",
+	"normalizers", "max-line-length", "compose-unicode", "fold-homoglyphs",
+	"ignore-copyright-years", "pragmas", "asm-dialect", "preprocess", "cpp", "include-dir", "define",
+	"extra-languages",
+}
+
+// normalizedCache keeps the normalized contents of files in --cache-dir, keyed by the hash of
+// their raw contents, so that unchanged files needn't be normalized again on the next run.
+// Like the pair cache, it's best-effort: failures to read or write are treated as misses.
+type normalizedCache struct{}
+
+var normalized normalizedCache
+
+// path returns where normalized contents are kept, or "" if they aren't.
+func (normalizedCache) path(data []byte, lang *language) string {
+	// Preprocessed files depend on headers elsewhere too, so they can't be keyed by their contents.
+	if *cacheDir == "" || *preprocess && lang == cLanguage {
+		return ""
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "venatus normalized v1\x00%v\x00", *lang)
+	writeNormalizeFlags(h)
+	h.Write(data)
+	key := hex.EncodeToString(h.Sum(nil))
+	return filepath.Join(*cacheDir, "normalized", key[:2], key)
+}
+
+// writeNormalizeFlags writes the values of normalizeFlags, to be hashed.
+func writeNormalizeFlags(w io.Writer) {
+	for _, name := range normalizeFlags {
+		fmt.Fprintf(w, "%s=%s\x00", name, globalFlags.Lookup(name).Value)
+	}
+}
+
+// normalizationKey hashes the values of normalizeFlags.
+func normalizationKey() [sha256.Size]byte {
+	h := sha256.New()
+	writeNormalizeFlags(h)
+	var key [sha256.Size]byte
+	h.Sum(key[:0])
+	return key
+}
+
+func (n normalizedCache) get(data []byte, lang *language) (string, bool) {
+	path := n.path(data, lang)
+	if path == "" {
+		return "", false
+	}
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	return string(code), true
+}
+
+func (n normalizedCache) put(data []byte, lang *language, code string) {
+	path := n.path(data, lang)
+	if path == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	// Write-then-rename, as for the pair cache.
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.WriteString(code)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+	os.Rename(tmp.Name(), path)
+}
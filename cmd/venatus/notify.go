@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	notifyWebhook  = flag.String("notify-webhook", "", "when the run finishes, POST a JSON summary of it (overall score, worst files, and regressions from --baseline) to this URL, such as a Slack incoming webhook")
+	summaryPath    = flag.String("summary-json", "", "write the JSON summary that --notify-webhook sends to this file, e.g. to be the --baseline of a later run")
+	baselinePath   = flag.String("baseline", "", "a --summary-json file from an earlier run, to report files whose scores have dropped since")
+	notifyWorstLen = flag.Int("notify-worst", 10, "how many of the worst-scoring files the JSON summary lists")
+)
+
+var webhookClient = &http.Client{Timeout: 30 * time.Second}
+
+// A summary of a run, as sent to --notify-webhook. Its text is what Slack shows.
+type summary struct {
+	Text         string             `json:"text"`
+	Target       string             `json:"target"`
+	OverallScore float64            `json:"overall_score"`
+	Files        int                `json:"files"`
+	LineCount    int                `json:"loc"`
+	Worst        []summaryFile      `json:"worst"`
+	Regressions  []summaryFile      `json:"regressions,omitempty"`
+	Scores       map[string]float64 `json:"scores"`
+	Metadata     *runMetadata       `json:"metadata"`
+	// What couldn't be compared, so that the scores' coverage is clear.
+	Coverage *runCoverage `json:"coverage"`
+}
+
+// A summaryFile is a target file in a summary.
+type summaryFile struct {
+	Path  string  `json:"path"`
+	Match string  `json:"match,omitempty"`
+	Score float64 `json:"score"`
+	// With a baseline, what the file scored then.
+	BaselineScore *float64 `json:"baseline_score,omitempty"`
+}
+
+func checkNotify() error {
+	if *notifyWorstLen < 0 {
+		return fmt.Errorf("--notify-worst must not be negative")
+	}
+	if *baselinePath != "" && *notifyWebhook == "" && *summaryPath == "" {
+		return fmt.Errorf("--baseline needs --notify-webhook or --summary-json to report to")
+	}
+	return nil
+}
+
+// summarize sums up a run, comparing its scores with those of the baseline, if there is one.
+func summarize(c *comparison, baseline *summary) *summary {
+	s := &summary{
+		Target:       c.targetRoot,
+		OverallScore: c.overallScore,
+		Files:        len(c.results),
+		LineCount:    c.totalLineCount,
+		Scores:       make(map[string]float64),
+		Metadata:     c.metadata,
+		Coverage:     c.coverage(),
+	}
+	var files []summaryFile
+	for _, result := range c.results {
+		f := summaryFile{Path: c.relTarget(result.filename), Score: result.matchSimilarity}
+		if result.matchedFilename != "N/A" {
+			f.Match = c.sourceLabel(result.matchedFilename)
+		}
+		s.Scores[f.Path] = f.Score
+		files = append(files, f)
+		if baseline == nil {
+			continue
+		}
+		if before, ok := baseline.Scores[f.Path]; ok && f.Score < before {
+			f.BaselineScore = &before
+			s.Regressions = append(s.Regressions, f)
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool { return files[i].Score < files[j].Score })
+	s.Worst = files[:min(len(files), *notifyWorstLen)]
+	sort.SliceStable(s.Regressions, func(i, j int) bool {
+		ri, rj := s.Regressions[i], s.Regressions[j]
+		return ri.Score-*ri.BaselineScore < rj.Score-*rj.BaselineScore
+	})
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "venatus: %s is %v similar to upstream over %d files (%d LoC)", c.targetRoot, percentage(s.OverallScore), s.Files, s.LineCount)
+	if baseline != nil {
+		fmt.Fprintf(&text, ", %v at the baseline; regressed files: %d", percentage(baseline.OverallScore), len(s.Regressions))
+	}
+	for _, f := range s.Regressions[:min(len(s.Regressions), *notifyWorstLen)] {
+		fmt.Fprintf(&text, "\n• %s: %v → %v", f.Path, percentage(*f.BaselineScore), percentage(f.Score))
+	}
+	s.Text = text.String()
+	return s
+}
+
+func readBaseline(path string) (*summary, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s summary
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("--baseline: %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// report writes the run's summary to --summary-json and sends it to --notify-webhook.
+func report(c *comparison) error {
+	if *notifyWebhook == "" && *summaryPath == "" {
+		return nil
+	}
+	var baseline *summary
+	if *baselinePath != "" {
+		var err error
+		if baseline, err = readBaseline(*baselinePath); err != nil {
+			return err
+		}
+	}
+	data, err := json.MarshalIndent(summarize(c, baseline), "", "  ")
+	if err != nil {
+		return err
+	}
+	if *summaryPath != "" {
+		if err := os.WriteFile(*summaryPath, append(data, '\n'), 0644); err != nil {
+			return err
+		}
+	}
+	if *notifyWebhook == "" {
+		return nil
+	}
+	resp, err := webhookClient.Post(*notifyWebhook, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("--notify-webhook: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("--notify-webhook: %s", resp.Status)
+	}
+	return nil
+}
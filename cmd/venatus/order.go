@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+)
+
+var (
+	sortBy      = flag.String("sort", "loc", "order of the results: loc or score (highest first), or path or match (alphabetically)")
+	reverseSort = flag.Bool("reverse", false, "reverse the order of the results")
+)
+
+// resultOrders are the ways results can be sorted, each returning whether a comes before b.
+var resultOrders = map[string]func(a, b *findResult) bool{
+	"loc":   func(a, b *findResult) bool { return a.lineCount > b.lineCount },
+	"score": func(a, b *findResult) bool { return a.matchSimilarity > b.matchSimilarity },
+	"path":  func(a, b *findResult) bool { return a.filename < b.filename },
+	"match": func(a, b *findResult) bool { return a.matchedFilename < b.matchedFilename },
+}
+
+func checkSortOrder() error {
+	if resultOrders[*sortBy] == nil {
+		return fmt.Errorf("unknown --sort %q (want loc, score, path, or match)", *sortBy)
+	}
+	return nil
+}
+
+// sortResults puts results in the order given by --sort and --reverse. Ties are broken by path,
+// so that the order is the same from run to run.
+func sortResults(results []*findResult) {
+	less := resultOrders[*sortBy]
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if *reverseSort {
+			a, b = b, a
+		}
+		if less(a, b) != less(b, a) {
+			return less(a, b)
+		}
+		return a.filename < b.filename
+	})
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sync"
+)
+
+var (
+	outputFormat = flag.String("format", "table", "how to report results: table (for people), or jsonl (a JSON object per line for each target file as soon as it has been compared, then one summing up the run)")
+	liveRows     = flag.Bool("live", false, "with --format=table, also print a row for each target file as soon as it has been compared, before the sorted table at the end")
+)
+
+func checkFormat() error {
+	switch *outputFormat {
+	case "table":
+		return nil
+	case "jsonl":
+		if *liveRows {
+			return fmt.Errorf("--live is only for --format=table; --format=jsonl streams already")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown --format %q (want table or jsonl)", *outputFormat)
+}
+
+// A fileLine is the JSON line reporting a target file with --format=jsonl. Licenses and
+// calibration are only worked out once every file has been compared, so they're left out.
+type fileLine struct {
+	Type       string  `json:"type"`
+	Path       string  `json:"path"`
+	Match      string  `json:"match,omitempty"`
+	Score      float64 `json:"score"`
+	Confidence float64 `json:"confidence"`
+	Lines      int     `json:"lines"`
+	TimedOut   bool    `json:"timed_out,omitempty"`
+	// Scored from fingerprints, once --budget was spent.
+	Approximate bool `json:"approximate,omitempty"`
+}
+
+// A summaryLine is the last JSON line, with --format=jsonl.
+type summaryLine struct {
+	Type  string  `json:"type"`
+	Score float64 `json:"score"`
+	Files int     `json:"files"`
+	Lines int     `json:"lines"`
+	// How the comparison was run.
+	Metadata *runMetadata `json:"metadata"`
+	// What couldn't be compared.
+	Coverage *runCoverage `json:"coverage"`
+}
+
+var streamMu sync.Mutex
+
+// writeLine writes a JSON line to standard output.
+func writeLine(v any) {
+	streamMu.Lock()
+	defer streamMu.Unlock()
+	if err := json.NewEncoder(os.Stdout).Encode(v); err != nil {
+		// Standard output is gone, so there's nowhere better to say so.
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// stream reports a target file as soon as it has been compared, with --format=jsonl or --live.
+func (c *comparison) stream(result *findResult) {
+	if *liveRows && *outputFormat == "table" {
+		c.printRow(result)
+	}
+	if *outputFormat != "jsonl" {
+		return
+	}
+	line := fileLine{
+		Type:        "file",
+		Path:        c.relTarget(result.filename),
+		Score:       result.matchSimilarity,
+		Confidence:  result.confidence,
+		Lines:       result.lineCount,
+		TimedOut:    result.timedOut,
+		Approximate: result.approximate,
+	}
+	if result.matchedFilename != "N/A" {
+		line.Match = c.sourceLabel(result.matchedFilename)
+	}
+	writeLine(line)
+}
+
+// printSummaryLine sums up the run, with --format=jsonl.
+func printSummaryLine(c *comparison) {
+	writeLine(summaryLine{Type: "summary", Score: c.overallScore, Files: len(c.results), Lines: c.totalLineCount, Metadata: c.metadata, Coverage: c.coverage()})
+}
+
+// printRow prints a plain row for a target file that has just been compared, with --live.
+func (c *comparison) printRow(result *findResult) {
+	streamMu.Lock()
+	defer streamMu.Unlock()
+	if c.progress != nil {
+		// The bar draws itself again on its next update.
+		c.progress.Clear()
+	}
+	fmt.Printf("%8v  %s -> %s\n", percentage(result.matchSimilarity), c.relTarget(result.filename), c.sourceLabel(result.matchedFilename))
+}
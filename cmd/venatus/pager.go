@@ -0,0 +1,30 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+var (
+	topFiles   = flag.Int("top", 0, "only show the first this many files in the table, in the order of --sort: the largest by default, or with --sort=score --reverse the lowest-scoring; totals are still of all files (0 shows them all)")
+	plainTable = flag.Bool("plain", false, "print the results table without borders or colors, one plain line per file, to read through a pager or grep")
+)
+
+func checkTop() error {
+	if *topFiles < 0 {
+		return fmt.Errorf("--top must not be negative")
+	}
+	return nil
+}
+
+// plainStyle is the results table's style with --plain: ASCII, with no borders or lines
+// between columns, so that long tables page and grep well.
+func plainStyle() table.Style {
+	style := table.StyleDefault
+	style.Name = "plain"
+	style.Options.DrawBorder = false
+	style.Options.SeparateColumns = false
+	return style
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// pair compares exactly two files, normalized just as in a comparison of repos, and prints their
+// score and the diff of their normalized code. The first file plays the part of the source, and
+// the second the target. The usual flags about normalizing and scoring apply.
+func pair(args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: venatus pair [flags] SOURCE_FILE TARGET_FILE")
+	}
+	if err := setUp(); err != nil {
+		return err
+	}
+	return pairFiles(args[0], args[1])
+}
+
+// pairFiles scores and diffs two files, once the flags have been set up.
+func pairFiles(sourcePath, targetPath string) error {
+	source, err := readPairFile(sourcePath)
+	if err != nil {
+		return err
+	}
+	target, err := readPairFile(targetPath)
+	if err != nil {
+		return err
+	}
+	score, timedOut := similarity(target, source)
+	algorithm := *algorithmName
+	if len(comparatorArgs) > 0 {
+		algorithm = comparatorArgs[0]
+	} else if timedOut {
+		algorithm += " (timed out)"
+	}
+	fmt.Printf("Score: %v (%s)\n", percentage(score), algorithm)
+	if d := unifiedDiff(sourcePath, targetPath, source, target); d != "" {
+		fmt.Print("\n", d)
+	}
+	return nil
+}
+
+// readPairFile reads and normalizes one of the files given to pair.
+func readPairFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	lang := languageOf(path, info)
+	if lang == nil {
+		return "", fmt.Errorf("%s: not a code file venatus knows how to compare", path)
+	}
+	return readCodeFileNormalized(path, lang)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// checkPatches rejects --emit-patches with an index as a source, since patches need the files as
+// they are, which an index doesn't keep.
+func checkPatches() error {
+	if *patchDir == "" {
+		return nil
+	}
+	for _, source := range sources {
+		if !isPackage(source) && isIndexFile(source) {
+			return fmt.Errorf("--emit-patches needs the source repo itself, not an index of it (%s)", source)
+		}
+	}
+	return nil
+}
+
+// emitPatches writes one patch per matched pair into dir, mirroring the layout of the target repo.
+// Each patch turns the target file back into its source match, and applies with `git apply` or
+// `patch -p1` from the root of the target repo.
+// Patches are made from the files as they are on disk, not their normalized contents.
+// Target paths that differ only by case get distinct patch names, so that none of them are lost
+// if dir is on a case-insensitive filesystem.
+func emitPatches(dir string, c *comparison) (int, error) {
+	suffixes := caseSuffixes(c.targetCollisions)
+	written := 0
+	for _, result := range c.results {
+		if result.matchedFilename == "N/A" {
+			continue
+		}
+		targetContents, err := c.readTargetFile(result.filename)
+		if err != nil {
+			return written, err
+		}
+		sourceContents, err := c.readSourceFile(result.matchedFilename)
+		if err != nil {
+			return written, err
+		}
+		rel := c.relTarget(result.filename)
+		patch := unifiedDiff("a/"+rel, "b/"+rel, string(targetContents), string(sourceContents))
+		if patch == "" {
+			continue
+		}
+		out := filepath.Join(dir, rel+suffixes[rel]+".patch")
+		if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+			return written, err
+		}
+		if err := os.WriteFile(out, []byte(patch), 0644); err != nil {
+			return written, fmt.Errorf("writing patch for %q: %w", rel, err)
+		}
+		written++
+	}
+	return written, nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEmitPatches(t *testing.T) {
+	dir := t.TempDir()
+	source, target, out := filepath.Join(dir, "upstream"), filepath.Join(dir, "fork"), filepath.Join(dir, "patches")
+	files := map[string]string{
+		filepath.Join(source, "lib", "util.c"): "/* upstream */\nint add(int a, int b)\n{\n\treturn a + b;\n}\n",
+		filepath.Join(target, "lib", "util.c"): "/* fork */\nint add(int a, int b)\n{\n\treturn b + a;\n}\n",
+	}
+	for path, contents := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := &comparison{
+		sources:    []*sourceRepo{{name: "upstream", root: source}},
+		targetRoot: target,
+		results: []*findResult{
+			{filename: filepath.Join(target, "lib", "util.c"), matchedFilename: filepath.Join(source, "lib", "util.c")},
+			{filename: filepath.Join(target, "lib", "new.c"), matchedFilename: "N/A"},
+		},
+	}
+	n, err := emitPatches(out, c)
+	if err != nil || n != 1 {
+		t.Fatalf("emitPatches() = %d, %v; want 1 patch", n, err)
+	}
+	patch, err := os.ReadFile(filepath.Join(out, "lib", "util.c.patch"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Patches are of the files as they are, comments and all.
+	for _, want := range []string{"--- a/lib/util.c", "-/* fork */", "+/* upstream */", "-\treturn b + a;", "+\treturn a + b;"} {
+		if !strings.Contains(string(patch), want) {
+			t.Errorf("patch has no %q line:\n%s", want, patch)
+		}
+	}
+}
+
+func TestCheckPatchesRejectsIndex(t *testing.T) {
+	defer func(dir string, given repoList) { *patchDir, sources = dir, given }(*patchDir, sources)
+	index := filepath.Join(t.TempDir(), "upstream.vidx")
+	if err := os.WriteFile(index, []byte(indexMagic), 0644); err != nil {
+		t.Fatal(err)
+	}
+	*patchDir, sources = "patches", repoList{index}
+	if err := checkPatches(); err == nil || !strings.Contains(err.Error(), "not an index") {
+		t.Errorf("checkPatches() with an index source: error %v, want one about the index", err)
+	}
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// isPackage reports whether a --source is a package URL (https://github.com/package-url/purl-spec)
+// rather than a path.
+func isPackage(source string) bool {
+	return strings.HasPrefix(source, "pkg:")
+}
+
+// A pkgURL is the part of a package URL that says what to download.
+type pkgURL struct {
+	typ, namespace, name, version, subpath string
+}
+
+func parsePkgURL(purl string) (*pkgURL, error) {
+	rest := strings.TrimPrefix(purl, "pkg:")
+	rest, subpath, _ := strings.Cut(rest, "#")
+	rest, _, _ = strings.Cut(rest, "?")
+	rest, version, ok := strings.Cut(rest, "@")
+	if !ok || version == "" {
+		return nil, fmt.Errorf("%s: package URL needs a version", purl)
+	}
+	parts := strings.Split(strings.Trim(rest, "/"), "/")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("%s: package URL needs a type and a name", purl)
+	}
+	for i, part := range parts {
+		unescaped, err := url.PathUnescape(part)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", purl, err)
+		}
+		parts[i] = unescaped
+	}
+	version, err := url.PathUnescape(version)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", purl, err)
+	}
+	subpath, err = parseSubpath(subpath)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", purl, err)
+	}
+	return &pkgURL{
+		typ:       strings.ToLower(parts[0]),
+		namespace: strings.Join(parts[1:len(parts)-1], "/"),
+		name:      parts[len(parts)-1],
+		version:   version,
+		subpath:   subpath,
+	}, nil
+}
+
+// parseSubpath unescapes the subpath of a package URL, the directory in the package to compare.
+// As the spec says, empty segments are dropped, and . and .. aren't allowed; nor is anything
+// that would lead out of the package once joined to where it's unpacked.
+func parseSubpath(subpath string) (string, error) {
+	var segments []string
+	for _, segment := range strings.Split(subpath, "/") {
+		if segment == "" {
+			continue
+		}
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil {
+			return "", err
+		}
+		if unescaped == "." || unescaped == ".." || strings.ContainsAny(unescaped, `/\`) || filepath.VolumeName(unescaped) != "" {
+			return "", fmt.Errorf("subpath segment %q isn't allowed", unescaped)
+		}
+		segments = append(segments, unescaped)
+	}
+	return strings.Join(segments, "/"), nil
+}
+
+// module is the Go module path of a pkg:golang package.
+func (p *pkgURL) module() string {
+	if p.namespace == "" {
+		return p.name
+	}
+	return p.namespace + "/" + p.name
+}
+
+// archive works out where to download the package from, and whether it's a zip or a tarball.
+func (p *pkgURL) archive() (archiveURL string, isZip bool, err error) {
+	switch p.typ {
+	case "github":
+		if p.namespace == "" {
+			return "", false, fmt.Errorf("pkg:github needs an owner")
+		}
+		return fmt.Sprintf("https://codeload.github.com/%s/%s/tar.gz/%s", p.namespace, p.name, url.PathEscape(p.version)), false, nil
+	case "golang":
+		return fmt.Sprintf("%s/%s/@v/%s.zip", goProxy(), escapeModulePath(p.module()), escapeModulePath(p.version)), true, nil
+	case "cargo":
+		return fmt.Sprintf("https://crates.io/api/v1/crates/%s/%s/download", url.PathEscape(p.name), url.PathEscape(p.version)), false, nil
+	case "pypi":
+		return pypiSdist(p.name, p.version)
+	}
+	return "", false, fmt.Errorf("unsupported package type %q (want github, golang, cargo, or pypi)", p.typ)
+}
+
+// goProxy returns the first proxy in $GOPROXY that can be downloaded from, or the public one.
+func goProxy() string {
+	for _, proxy := range strings.FieldsFunc(os.Getenv("GOPROXY"), func(r rune) bool { return r == ',' || r == '|' }) {
+		if strings.HasPrefix(proxy, "https://") || strings.HasPrefix(proxy, "http://") {
+			return strings.TrimSuffix(proxy, "/")
+		}
+	}
+	return "https://proxy.golang.org"
+}
+
+// escapeModulePath applies the module proxy's case encoding, in which each capital letter is
+// replaced by "!" and its lowercase.
+func escapeModulePath(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if unicode.IsUpper(r) {
+			sb.WriteRune('!')
+			r = unicode.ToLower(r)
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// pypiSdist looks up the source distribution of a release on PyPI.
+func pypiSdist(name, version string) (string, bool, error) {
+	body, err := download(fmt.Sprintf("https://pypi.org/pypi/%s/%s/json", url.PathEscape(name), url.PathEscape(version)))
+	if err != nil {
+		return "", false, err
+	}
+	var release struct {
+		URLs []struct {
+			PackageType string `json:"packagetype"`
+			Filename    string `json:"filename"`
+			URL         string `json:"url"`
+		} `json:"urls"`
+	}
+	if err := json.Unmarshal(body, &release); err != nil {
+		return "", false, fmt.Errorf("pypi %s %s: %w", name, version, err)
+	}
+	for _, u := range release.URLs {
+		if u.PackageType == "sdist" {
+			return u.URL, strings.HasSuffix(u.Filename, ".zip"), nil
+		}
+	}
+	return "", false, fmt.Errorf("pypi %s %s has no source distribution", name, version)
+}
+
+var pkgClient = &http.Client{Timeout: 5 * time.Minute}
+
+func download(u string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	// crates.io turns away requests without a User-Agent.
+	req.Header.Set("User-Agent", "venatus (https://github.com/chrisfenner/venatus)")
+	resp, err := pkgClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", u, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// fetchPackage downloads and unpacks the package named by a package URL, returning the directory
+// it was unpacked into. Packages are kept in the user's cache directory, so each version is only
+// downloaded once.
+func fetchPackage(purl string) (string, error) {
+	p, err := parsePkgURL(purl)
+	if err != nil {
+		return "", err
+	}
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	key := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%s\x00%s", p.typ, p.namespace, p.name, p.version)))
+	dir := filepath.Join(cache, "venatus", "pkg", hex.EncodeToString(key[:8]))
+	root := filepath.Join(dir, filepath.FromSlash(p.subpath))
+	if _, err := os.Stat(dir); err == nil {
+		slog.Info("Using cached package", "package", purl, "path", dir)
+		return root, nil
+	}
+
+	archiveURL, isZip, err := p.archive()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", purl, err)
+	}
+	slog.Info("Downloading package", "package", purl, "url", archiveURL)
+	data, err := download(archiveURL)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", purl, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return "", err
+	}
+	// Unpack next to where the package belongs and move it into place once it's complete, so that
+	// an interrupted download isn't mistaken for a cached one.
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), "tmp-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	// Module zips are laid out under module@version/, and the module path has slashes in it.
+	var prefix string
+	if p.typ == "golang" {
+		prefix = p.module() + "@" + p.version
+	}
+	if isZip {
+		err = unzip(data, tmp, prefix)
+	} else {
+		err = untar(data, tmp, prefix)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", purl, err)
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		// Fine if another run got there first.
+		if _, serr := os.Stat(dir); serr != nil {
+			return "", err
+		}
+	}
+	return root, nil
+}
+
+// archivePath works out where an archive entry should be unpacked to. Archives from all the
+// supported registries put everything under a top-level directory, which is dropped: prefix if
+// given, or else whatever the first path component is.
+// Returns "" for entries that should be skipped.
+func archivePath(dest, name, prefix string) (string, error) {
+	name = path.Clean(strings.TrimPrefix(name, "./"))
+	var rel string
+	var ok bool
+	if prefix != "" {
+		rel, ok = strings.CutPrefix(name, prefix+"/")
+	} else {
+		_, rel, ok = strings.Cut(name, "/")
+	}
+	if !ok {
+		return "", nil
+	}
+	if path.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("archive entry %q is outside the archive", name)
+	}
+	return filepath.Join(dest, filepath.FromSlash(rel)), nil
+}
+
+func writeArchiveFile(dest string, r io.Reader, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	// Keep the executable bit, which is how scripts without an extension are recognized.
+	f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm|0600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func untar(data []byte, dest, prefix string) error {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		path, err := archivePath(dest, hdr.Name, prefix)
+		if err != nil {
+			return err
+		}
+		if path == "" {
+			continue
+		}
+		if err := writeArchiveFile(path, tr, hdr.FileInfo().Mode().Perm()); err != nil {
+			return err
+		}
+	}
+}
+
+func unzip(data []byte, dest, prefix string) error {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		path, err := archivePath(dest, f.Name, prefix)
+		if err != nil {
+			return err
+		}
+		if path == "" {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = writeArchiveFile(path, r, f.Mode().Perm())
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePkgURL(t *testing.T) {
+	tests := []struct {
+		purl string
+		want *pkgURL
+	}{
+		{"pkg:github/madler/zlib@v1.3.1", &pkgURL{typ: "github", namespace: "madler", name: "zlib", version: "v1.3.1"}},
+		{"pkg:golang/golang.org/x/crypto@v0.21.0#ssh/agent", &pkgURL{typ: "golang", namespace: "golang.org/x", name: "crypto", version: "v0.21.0", subpath: "ssh/agent"}},
+		{"pkg:cargo/ring@0.17.8?arch=x86#/src//aes/", &pkgURL{typ: "cargo", name: "ring", version: "0.17.8", subpath: "src/aes"}},
+		{"pkg:pypi/cffi@1.16.0#c%20src", &pkgURL{typ: "pypi", name: "cffi", version: "1.16.0", subpath: "c src"}},
+		{"pkg:github/madler/zlib", nil},
+		{"pkg:zlib@v1.3.1", nil},
+		{"pkg:github/madler/zlib@v1.3.1#../../etc", nil},
+		{"pkg:github/madler/zlib@v1.3.1#contrib/../..", nil},
+		{"pkg:github/madler/zlib@v1.3.1#./contrib", nil},
+		{"pkg:github/madler/zlib@v1.3.1#%2E%2E/etc", nil},
+		{"pkg:github/madler/zlib@v1.3.1#contrib%2F..%2F..", nil},
+		{`pkg:github/madler/zlib@v1.3.1#..\..\etc`, nil},
+	}
+	for _, tt := range tests {
+		got, err := parsePkgURL(tt.purl)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("parsePkgURL(%q) = %+v, want an error", tt.purl, *got)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parsePkgURL(%q) = %+v, %v; want %+v", tt.purl, got, err, *tt.want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+)
+
+var honorPragmas = flag.Bool("pragmas", true, "honor venatus: comments in code: a file with venatus:ignore-file in its first 4 KB is left out, like an excluded generated file, and lines from venatus:ignore-begin to venatus:ignore-end are left out of comparisons")
+
+const (
+	ignoreFilePragma  = "venatus:ignore-file"
+	ignoreBeginPragma = "venatus:ignore-begin"
+	ignoreEndPragma   = "venatus:ignore-end"
+)
+
+// What files left out by venatus:ignore-file are listed as.
+const pragmaKind = "venatus:ignore-file"
+
+// hasIgnoreFilePragma reports whether a file asks to be left out, with venatus:ignore-file in a
+// comment near its top. Like generator markers, the pragma is only looked for there, so that big
+// files needn't be read twice.
+func hasIgnoreFilePragma(t tree, name string, lang *language) bool {
+	if !*honorPragmas {
+		return false
+	}
+	head := t.head(name, generatedHeaderLen)
+	if !bytes.Contains(head, []byte(ignoreFilePragma)) {
+		return false
+	}
+	var state lexState
+	for _, line := range strings.Split(string(head), "\n") {
+		var comments string
+		_, _, comments, state = lexLineComments(line, lang, state)
+		if strings.Contains(comments, ignoreFilePragma) {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoredRegion works out whether a line is in a region marked with venatus:ignore-begin and
+// venatus:ignore-end, given the text of the comments on the line, and whether the line before
+// was in the region. The marker lines themselves are ignored too. It returns whether to skip the
+// line, and whether the next line is in the region.
+func ignoredRegion(comments string, ignoring bool) (skip, next bool) {
+	if !*honorPragmas {
+		return false, false
+	}
+	switch {
+	case strings.Contains(comments, ignoreBeginPragma):
+		return true, true
+	case strings.Contains(comments, ignoreEndPragma):
+		return true, false
+	}
+	return ignoring, ignoring
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	stripIfZero       = flag.Bool("strip-if0", false, "in C files, drop #if 0 blocks, which are dead code")
+	normalizeIncludes = flag.Bool("normalize-includes", false, "in C files, compare #include lines by the included file's name alone, ignoring its directory and quotes vs. brackets")
+)
+
+var includePattern = regexp.MustCompile(`^\s*#\s*include\s*[<"]([^>"]+)[>"]`)
+
+// directive returns the name of the preprocessor directive on the line, and the rest of the
+// line, or "" if it isn't a directive.
+func directive(line string) (string, string) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(line), "#")
+	if !ok {
+		return "", ""
+	}
+	rest = strings.TrimSpace(rest)
+	end := strings.IndexFunc(rest, func(r rune) bool { return !('a' <= r && r <= 'z') })
+	if end < 0 {
+		return rest, ""
+	}
+	return rest[:end], strings.TrimSpace(rest[end:])
+}
+
+// isFalse reports whether an #if condition is a literal 0, ignoring any comment after it.
+func isFalse(condition string) bool {
+	condition, _, _ = strings.Cut(condition, "//")
+	condition, _, _ = strings.Cut(condition, "/*")
+	condition = strings.TrimSpace(condition)
+	for strings.HasPrefix(condition, "(") && strings.HasSuffix(condition, ")") {
+		condition = strings.TrimSpace(condition[1 : len(condition)-1])
+	}
+	return condition == "0"
+}
+
+// ifZeroLines finds the lines of C code in #if 0 blocks, along with their directives, and returns
+// which lines they are. An #else or #elif branch of such a block is left alone, since it is the
+// one that is compiled.
+func ifZeroLines(lines []string) []bool {
+	// The conditional blocks the current line is in, innermost last.
+	type block int
+	const (
+		live      block = iota // an ordinary block
+		deadIf                 // the #if 0 itself, up to its #else
+		deadInner              // any block within dead code
+		liveElse               // the #else of an #if 0
+	)
+	var stack []block
+	dead := func() bool {
+		return len(stack) > 0 && (stack[len(stack)-1] == deadIf || stack[len(stack)-1] == deadInner)
+	}
+	dropped := make([]bool, len(lines))
+	for i, line := range lines {
+		name, rest := directive(line)
+		drop := dead()
+		switch name {
+		case "if", "ifdef", "ifndef":
+			switch {
+			case dead():
+				stack = append(stack, deadInner)
+			case name == "if" && isFalse(rest):
+				stack = append(stack, deadIf)
+				drop = true
+			default:
+				stack = append(stack, live)
+			}
+		case "else", "elif":
+			if len(stack) > 0 && stack[len(stack)-1] == deadIf {
+				stack[len(stack)-1] = liveElse
+				drop = true
+			}
+		case "endif":
+			if len(stack) > 0 {
+				if stack[len(stack)-1] == liveElse || stack[len(stack)-1] == deadIf {
+					drop = true
+				}
+				stack = stack[:len(stack)-1]
+			}
+		}
+		dropped[i] = drop
+	}
+	return dropped
+}
+
+// normalizeInclude rewrites an #include line to name just the included file.
+func normalizeInclude(line string) string {
+	if m := includePattern.FindStringSubmatch(line); m != nil {
+		return "#include <" + path.Base(m[1]) + ">"
+	}
+	return line
+}
+
+var (
+	preprocess  = flag.Bool("preprocess", false, "run C files through the C preprocessor before comparing them, so that code rewritten in terms of macros still matches")
+	cppCommand  = flag.String("cpp", "cpp", "the C preprocessor to run with --preprocess")
+	cppIncludes multiFlag
+	cppDefines  multiFlag
+)
+
+func init() {
+	flag.Var(&cppIncludes, "include-dir", "with --preprocess, a directory to search for included headers (can be repeated)")
+	flag.Var(&cppDefines, "define", "with --preprocess, a macro to define, as NAME or NAME=VALUE (can be repeated)")
+}
+
+// A multiFlag is a flag that can be repeated.
+type multiFlag []string
+
+func (m *multiFlag) String() string {
+	return strings.Join(*m, " ")
+}
+
+func (m *multiFlag) Set(value string) error {
+	*m = append(*m, value)
+	return nil
+}
+
+// How many missing headers to put up with in one file before giving up on preprocessing it.
+const maxMissingHeaders = 50
+
+// A missing header is reported like `<stdin>:12:10: fatal error: foo.h: No such file or directory`.
+var missingHeaderPattern = regexp.MustCompile(`<stdin>:(\d+):\d+: fatal error: .*: No such file or directory`)
+
+// runPreprocessor preprocesses C code from the given file, returning only the code that came from
+// the file itself, and not from the headers it includes. Headers that can't be found are left
+// out, so that code can be preprocessed without all of its dependencies being at hand.
+func runPreprocessor(filename, code string) (string, error) {
+	args := []string{"-iquote", filepath.Dir(filename)}
+	for _, dir := range cppIncludes {
+		args = append(args, "-I", dir)
+	}
+	for _, define := range cppDefines {
+		args = append(args, "-D", define)
+	}
+	args = append(args, "-")
+
+	lines := strings.Split(code, "\n")
+	for attempt := 0; ; attempt++ {
+		cmd := exec.Command(*cppCommand, args...)
+		cmd.Stdin = strings.NewReader(strings.Join(lines, "\n"))
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err == nil {
+			return ownLines(string(out)), nil
+		}
+		m := missingHeaderPattern.FindStringSubmatch(stderr.String())
+		if m == nil || attempt == maxMissingHeaders {
+			return "", fmt.Errorf("%s: %w: %s", *cppCommand, err, strings.TrimSpace(stderr.String()))
+		}
+		// Blank out the #include rather than deleting it, so that line numbers stay the same.
+		n, _ := strconv.Atoi(m[1])
+		if n < 1 || n > len(lines) {
+			return "", fmt.Errorf("%s: %w: %s", *cppCommand, err, strings.TrimSpace(stderr.String()))
+		}
+		lines[n-1] = ""
+	}
+}
+
+// ownLines picks the lines of preprocessor output that came from standard input, going by the
+// `# <line> "<file>"` markers the preprocessor puts out whenever it switches files.
+func ownLines(out string) string {
+	var sb strings.Builder
+	own := false
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "# ") {
+			if fields := strings.Fields(line); len(fields) >= 3 && strings.HasPrefix(fields[2], `"`) {
+				own = fields[2] == `"<stdin>"`
+				continue
+			}
+		}
+		if own {
+			sb.WriteString(line)
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+var (
+	queryFlags = flag.NewFlagSet("query", flag.ContinueOnError)
+	queryIndex = queryFlags.String("index", "", "index file made with venatus index, to compare the targets against")
+)
+
+// query compares one or more targets against an index made with venatus index:
+//
+//	venatus query --index repo.idx --target path/to/vendor-drop
+//	venatus query --index repo.idx drops/*
+//
+// A single target is reported in full, as by a comparison of repos. Several are summed up a line
+// each, which suits scanning every incoming vendor drop against one canonical upstream. The index
+// is only read, never walked or normalized again.
+func query(args []string) error {
+	targets := args
+	if *target != "" {
+		targets = append([]string{*target}, targets...)
+	}
+	if *queryIndex == "" || len(targets) == 0 || len(sources) > 0 {
+		return errors.New("usage: venatus query --index FILE [flags] [--target] TARGET...")
+	}
+	if !isIndexFile(*queryIndex) {
+		return fmt.Errorf("--index %s: not an index file", *queryIndex)
+	}
+	if err := setUp(); err != nil {
+		return err
+	}
+	sources = repoList{*queryIndex}
+	if len(targets) == 1 {
+		c, err := compare(sources, targets[0])
+		if err != nil {
+			return err
+		}
+		if *outputFormat == "jsonl" {
+			printSummaryLine(c)
+		} else {
+			printReport(c)
+		}
+		return nil
+	}
+
+	tw := table.NewWriter()
+	tw.SetStyle(table.StyleDouble)
+	tw.AppendHeader(table.Row{"Target", "Score", "Files matched", "LoC"})
+	for _, t := range targets {
+		c, err := compare(sources, t)
+		if err != nil {
+			return fmt.Errorf("%s: %w", t, err)
+		}
+		if *outputFormat == "jsonl" {
+			printSummaryLine(c)
+			continue
+		}
+		matched := 0
+		for _, result := range c.results {
+			if !isNew(result) {
+				matched++
+			}
+		}
+		tw.AppendRow(table.Row{t, percentage(c.overallScore), fmt.Sprintf("%d of %d", matched, len(c.results)), c.totalLineCount})
+	}
+	if *outputFormat == "jsonl" {
+		return nil
+	}
+	tw.SetColumnConfigs([]table.ColumnConfig{
+		{Number: 2, Align: text.AlignRight},
+		{Number: 3, Align: text.AlignRight},
+		{Number: 4, Align: text.AlignRight},
+	})
+	fmt.Println(tw.Render())
+	return nil
+}
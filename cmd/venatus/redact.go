@@ -0,0 +1,106 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+var redactPath = flag.String("redact", "", "write a redacted report to this JSON file, for sharing with outside parties: only relative paths, hashes of files, lines of code, and scores, with no code, diffs, or absolute paths")
+
+// A redactedReport is a report that reveals nothing about the code compared beyond its layout.
+type redactedReport struct {
+	Version string         `json:"version"`
+	Started string         `json:"started"`
+	Sources []repoMetadata `json:"sources"`
+	Target  repoMetadata   `json:"target"`
+	Score   float64        `json:"score"`
+	Lines   int            `json:"lines"`
+	Files   []redactedFile `json:"files"`
+}
+
+// A redactedFile is a target file in a redacted report. Its hashes are of the files' raw
+// contents, so that whoever has the files can check which were compared.
+type redactedFile struct {
+	Path        string  `json:"path"`
+	SHA256      string  `json:"sha256,omitempty"`
+	Match       string  `json:"match,omitempty"`
+	MatchSHA256 string  `json:"match_sha256,omitempty"`
+	Lines       int     `json:"lines"`
+	Score       float64 `json:"score"`
+	Confidence  float64 `json:"confidence"`
+}
+
+// fileHash returns the SHA-256 of a file's contents, or "" if it can't be read (as when the
+// source is an index).
+func fileHash(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// redactedNames names the source repos for a redacted report. Repos are usually named by their
+// directory, but by the path given if two share a directory name; a redacted report never has
+// the paths, so those are numbered instead.
+func redactedNames(sources []*sourceRepo) []string {
+	names := make([]string, len(sources))
+	seen := make(map[string]int)
+	for i, s := range sources {
+		names[i] = filepath.Base(filepath.Clean(s.name))
+		seen[names[i]]++
+	}
+	for i, name := range names {
+		if seen[name] > 1 {
+			names[i] = fmt.Sprintf("%s-%d", name, i+1)
+		}
+	}
+	return names
+}
+
+// writeRedacted writes a redacted report of a comparison to path.
+func writeRedacted(path string, c *comparison) error {
+	report := redactedReport{
+		Version: c.metadata.Version,
+		Started: c.metadata.Started.Format("2006-01-02T15:04:05Z07:00"),
+		Target:  repoMetadata{Commit: c.metadata.Target.Commit},
+		Score:   c.overallScore,
+		Lines:   c.totalLineCount,
+	}
+	names := redactedNames(c.sources)
+	for i, s := range c.metadata.Sources {
+		report.Sources = append(report.Sources, repoMetadata{Name: names[i], Commit: s.Commit})
+	}
+	for _, result := range c.results {
+		f := redactedFile{
+			Path:       c.relTarget(result.filename),
+			SHA256:     fileHash(result.filename),
+			Lines:      result.lineCount,
+			Score:      result.matchSimilarity,
+			Confidence: result.confidence,
+		}
+		if result.matchedFilename != "N/A" {
+			f.Match = c.relSource(result.matchedFilename)
+			if len(c.sources) > 1 {
+				for i, s := range c.sources {
+					if s == c.sourceOf(result.matchedFilename) {
+						f.Match = names[i] + ":" + f.Match
+					}
+				}
+			}
+			f.MatchSHA256 = fileHash(result.matchedFilename)
+		}
+		report.Files = append(report.Files, f)
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+var bestRegions = flag.Bool("best-region", false, "for target files that don't match well as a whole, find the contiguous region that matches their match best, and report its lines and similarity")
+
+// Regions shorter than this many lines of code aren't worth reporting.
+const minRegionLines = 5
+
+// An alignStep is a line of the line diff of a target file against a source file, with the
+// indices of the normalized lines it is at in each.
+type alignStep struct {
+	op             diffmatchpatch.Operation
+	target, source int
+}
+
+func alignSteps(source, target string) []alignStep {
+	var steps []alignStep
+	t, s := 0, 0
+	for _, line := range lineDiff(source, target) {
+		steps = append(steps, alignStep{line.op, t, s})
+		switch line.op {
+		case diffmatchpatch.DiffEqual:
+			t++
+			s++
+		case diffmatchpatch.DiffInsert:
+			t++
+		case diffmatchpatch.DiffDelete:
+			s++
+		}
+	}
+	return steps
+}
+
+// spanOf returns the lines that a span of steps covers in each file, as indices of normalized
+// lines, inclusive, and their similarity. It also returns how many target lines the span covers.
+func spanOf(steps []alignStep) (r snippet, targetLines int) {
+	r.targetStart, r.sourceStart = -1, -1
+	equal, sourceLines := 0, 0
+	for _, st := range steps {
+		if st.op != diffmatchpatch.DiffDelete {
+			if r.targetStart < 0 {
+				r.targetStart = st.target
+			}
+			r.targetEnd = st.target
+			targetLines++
+		}
+		if st.op != diffmatchpatch.DiffInsert {
+			if r.sourceStart < 0 {
+				r.sourceStart = st.source
+			}
+			r.sourceEnd = st.source
+			sourceLines++
+		}
+		if st.op == diffmatchpatch.DiffEqual {
+			equal++
+		}
+	}
+	r.similarity = 2 * float64(equal) / float64(max(1, targetLines+sourceLines))
+	return r, targetLines
+}
+
+// alignedRegion finds the contiguous region of target's lines that best matches a region of
+// source's: the one with the most lines in common, less the lines that differ. It returns the
+// region as indices of normalized lines, inclusive, or false if no region is long enough.
+func alignedRegion(source, target string) (snippet, bool) {
+	steps := alignSteps(source, target)
+	// The best span of steps, by the sum of +1 for each equal line and -1 for each other.
+	best, bestStart, bestEnd := 0, 0, -1
+	sum, start := 0, 0
+	for i, st := range steps {
+		if sum <= 0 {
+			sum, start = 0, i
+		}
+		if st.op == diffmatchpatch.DiffEqual {
+			sum++
+		} else {
+			sum--
+		}
+		if sum > best {
+			best, bestStart, bestEnd = sum, start, i
+		}
+	}
+	if bestEnd < 0 {
+		return snippet{}, false
+	}
+	r, targetLines := spanOf(steps[bestStart : bestEnd+1])
+	return r, targetLines >= minRegionLines
+}
+
+// matchRegions finds the best-matching region of each target file that doesn't match well as a
+// whole, with --best-region.
+func (c *comparison) matchRegions() {
+	if c.lineNumbers == nil {
+		c.lineNumbers = make(map[string][]int)
+	}
+	for _, result := range c.results {
+		source, ok := c.sourceContents(result.matchedFilename)
+		if !ok || result.matchSimilarity >= snippetFileScore {
+			continue
+		}
+		r, ok := alignedRegion(source, c.targetContents(result.filename))
+		if !ok {
+			continue
+		}
+		r.sourceFile = result.matchedFilename
+		r.targetStart, r.targetEnd = c.fileLine(result.filename, r.targetStart), c.fileLine(result.filename, r.targetEnd)
+		r.sourceStart, r.sourceEnd = c.fileLine(r.sourceFile, r.sourceStart), c.fileLine(r.sourceFile, r.sourceEnd)
+		result.region = &r
+	}
+}
+
+// printRegions lists the best-matching regions found with --best-region.
+func printRegions(c *comparison) {
+	var found []*findResult
+	for _, result := range c.results {
+		if result.region != nil {
+			found = append(found, result)
+		}
+	}
+	if len(found) == 0 {
+		return
+	}
+	fmt.Printf("\n\nBest-matching regions of %d target files that don't match well as a whole:\n", len(found))
+	for _, result := range found {
+		r := result.region
+		fmt.Printf("  %s lines %d-%d ~ %s lines %d-%d (%v, whole file %v)\n", c.relTarget(result.filename), r.targetStart, r.targetEnd,
+			c.sourceLabel(r.sourceFile), r.sourceStart, r.sourceEnd, percentage(r.similarity), percentage(result.matchSimilarity))
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import "fmt"
+
+// Matches scoring at least this, between files whose names the filename filter would have kept
+// apart, are reported as renames.
+const renameScore = 0.95
+
+// isRename reports whether a result looks like its target file is a renamed copy of its match.
+func isRename(result *findResult) bool {
+	return result.matchSimilarity >= renameScore && !filenamesCloseEnough(result.filename, result.matchedFilename)
+}
+
+// printRenames lists target files that look like renamed copies of source files. Without
+// --no-filename-filter, only exact copies (after normalization) can be found.
+func printRenames(c *comparison) {
+	var renamed []*findResult
+	for _, result := range c.results {
+		if isRename(result) {
+			renamed = append(renamed, result)
+		}
+	}
+	if len(renamed) == 0 {
+		return
+	}
+	fmt.Printf("\n\n%d target files look like renamed source files:\n", len(renamed))
+	for _, result := range renamed {
+		fmt.Printf("  %s <- %s (%v)\n", c.relTarget(result.filename), c.sourceLabel(result.matchedFilename), percentage(result.matchSimilarity))
+	}
+}
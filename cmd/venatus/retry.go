@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+var readRetries = flag.Int("read-retries", 3, "how many times to retry reading a file after a possibly-transient error, with exponential backoff")
+
+// A walkReport records how much of a repo was read, and trouble encountered while reading it.
+type walkReport struct {
+	// How many code files were read, and their total size.
+	examined      int
+	examinedBytes int64
+	// Files that could only be read after retrying, and how many retries each took.
+	retried map[string]int
+	// Files and directories that couldn't be read at all, and why.
+	unreadable map[string]error
+	// Sizes of the unreadable files, where they are known.
+	unreadableSizes map[string]int64
+	// Which of the unreadable paths are directories.
+	unreadableDirs map[string]bool
+	// Files skipped for being larger than --max-file-size, and their sizes.
+	tooLarge map[string]int64
+	// Files skipped for being smaller than --min-bytes or --min-lines, and how small they are.
+	tooSmall map[string]string
+	// Files that look generated or vendored, and which, with --generated=tag or exclude.
+	tagged, excluded map[string]string
+}
+
+func newWalkReport() *walkReport {
+	return &walkReport{
+		retried:         make(map[string]int),
+		unreadable:      make(map[string]error),
+		unreadableSizes: make(map[string]int64),
+		unreadableDirs:  make(map[string]bool),
+		tooLarge:        make(map[string]int64),
+		tooSmall:        make(map[string]string),
+		tagged:          make(map[string]string),
+		excluded:        make(map[string]string),
+	}
+}
+
+// skipped records a path that the walk couldn't get into.
+func (w *walkReport) skipped(path string, info fs.FileInfo, err error) {
+	slog.Warn("Couldn't read path", "path", path, "err", err)
+	w.unreadable[path] = err
+	if info == nil {
+		return
+	}
+	if info.IsDir() {
+		w.unreadableDirs[path] = true
+	} else {
+		w.unreadableSizes[path] = info.Size()
+	}
+}
+
+// isTransient reports whether a read error might go away if we try again, as errors from network
+// filesystems often do. Errors that certainly won't are the only ones we know about.
+func isTransient(err error) bool {
+	return !errors.Is(err, fs.ErrNotExist) && !errors.Is(err, fs.ErrPermission) && !errors.Is(err, bufio.ErrTooLong)
+}
+
+// read reads and normalizes a code file, retrying if that fails transiently.
+// Files that can't be read are recorded and skipped, rather than abandoning the whole walk.
+func (w *walkReport) read(t tree, name string, lang *language) (string, bool) {
+	path := t.path(name)
+	backoff := 100 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		code, err := t.readNormalized(name, lang)
+		if err == nil {
+			if attempt > 0 {
+				w.retried[path] = attempt
+				slog.Info("Read file after retrying", "path", path, "retries", attempt)
+			}
+			return code, true
+		}
+		if attempt >= *readRetries || !isTransient(err) {
+			w.unreadable[path] = err
+			slog.Warn("Couldn't read file", "path", path, "err", err)
+			return "", false
+		}
+		slog.Debug("Retrying read", "path", path, "err", err, "backoff", backoff)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// printReadIssues reports files that needed retries or couldn't be read, in either repo.
+func printReadIssues(c *comparison) {
+	for _, repo := range []struct {
+		name   string
+		rel    func(string) string
+		report *walkReport
+	}{
+		{"source", c.sourceLabel, c.sourceReport},
+		{"target", c.relTarget, c.targetReport},
+	} {
+		if len(repo.report.retried) > 0 {
+			fmt.Printf("\n\n%d %s files could only be read after retrying:\n", len(repo.report.retried), repo.name)
+			for _, path := range sortedKeys(repo.report.retried) {
+				fmt.Printf("  %s (%d retries)\n", repo.rel(path), repo.report.retried[path])
+			}
+		}
+		printTooLarge(repo.name, repo.rel, repo.report)
+		printTooSmall(repo.name, repo.rel, repo.report)
+		printExcluded(repo.name, repo.rel, repo.report)
+		printCoverage(repo.name, repo.rel, repo.report)
+	}
+}
+
+// merge adds what happened in another walk to this report.
+func (w *walkReport) merge(other *walkReport) {
+	for path, retries := range other.retried {
+		w.retried[path] = retries
+	}
+	for path, err := range other.unreadable {
+		w.unreadable[path] = err
+	}
+	for path, size := range other.unreadableSizes {
+		w.unreadableSizes[path] = size
+	}
+	for path := range other.unreadableDirs {
+		w.unreadableDirs[path] = true
+	}
+	for path, size := range other.tooLarge {
+		w.tooLarge[path] = size
+	}
+	for path, size := range other.tooSmall {
+		w.tooSmall[path] = size
+	}
+	for path, kind := range other.tagged {
+		w.tagged[path] = kind
+	}
+	for path, kind := range other.excluded {
+		w.excluded[path] = kind
+	}
+	w.examined += other.examined
+	w.examinedBytes += other.examinedBytes
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
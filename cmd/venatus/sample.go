@@ -0,0 +1,115 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	samplePercent = flag.String("sample", "", "only compare a random sample of this percentage of the target files, e.g. 10%, and estimate the overall score from them, for a quick idea before a full run")
+	sampleFiles   = flag.Int("sample-files", 0, "only compare a random sample of this many target files, as with --sample")
+	sampleSeed    = flag.Int64("sample-seed", 0, "seed for choosing the --sample, to choose the same files again (0 for a different sample each run)")
+)
+
+// The fraction of target files to sample, from --sample.
+var sampleFraction float64
+
+func checkSample() error {
+	if *samplePercent != "" {
+		if *sampleFiles != 0 {
+			return fmt.Errorf("--sample and --sample-files can't be used together")
+		}
+		p, err := strconv.ParseFloat(strings.TrimSuffix(*samplePercent, "%"), 64)
+		if err != nil || p <= 0 || p > 100 {
+			return fmt.Errorf("--sample must be a percentage from 0 to 100, like 10%%")
+		}
+		sampleFraction = p / 100
+	}
+	if *sampleFiles < 0 {
+		return fmt.Errorf("--sample-files must not be negative")
+	}
+	return nil
+}
+
+func sampling() bool {
+	return sampleFraction > 0 || *sampleFiles > 0
+}
+
+// sample chooses the target files to compare with --sample or --sample-files, uniformly at
+// random. Scores are weighted as usual once compared, which makes the weighted mean of the
+// sample's scores an estimate of the overall score; choosing heavier files more often as well
+// would count their weight twice.
+func (c *comparison) sample(targetFiles map[string]string) map[string]string {
+	if !sampling() {
+		return targetFiles
+	}
+	n := *sampleFiles
+	if sampleFraction > 0 {
+		n = int(math.Ceil(sampleFraction * float64(len(targetFiles))))
+	}
+	c.population = len(targetFiles)
+	if n >= len(targetFiles) {
+		return targetFiles
+	}
+	seed := *sampleSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(seed))
+	paths := sortedKeys(targetFiles)
+	rng.Shuffle(len(paths), func(i, j int) { paths[i], paths[j] = paths[j], paths[i] })
+	sampled := make(map[string]string, n)
+	for _, path := range paths[:n] {
+		sampled[path] = targetFiles[path]
+	}
+	slog.Info("Sampling target files", "files", n, "of", len(targetFiles), "seed", seed)
+	return sampled
+}
+
+// estimate returns the overall score estimated from a sample, and the half-width of its 95%
+// confidence interval. The estimate is the sample's weighted mean score, a ratio estimator, whose
+// variance comes from how far each file's weighted score is from it.
+func (c *comparison) estimate() (float64, float64) {
+	n := float64(len(c.results))
+	if n == 0 {
+		return 0, 0
+	}
+	var score, weight float64
+	for _, result := range c.results {
+		score += result.matchSimilarity * result.weight()
+		weight += result.weight()
+	}
+	if weight == 0 {
+		return 0, 1
+	}
+	mean := score / weight
+	if n < 2 {
+		return mean, 1
+	}
+	var sumSquares float64
+	for _, result := range c.results {
+		d := result.weight() * (result.matchSimilarity - mean)
+		sumSquares += d * d
+	}
+	meanWeight := weight / n
+	variance := sumSquares / (n - 1) / (meanWeight * meanWeight)
+	// Sampling a good part of the files leaves less to be uncertain about.
+	correction := 1 - n/float64(c.population)
+	return mean, 1.96 * math.Sqrt(max(0, variance)/n*max(0, correction))
+}
+
+// printEstimate reports the overall score estimated from a --sample.
+func printEstimate(c *comparison) {
+	if !sampling() || c.population == 0 {
+		return
+	}
+	mean, margin := c.estimate()
+	fmt.Printf("\n\nEstimated overall score from a sample of %d of %d target files: %v (95%% confidence interval %v to %v)\n",
+		len(c.results), c.population, percentage(mean), percentage(max(0, mean-margin)), percentage(min(1, mean+margin)))
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"crypto/rand"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed dashboard.html
+var dashboardHTML string
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(dashboardHTML))
+
+// resultView is how a single result is presented outside of the terminal.
+type resultView struct {
+	Path       string  `json:"path"`
+	Dir        string  `json:"dir"`
+	Match      string  `json:"match"`
+	License    string  `json:"license,omitempty"`
+	Score      float64 `json:"score"`
+	Confidence float64 `json:"confidence"`
+	Lines      int     `json:"lines"`
+	// How much the result counts towards totals, per --weight-by.
+	Weight float64 `json:"weight"`
+}
+
+func (c *comparison) views() []resultView {
+	views := make([]resultView, 0, len(c.results))
+	for _, result := range c.results {
+		rel := c.relTarget(result.filename)
+		views = append(views, resultView{
+			Path:       rel,
+			Dir:        path.Dir(rel),
+			Match:      c.sourceLabel(result.matchedFilename),
+			License:    result.license,
+			Score:      result.matchSimilarity,
+			Confidence: result.confidence,
+			Lines:      result.lineCount,
+			Weight:     result.weight(),
+		})
+	}
+	return views
+}
+
+var (
+	serveFlags = flag.NewFlagSet("serve", flag.ContinueOnError)
+	servePort  = serveFlags.Int("port", 8080, "port to serve on")
+	tokenFile  = serveFlags.String("tokens", "", "file of \"<client> <token>\" lines; if given, requests must bear one of the tokens")
+	rateLimit  = serveFlags.Int("rate-limit", 0, "maximum requests per minute per remote host, whether or not they bear a token (0 for no limit)")
+	auditPath  = serveFlags.String("audit-log", "", "file to append a JSON line to for each submitted comparison")
+	keepJobs   = serveFlags.Int("keep-jobs", 100, "how many finished jobs to keep, with their results, before forgetting the oldest; each holds all the code it compared in memory")
+)
+
+// serve runs comparisons and serves their results, both as web pages and as a JSON API:
+//
+//	POST /api/compare   {"source": "...", "target": "..."} starts a comparison and returns its job
+//	                    (source may be a comma-separated list of repos)
+//	GET  /api/jobs      lists all jobs
+//	GET  /api/jobs/ID   returns a job, including its results once it is done
+//	GET  /jobs/ID/      is the dashboard for a finished job
+//	GET  /metrics       returns metrics for Prometheus to scrape
+//
+// If --source and --target (or --self) are given, that comparison is started right away and /
+// redirects to it.
+//
+// Without --tokens, anyone who can connect can have the server read any path it can, so the
+// server only listens on localhost unless --tokens is given.
+func serve(args []string) error {
+	if len(args) != 0 {
+		return errors.New("usage: venatus serve [flags]")
+	}
+	if err := setUp(); err != nil {
+		return err
+	}
+
+	if *keepJobs < 1 {
+		return fmt.Errorf("--keep-jobs must be at least 1")
+	}
+	s := &server{jobs: make(map[string]*job), metrics: newServerMetrics(), keepJobs: *keepJobs}
+	if *auditPath != "" {
+		audit, err := openAuditLog(*auditPath)
+		if err != nil {
+			return err
+		}
+		s.audit = audit
+	}
+	if len(sources) > 0 || *target != "" || *self != "" {
+		if err := checkRepoFlags(); err != nil {
+			return err
+		}
+		s.initial = s.start(sources.String(), *target)
+	}
+
+	h := s.handler()
+	host := "localhost"
+	if *tokenFile != "" {
+		tokens, err := readTokens(*tokenFile)
+		if err != nil {
+			return err
+		}
+		h = authenticate(tokens, h)
+		host = ""
+	}
+	// Requests are limited before they're authenticated, so that guessing tokens is limited too.
+	if *rateLimit > 0 {
+		h = newRateLimiter(*rateLimit).limit(h)
+	}
+
+	addr := fmt.Sprintf("%s:%d", host, *servePort)
+	slog.Info(fmt.Sprintf("Serving on http://localhost:%d/", *servePort))
+	return http.ListenAndServe(addr, h)
+}
+
+type job struct {
+	id             string
+	source, target string
+	// Closed once the comparison has finished, successfully or not.
+	done chan struct{}
+	err  error
+	c    *comparison
+}
+
+// jobView is how a job is presented by the API.
+type jobView struct {
+	ID      string       `json:"id"`
+	Source  string       `json:"source"`
+	Target  string       `json:"target"`
+	Status  string       `json:"status"`
+	Error   string       `json:"error,omitempty"`
+	Score   *float64     `json:"score,omitempty"`
+	Lines   int          `json:"lines,omitempty"`
+	Results []resultView `json:"results,omitempty"`
+	// How the comparison was run, once it's done.
+	Metadata *runMetadata `json:"metadata,omitempty"`
+}
+
+func (j *job) view(withResults bool) jobView {
+	v := jobView{ID: j.id, Source: j.source, Target: j.target, Status: "running"}
+	select {
+	case <-j.done:
+	default:
+		return v
+	}
+	if j.err != nil {
+		v.Status = "failed"
+		v.Error = j.err.Error()
+		return v
+	}
+	v.Status = "done"
+	v.Score = &j.c.overallScore
+	v.Lines = j.c.totalLineCount
+	v.Metadata = j.c.metadata
+	if withResults {
+		v.Results = j.c.views()
+	}
+	return v
+}
+
+// isDone reports whether the job has finished, successfully or not.
+func (j *job) isDone() bool {
+	select {
+	case <-j.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// finished returns the job's comparison, or nil if it isn't done (or failed).
+func (j *job) finished() *comparison {
+	select {
+	case <-j.done:
+		return j.c
+	default:
+		return nil
+	}
+}
+
+type server struct {
+	mu      sync.Mutex
+	jobs    map[string]*job
+	order   []*job
+	initial *job
+	audit   *auditLog
+	metrics *serverMetrics
+	// How many finished jobs to keep.
+	keepJobs int
+}
+
+// start kicks off a comparison in the background.
+func (s *server) start(sourceRoot, targetRoot string) *job {
+	id := make([]byte, 8)
+	rand.Read(id)
+	j := &job{
+		id:     hex.EncodeToString(id),
+		source: sourceRoot,
+		target: targetRoot,
+		done:   make(chan struct{}),
+	}
+	s.mu.Lock()
+	s.jobs[j.id] = j
+	s.order = append(s.order, j)
+	s.evict()
+	s.mu.Unlock()
+
+	s.metrics.jobsStarted.Add(1)
+	go func() {
+		defer close(j.done)
+		start := time.Now()
+		j.c, j.err = compare(splitRepos(sourceRoot), targetRoot)
+		s.metrics.finished(j.c, j.err, time.Since(start))
+	}()
+	return j
+}
+
+// evict forgets the oldest finished jobs beyond --keep-jobs, and the comparisons they hold, so
+// that a long-running server's memory doesn't grow without bound. Running jobs, and the one
+// started with the server, are kept. s.mu must be held.
+func (s *server) evict() {
+	finished := 0
+	for _, j := range s.order {
+		if j.isDone() {
+			finished++
+		}
+	}
+	kept := s.order[:0]
+	for _, j := range s.order {
+		if finished > s.keepJobs && j != s.initial && (j.isDone()) {
+			delete(s.jobs, j.id)
+			finished--
+			continue
+		}
+		kept = append(kept, j)
+	}
+	s.order = kept
+}
+
+func (s *server) job(id string) *job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jobs[id]
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" || s.initial == nil {
+			http.NotFound(w, r)
+			return
+		}
+		http.Redirect(w, r, "/jobs/"+s.initial.id+"/", http.StatusFound)
+	})
+	mux.HandleFunc("/jobs/", s.handleDashboard)
+	mux.HandleFunc("/api/compare", s.handleCompare)
+	mux.HandleFunc("/api/jobs", s.handleJobs)
+	mux.HandleFunc("/api/jobs/", s.handleJob)
+	mux.Handle("/metrics", s.metrics)
+	return mux
+}
+
+// handleDashboard serves /jobs/ID/ and /jobs/ID/diff?path=...
+func (s *server) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	id, page, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/jobs/"), "/")
+	j := s.job(id)
+	if j == nil {
+		http.NotFound(w, r)
+		return
+	}
+	c := j.finished()
+	if c == nil {
+		http.Error(w, fmt.Sprintf("job %s is %s", id, j.view(false).Status), http.StatusConflict)
+		return
+	}
+	switch page {
+	case "":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err := dashboardTemplate.Execute(w, map[string]any{
+			"Source":     j.source,
+			"Target":     j.target,
+			"Score":      percentage(c.overallScore).String(),
+			"Lines":      c.totalLineCount,
+			"Results":    c.views(),
+			"GroupByDir": *groupBy == "dir",
+			"Metadata":   c.metadata,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	case "diff":
+		d, err := c.diff(r.URL.Query().Get("path"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprint(w, d)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *server) handleCompare(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Source string `json:"source"`
+		Target string `json:"target"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Source == "" || req.Target == "" {
+		http.Error(w, "both source and target are required", http.StatusBadRequest)
+		return
+	}
+	for _, repo := range append(splitRepos(req.Source), req.Target) {
+		if repo != req.Target && isPackage(repo) {
+			continue
+		}
+		if _, err := os.Stat(repo); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	j := s.start(req.Source, req.Target)
+	s.audit.record(r, j)
+	w.Header().Set("Location", "/api/jobs/"+j.id)
+	writeJSON(w, http.StatusAccepted, j.view(false))
+}
+
+func (s *server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	views := make([]jobView, 0, len(s.order))
+	for _, j := range s.order {
+		views = append(views, j.view(false))
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, views)
+}
+
+func (s *server) handleJob(w http.ResponseWriter, r *http.Request) {
+	j := s.job(strings.TrimPrefix(r.URL.Path, "/api/jobs/"))
+	if j == nil {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, http.StatusOK, j.view(true))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
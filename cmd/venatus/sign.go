@@ -0,0 +1,141 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Reports are signed with Ed25519 keys in PEM files, as OpenSSL makes them:
+//
+//	openssl genpkey -algorithm ed25519 -out venatus.key
+//	openssl pkey -in venatus.key -pubout -out venatus.pub
+//
+// A signature is kept next to the report, base64-encoded, in a file named after it with ".sig"
+// added.
+
+// readPEM reads the DER contents of a PEM file of the given type.
+func readPEM(path, typ string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != typ {
+		return nil, fmt.Errorf("%s: not a PEM %s", path, typ)
+	}
+	return block.Bytes, nil
+}
+
+func readPrivateKey(path string) (ed25519.PrivateKey, error) {
+	der, err := readPEM(path, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	private, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an Ed25519 key", path)
+	}
+	return private, nil
+}
+
+func readPublicKey(path string) (ed25519.PublicKey, error) {
+	der, err := readPEM(path, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	public, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an Ed25519 key", path)
+	}
+	return public, nil
+}
+
+var (
+	signFlags    = flag.NewFlagSet("sign", flag.ContinueOnError)
+	privateKey   = signFlags.String("key", "", "PEM file of the Ed25519 private key to sign with")
+	signatureOut = signFlags.String("signature", "", "file to write the signature to (default REPORT.sig)")
+	verifyFlags  = flag.NewFlagSet("verify", flag.ContinueOnError)
+	publicKey    = verifyFlags.String("public-key", "", "PEM file of the Ed25519 public key the report should be signed with")
+	signatureIn  = verifyFlags.String("signature", "", "file of the signature (default REPORT.sig)")
+)
+
+// signArgs checks the arguments of sign and verify, returning the report and signature paths.
+func signArgs(command string, args []string, signature string) (string, string, error) {
+	if len(args) != 1 {
+		return "", "", fmt.Errorf("usage: venatus %s [flags] REPORT", command)
+	}
+	report := args[0]
+	if signature == "" {
+		signature = report + ".sig"
+	}
+	return report, signature, nil
+}
+
+// sign signs a report, such as a --summary-json file, so that it can be shown later not to have
+// been tampered with.
+func sign(args []string) error {
+	report, sigPath, err := signArgs("sign", args, *signatureOut)
+	if err != nil {
+		return err
+	}
+	if *privateKey == "" {
+		return errors.New("--key not specified")
+	}
+	key, err := readPrivateKey(*privateKey)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(report)
+	if err != nil {
+		return err
+	}
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(key, data))
+	return os.WriteFile(sigPath, []byte(sig+"\n"), 0644)
+}
+
+// verify checks a report's signature.
+func verify(args []string) error {
+	report, sigPath, err := signArgs("verify", args, *signatureIn)
+	if err != nil {
+		return err
+	}
+	if *publicKey == "" {
+		return errors.New("--public-key not specified")
+	}
+	key, err := readPublicKey(*publicKey)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(report)
+	if err != nil {
+		return err
+	}
+	encoded, err := os.ReadFile(sigPath)
+	if err != nil {
+		return err
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil {
+		return fmt.Errorf("%s: %w", sigPath, err)
+	}
+	if !ed25519.Verify(key, data, sig) {
+		return fmt.Errorf("%s: signature doesn't match; the report or signature has been changed, or was signed with another key", report)
+	}
+	fmt.Printf("%s: signature OK\n", report)
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+)
+
+var maxSimhashDistance = flag.Int("max-simhash-distance", 64, "only compare files whose SimHashes differ in at most this many of their 64 bits, to prune candidates cheaply before scoring them (64 compares all)")
+
+// simhash returns the 64-bit SimHash of normalized code's tokens: each bit is set if more of the
+// tokens' hashes have it set than not. Similar code has SimHashes that differ in few bits.
+func simhash(code string) uint64 {
+	var votes [64]int
+	forEachToken(code, func(token string) {
+		h := fnv.New64a()
+		h.Write([]byte(token))
+		sum := h.Sum64()
+		for i := range votes {
+			if sum&(1<<i) != 0 {
+				votes[i]++
+			} else {
+				votes[i]--
+			}
+		}
+	})
+	var hash uint64
+	for i, v := range votes {
+		if v > 0 {
+			hash |= 1 << i
+		}
+	}
+	return hash
+}
+
+// simhashDistance is the number of bits in which two SimHashes differ.
+func simhashDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+func checkSimhash() error {
+	if *maxSimhashDistance < 0 || *maxSimhashDistance > 64 {
+		return fmt.Errorf("--max-simhash-distance must be between 0 and 64")
+	}
+	return nil
+}
+
+// filtersBySimhash reports whether candidates are filtered by their SimHashes.
+func filtersBySimhash() bool {
+	return *maxSimhashDistance < 64
+}
+
+// simhashAlgorithm scores by the fraction of bits in which the files' SimHashes agree. It's
+// coarse, but about as fast as scoring can be.
+type simhashAlgorithm struct{}
+
+func (simhashAlgorithm) similarity(code1, code2 string) (float64, bool) {
+	return 1 - float64(simhashDistance(simhash(code1), simhash(code2)))/64, false
+}
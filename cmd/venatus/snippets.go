@@ -0,0 +1,212 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+	"strings"
+)
+
+var (
+	findSnippets    = flag.Bool("snippets", false, "look for regions of target files that were copied from source files, even where the files as a whole don't match well")
+	snippetMinLines = flag.Int("snippet-min-lines", 20, "with --snippets, the fewest lines of code a copied region must have to be reported")
+)
+
+const (
+	// How many lines of code make up a shingle, the unit that regions are found by.
+	shingleLines = 4
+	// Shingles found in more places than this are boilerplate (like runs of closing braces), and
+	// don't say anything about where code came from.
+	maxShingleSites = 50
+	// Only files whose best whole-file match scores less than this are searched for snippets;
+	// for the rest, the whole-file match says it all.
+	snippetFileScore = 0.8
+)
+
+// A snippet is a region of a target file that closely matches a region of a source file.
+// Line ranges are inclusive, and numbered as in the files (not their normalized contents).
+type snippet struct {
+	sourceFile             string
+	targetStart, targetEnd int
+	sourceStart, sourceEnd int
+	similarity             float64
+}
+
+// A site is where a shingle was found: the file, and the index of its first line of code.
+type site struct {
+	file string
+	line int
+}
+
+func shingleHash(lines []string) uint64 {
+	h := fnv.New64a()
+	for _, line := range lines {
+		h.Write([]byte(line))
+		h.Write([]byte{'\n'})
+	}
+	return h.Sum64()
+}
+
+// nonBlankLines returns the non-blank lines of normalized code, and the index of each among all
+// of its lines.
+func nonBlankLines(code string) ([]string, []int) {
+	var lines []string
+	var at []int
+	for i, line := range strings.Split(code, "\n") {
+		if line != "" {
+			lines = append(lines, line)
+			at = append(at, i)
+		}
+	}
+	return lines, at
+}
+
+// shingleIndex maps each shingle in the source files to where it was found.
+type shingleIndex map[uint64][]site
+
+func (c *comparison) indexShingles() shingleIndex {
+	return indexShinglesOf(c.sourceFiles)
+}
+
+func indexShinglesOf(files map[string]string) shingleIndex {
+	index := make(shingleIndex)
+	for path, contents := range files {
+		lines, _ := nonBlankLines(contents)
+		for i := 0; i+shingleLines <= len(lines); i++ {
+			h := shingleHash(lines[i : i+shingleLines])
+			if len(index[h]) <= maxShingleSites {
+				index[h] = append(index[h], site{path, i})
+			}
+		}
+	}
+	return index
+}
+
+// A run is a chain of shingles found in the same order, close together, in a target file and in
+// one source file.
+type run struct {
+	file                    string
+	targetFirst, targetLast int
+	sourceFirst, sourceLast int
+}
+
+// findRuns chains together the shingles a target file shares with the source files.
+func findRuns(index shingleIndex, path string, lines []string) []*run {
+	var runs []*run
+	open := make(map[string][]*run)
+	for i := 0; i+shingleLines <= len(lines); i++ {
+		sites := index[shingleHash(lines[i:i+shingleLines])]
+		if len(sites) > maxShingleSites {
+			continue
+		}
+	sites:
+		for _, s := range sites {
+			if s.file == path {
+				continue
+			}
+			// Extend a run that this shingle follows on from, allowing for a few changed lines.
+			for _, r := range open[s.file] {
+				if i > r.targetLast && i-r.targetLast <= shingleLines && s.line > r.sourceLast && s.line-r.sourceLast <= shingleLines {
+					r.targetLast, r.sourceLast = i, s.line
+					continue sites
+				}
+			}
+			r := &run{file: s.file, targetFirst: i, targetLast: i, sourceFirst: s.line, sourceLast: s.line}
+			runs = append(runs, r)
+			open[s.file] = append(open[s.file], r)
+		}
+	}
+	return runs
+}
+
+// findSnippetsIn looks for regions of a target file copied from the source files.
+func (c *comparison) findSnippetsIn(index shingleIndex, result *findResult) {
+	result.snippets = c.copiedRegions(index, c.sourceFiles, result.filename)
+}
+
+// copiedRegions looks for regions of a target file copied from the given files, which index
+// indexes.
+func (c *comparison) copiedRegions(index shingleIndex, files map[string]string, path string) []snippet {
+	var snippets []snippet
+	targetLines, targetAt := nonBlankLines(c.targetContents(path))
+	for _, r := range findRuns(index, path, targetLines) {
+		targetEnd := r.targetLast + shingleLines - 1
+		sourceEnd := r.sourceLast + shingleLines - 1
+		if targetEnd-r.targetFirst+1 < *snippetMinLines {
+			continue
+		}
+		sourceLines, sourceAt := nonBlankLines(files[r.file])
+		d := diff(strings.Join(targetLines[r.targetFirst:targetEnd+1], "\n"), strings.Join(sourceLines[r.sourceFirst:sourceEnd+1], "\n"))
+		snippets = append(snippets, snippet{
+			sourceFile:  r.file,
+			targetStart: c.fileLine(path, targetAt[r.targetFirst]),
+			targetEnd:   c.fileLine(path, targetAt[targetEnd]),
+			sourceStart: c.fileLine(r.file, sourceAt[r.sourceFirst]),
+			sourceEnd:   c.fileLine(r.file, sourceAt[sourceEnd]),
+			similarity:  d.asPercentage(),
+		})
+	}
+	sort.Slice(snippets, func(i, j int) bool {
+		a, b := snippets[i], snippets[j]
+		if a.targetStart != b.targetStart {
+			return a.targetStart < b.targetStart
+		}
+		if a.sourceFile != b.sourceFile {
+			return a.sourceFile < b.sourceFile
+		}
+		return a.sourceStart < b.sourceStart
+	})
+	return snippets
+}
+
+// matchSnippets looks for copied regions in the target files whose best whole-file match is poor.
+func (c *comparison) matchSnippets() {
+	index := c.indexShingles()
+	c.lineNumbers = make(map[string][]int)
+	for _, result := range c.results {
+		if result.matchSimilarity < snippetFileScore {
+			c.findSnippetsIn(index, result)
+		}
+	}
+}
+
+// fileLine maps the index of a line of a file's normalized contents back to its line number in
+// the file. If the file can't be read again (e.g., it came from an index), lines are numbered as
+// in the normalized contents.
+func (c *comparison) fileLine(path string, i int) int {
+	numbers, ok := c.lineNumbers[path]
+	if !ok {
+		if info, err := os.Lstat(path); err == nil {
+			if lang := languageOf(path, info); lang != nil {
+				_, numbers, _ = readCodeFileNumbered(path, lang)
+			}
+		}
+		c.lineNumbers[path] = numbers
+	}
+	if i < len(numbers) {
+		return numbers[i]
+	}
+	return i + 1
+}
+
+// printSnippets lists the copied regions found with --snippets.
+func printSnippets(c *comparison) {
+	var found []*findResult
+	for _, result := range c.results {
+		if len(result.snippets) > 0 {
+			found = append(found, result)
+		}
+	}
+	if len(found) == 0 {
+		return
+	}
+	fmt.Printf("\n\n%d target files have regions copied from source files they don't match as a whole:\n", len(found))
+	for _, result := range found {
+		fmt.Println(c.relTarget(result.filename))
+		for _, s := range result.snippets {
+			fmt.Printf("  lines %d-%d ~ %s lines %d-%d (%v)\n", s.targetStart, s.targetEnd, c.sourceLabel(s.sourceFile), s.sourceStart, s.sourceEnd, percentage(s.similarity))
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"path/filepath"
+	"strings"
+)
+
+// The source repos, each a path to a repo (or an index of one) or a package URL.
+var sources repoList
+
+func init() {
+	flag.Var(&sources, "source", "path to source repo (or an index of one), or a package URL like pkg:golang/golang.org/x/sync@v0.6.0; repeat or separate with commas to match against several")
+}
+
+// A repoList is a flag that can be repeated, or given a comma-separated list, or both.
+type repoList []string
+
+func (l *repoList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *repoList) Set(value string) error {
+	*l = append(*l, splitRepos(value)...)
+	return nil
+}
+
+func splitRepos(value string) []string {
+	var repos []string
+	for _, repo := range strings.Split(value, ",") {
+		if repo = strings.TrimSpace(repo); repo != "" {
+			repos = append(repos, repo)
+		}
+	}
+	return repos
+}
+
+// A sourceRepo is one of the repos target files are matched against.
+type sourceRepo struct {
+	// What to call the repo in the report.
+	name string
+	// Where its files are (or, for an index, the index file).
+	root string
+	// For an index, the SimHashes it holds of the files.
+	simhashes map[string]uint64
+}
+
+// nameSources decides what to call each of the given sources, as given on the command line:
+// the last element of the path or package name, or the whole thing if that would be ambiguous.
+func nameSources(given []string) []string {
+	names := make([]string, len(given))
+	seen := make(map[string]int)
+	for i, g := range given {
+		name := g
+		if isPackage(g) {
+			if p, err := parsePkgURL(g); err == nil {
+				name = p.name
+			}
+		} else {
+			name = filepath.Base(filepath.Clean(g))
+		}
+		names[i] = name
+		seen[name]++
+	}
+	for i, name := range names {
+		if seen[name] > 1 {
+			names[i] = given[i]
+		}
+	}
+	return names
+}
+
+// sourceOf returns the source repo that the given source file was found in, or nil if it isn't
+// in any of them.
+func (c *comparison) sourceOf(path string) *sourceRepo {
+	for _, s := range c.sources {
+		root := filepath.Clean(s.root)
+		if path == root || strings.HasPrefix(path, root+string(filepath.Separator)) {
+			return s
+		}
+	}
+	return nil
+}
+
+// sourceLabel is how a source file is shown in the report: relative to its repo, and prefixed
+// with which repo that is if there are several.
+func (c *comparison) sourceLabel(path string) string {
+	s := c.sourceOf(path)
+	if s == nil || len(c.sources) == 1 {
+		return c.relSource(path)
+	}
+	return s.name + ":" + relTo(s.root, path)
+}
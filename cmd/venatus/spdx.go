@@ -0,0 +1,314 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+var compareLicenseFiles = flag.Bool("license-files", false, "also find the LICENSE, COPYING, and NOTICE files of each repo, identify their licenses by matching them against SPDX license templates, and report which the target altered or dropped")
+
+// The texts of common licenses, after SPDX's templates, with <<var>> wherever a license is
+// expected to vary, like the name of its copyright holder. Copyright lines and titles are left
+// out, since text before a template's first words is never held against it. Longer licenses are
+// recognized by their phrases instead (see licenseTexts).
+var spdxTemplates = []struct {
+	id, text string
+}{
+	{"MIT", `Permission is hereby granted, free of charge, to any person obtaining a copy of this
+software and associated documentation files (the "Software"), to deal in the Software without
+restriction, including without limitation the rights to use, copy, modify, merge, publish,
+distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the
+Software is furnished to do so, subject to the following conditions:
+The above copyright notice and this permission notice shall be included in all copies or
+substantial portions of the Software.
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT
+NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+NONINFRINGEMENT. IN NO EVENT SHALL <<var>> BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.`},
+	{"ISC", `Permission to use, copy, modify, and/or distribute this software for any purpose with or
+without fee is hereby granted, provided that the above copyright notice and this permission notice
+appear in all copies.
+THE SOFTWARE IS PROVIDED "AS IS" AND <<var>> DISCLAIMS ALL WARRANTIES WITH REGARD TO THIS SOFTWARE
+INCLUDING ALL IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL <<var>> BE LIABLE
+FOR ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES WHATSOEVER RESULTING FROM
+LOSS OF USE, DATA OR PROFITS, WHETHER IN AN ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS
+ACTION, ARISING OUT OF OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.`},
+	{"BSD-3-Clause", `Redistribution and use in source and binary forms, with or without modification, are
+permitted provided that the following conditions are met:
+1. Redistributions of source code must retain the above copyright notice, this list of conditions
+and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice, this list of
+conditions and the following disclaimer in the documentation and/or other materials provided with
+the distribution.
+3. Neither the name of <<var>> nor the names of its contributors may be used to endorse or promote
+products derived from this software without specific prior written permission.
+THIS SOFTWARE IS PROVIDED BY <<var>> "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT
+NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL <<var>> BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR
+OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY
+OF SUCH DAMAGE.`},
+	{"BSD-2-Clause", `Redistribution and use in source and binary forms, with or without modification, are
+permitted provided that the following conditions are met:
+1. Redistributions of source code must retain the above copyright notice, this list of conditions
+and the following disclaimer.
+2. Redistributions in binary form must reproduce the above copyright notice, this list of
+conditions and the following disclaimer in the documentation and/or other materials provided with
+the distribution.
+THIS SOFTWARE IS PROVIDED BY <<var>> "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT
+NOT LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE ARE
+DISCLAIMED. IN NO EVENT SHALL <<var>> BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR
+OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY
+OF SUCH DAMAGE.`},
+	{"Zlib", `This software is provided 'as-is', without any express or implied warranty. In no event
+will the authors be held liable for any damages arising from the use of this software.
+Permission is granted to anyone to use this software for any purpose, including commercial
+applications, and to alter it and redistribute it freely, subject to the following restrictions:
+1. The origin of this software must not be misrepresented; you must not claim that you wrote the
+original software. If you use this software in a product, an acknowledgment in the product
+documentation would be appreciated but is not required.
+2. Altered source versions must be plainly marked as such, and must not be misrepresented as
+being the original software.
+3. This notice may not be removed or altered from any source distribution.`},
+}
+
+const (
+	// Stands for a <<var>> among the words of a template.
+	templateVar = "<<var>>\n"
+	// The most words a <<var>> may stand for.
+	maxVarWords = 40
+	// How much of a template's words a license file must have, in order, to be taken for a
+	// modified copy of it.
+	minTemplateMatch = 0.8
+)
+
+// licenseWordList splits license text into words, one per line for lineDiff, ignoring case,
+// punctuation, and list numbering, which vary between copies without changing their meaning.
+func licenseWordList(s string) string {
+	var b strings.Builder
+	for _, field := range strings.Fields(s) {
+		if field == "<<var>>" {
+			b.WriteString(templateVar)
+			continue
+		}
+		word := strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return unicode.ToLower(r)
+			}
+			return -1
+		}, field)
+		if word == "" || strings.Trim(word, "0123456789") == "" {
+			continue
+		}
+		b.WriteString(word + "\n")
+	}
+	return b.String()
+}
+
+// matchTemplate reports how much of a template's words license text has, in order, and whether
+// it is exactly the template: nothing missing, and nothing added but where the template varies,
+// before its first words, or after its last.
+func matchTemplate(template, text string) (score float64, exact bool) {
+	ops := lineDiff(licenseWordList(template), licenseWordList(text))
+	words, equal := 0, 0
+	exact = true
+	// Whether the template's words have started, whether a <<var>> has come since the last of
+	// them, and how many words have been added since.
+	started, afterVar, added := false, false, 0
+	for _, op := range ops {
+		switch op.op {
+		case diffmatchpatch.DiffEqual:
+			if added > 0 && started && (!afterVar || added > maxVarWords) {
+				exact = false
+			}
+			words++
+			equal++
+			started, afterVar, added = true, false, 0
+		case diffmatchpatch.DiffDelete:
+			if op.text == templateVar {
+				afterVar = true
+				continue
+			}
+			words++
+			exact = false
+		case diffmatchpatch.DiffInsert:
+			added++
+		}
+	}
+	if words == 0 {
+		return 0, false
+	}
+	return float64(equal) / float64(words), exact
+}
+
+// A licenseFile is a LICENSE, COPYING, or NOTICE file of a repo.
+type licenseFile struct {
+	// Which repo it's in: "target", or the name of a source.
+	repo string
+	rel  string
+	text string
+	// The license it contains, and how it was recognized.
+	license, how string
+	// For the target's files, how they compare with the source's.
+	status string
+}
+
+// isLicenseFileName reports whether a file's name says it holds a license, like LICENSE,
+// LICENSE-MIT, COPYING.LIB, or NOTICE.txt.
+func isLicenseFileName(name string) bool {
+	upper := strings.ToUpper(name)
+	for _, prefix := range []string{"LICENSE", "LICENCE", "COPYING", "NOTICE"} {
+		if strings.HasPrefix(upper, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// findLicenseFiles finds the license files under root, and identifies their licenses.
+func findLicenseFiles(repo, root string) []*licenseFile {
+	var files []*licenseFile
+	walkTree(root, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !info.Mode().IsRegular() || !isLicenseFileName(info.Name()) {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		f := &licenseFile{repo: repo, rel: relTo(root, path), text: string(data)}
+		f.license, f.how = identifyLicenseText(f.text)
+		files = append(files, f)
+		return nil
+	})
+	sort.Slice(files, func(i, j int) bool { return files[i].rel < files[j].rel })
+	return files
+}
+
+// identifyLicenseText identifies the license in a license file: by the best-matching template if
+// there is one close enough, or else by its SPDX tag or phrases.
+func identifyLicenseText(text string) (license, how string) {
+	best, bestScore, bestExact := "", 0.0, false
+	for _, t := range spdxTemplates {
+		score, exact := matchTemplate(t.text, text)
+		if exact || score > bestScore {
+			best, bestScore, bestExact = t.id, score, exact
+		}
+		if exact {
+			break
+		}
+	}
+	switch {
+	case bestExact:
+		return best, "template"
+	case bestScore >= minTemplateMatch:
+		return best, fmt.Sprintf("template, %v matched", percentage(bestScore))
+	}
+	if license := identifyLicense(text); license != "unrecognized" {
+		return license, "phrases"
+	}
+	return "unrecognized", ""
+}
+
+// sameLicenseText reports whether two license files say the same thing, give or take layout,
+// punctuation, and copyright years.
+func sameLicenseText(a, b string) bool {
+	words := func(text string) string {
+		var lines []string
+		for _, line := range strings.Split(text, "\n") {
+			lines = append(lines, normalizeCopyrightYears(line))
+		}
+		return licenseWordList(strings.Join(lines, "\n"))
+	}
+	return words(a) == words(b)
+}
+
+// matchLicenseFiles finds the license files of the repos, and works out whether each of the
+// target's is the same as the source's at the same path (or, failing that, of the same name),
+// and which of the source's the target doesn't have.
+func (c *comparison) matchLicenseFiles() {
+	var sourceFiles []*licenseFile
+	for _, s := range c.sources {
+		if isIndexFile(s.root) {
+			continue
+		}
+		sourceFiles = append(sourceFiles, findLicenseFiles(s.name, s.root)...)
+	}
+	targetFiles := findLicenseFiles("target", c.targetRoot)
+	kept := make(map[*licenseFile]bool)
+	for _, t := range targetFiles {
+		var counterpart *licenseFile
+		for _, s := range sourceFiles {
+			if s.rel == t.rel {
+				counterpart = s
+				break
+			}
+			if counterpart == nil && filepath.Base(s.rel) == filepath.Base(t.rel) {
+				counterpart = s
+			}
+		}
+		switch {
+		case counterpart == nil:
+			t.status = "not in source"
+		case sameLicenseText(counterpart.text, t.text):
+			t.status = "same as " + counterpart.rel
+			kept[counterpart] = true
+		default:
+			t.status = "altered from " + counterpart.rel
+			kept[counterpart] = true
+		}
+	}
+	for _, s := range sourceFiles {
+		if !kept[s] {
+			s.status = "not in target"
+		}
+	}
+	c.licenseFiles = append(sourceFiles, targetFiles...)
+}
+
+// printLicenseFiles lists the license files of the repos, with their licenses, with
+// --license-files.
+func printLicenseFiles(c *comparison) {
+	if !*compareLicenseFiles {
+		return
+	}
+	if len(c.licenseFiles) == 0 {
+		fmt.Printf("\n\nNo license files found.\n")
+		return
+	}
+	altered := 0
+	tw := table.NewWriter()
+	tw.SetStyle(table.StyleDouble)
+	tw.AppendHeader(table.Row{"Repo", "License file", "License", "Recognized by", "Status"})
+	for _, f := range c.licenseFiles {
+		tw.AppendRow(table.Row{f.repo, f.rel, f.license, f.how, f.status})
+		if strings.HasPrefix(f.status, "altered") {
+			altered++
+		}
+	}
+	if altered > 0 {
+		tw.SetCaption("%s", fmt.Sprintf("License files whose text the target altered: %d", altered))
+	}
+	fmt.Printf("\n\n%s\n", tw.Render())
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	identifierPattern = regexp.MustCompile(`[A-Za-z_]\w*`)
+	definePattern     = regexp.MustCompile(`^#\s*define\s+([A-Za-z_]\w*)`)
+	// Function definitions in shell and Python scripts.
+	scriptFuncPattern = regexp.MustCompile(`^(?:def\s+([A-Za-z_]\w*)\s*\(|function\s+([A-Za-z_][\w-]*)|([A-Za-z_][\w-]*)\s*\(\)\s*\{)`)
+)
+
+// Words that look like names in a declaration, but aren't.
+var declarationKeywords = map[string]bool{
+	"auto": true, "char": true, "const": true, "double": true, "enum": true, "extern": true,
+	"float": true, "inline": true, "int": true, "long": true, "register": true, "restrict": true,
+	"short": true, "signed": true, "struct": true, "union": true, "unsigned": true, "void": true,
+	"volatile": true, "_Bool": true, "bool": true,
+}
+
+// declaredSymbols returns the sorted names of the functions, global variables, and macros that
+// normalized code declares or defines for use by other files: that is, leaving out static ones.
+// This is a rough parse that doesn't need the code to compile, which is the point: it finds
+// copied APIs even when their implementations have been rewritten.
+func declaredSymbols(code string) []string {
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if name != "" && !declarationKeywords[name] {
+			seen[name] = true
+		}
+	}
+
+	depth := 0
+	var statement strings.Builder
+	for _, line := range strings.Split(code, "\n") {
+		if m := definePattern.FindStringSubmatch(line); m != nil {
+			add(m[1])
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if m := scriptFuncPattern.FindStringSubmatch(line); m != nil && depth == 0 {
+			add(m[1] + m[2] + m[3])
+			continue
+		}
+		inString := byte(0)
+		for i := 0; i < len(line); i++ {
+			c := line[i]
+			switch {
+			case inString != 0:
+				if c == '\\' {
+					i++
+				} else if c == inString {
+					inString = 0
+				}
+				continue
+			case c == '"' || c == '\'':
+				inString = c
+				continue
+			case c == '{':
+				if depth == 0 {
+					add(declaredName(statement.String()))
+					statement.Reset()
+				}
+				depth++
+				continue
+			case c == '}':
+				depth = max(0, depth-1)
+				continue
+			}
+			if depth > 0 {
+				continue
+			}
+			if c == ';' {
+				add(declaredName(statement.String()))
+				statement.Reset()
+				continue
+			}
+			statement.WriteByte(c)
+		}
+		statement.WriteByte(' ')
+	}
+
+	symbols := make([]string, 0, len(seen))
+	for name := range seen {
+		symbols = append(symbols, name)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// declaredName works out what a top-level C declaration (up to its ; or {) declares, if it's a
+// function or variable that other files can see.
+func declaredName(decl string) string {
+	decl = strings.TrimSpace(decl)
+	words := identifierPattern.FindAllString(decl, -1)
+	if len(words) == 0 || words[0] == "static" || words[0] == "typedef" {
+		return ""
+	}
+	// A function: the name just before the parameter list.
+	if paren := strings.Index(decl, "("); paren >= 0 {
+		// A pointer to a function, like void (*handler)(int), is named inside the first parentheses.
+		if inner := strings.TrimSpace(decl[paren+1:]); strings.HasPrefix(inner, "*") {
+			if name := identifierPattern.FindString(inner); name != "" {
+				return name
+			}
+		}
+		names := identifierPattern.FindAllString(decl[:paren], -1)
+		if len(names) == 0 {
+			return ""
+		}
+		return names[len(names)-1]
+	}
+	// A variable: the name just before its initializer or dimensions.
+	if end := strings.IndexAny(decl, "=["); end >= 0 {
+		decl = decl[:end]
+	}
+	names := identifierPattern.FindAllString(decl, -1)
+	// A lone struct, union, or enum tag declares a type, not a variable.
+	if len(names) < 2 || len(names) == 2 && (names[0] == "struct" || names[0] == "union" || names[0] == "enum") {
+		return ""
+	}
+	return names[len(names)-1]
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var followSymlinks = flag.Bool("follow-symlinks", false, "walk into symlinked directories, such as vendored trees linked into a repo")
+
+// walkTree is filepath.Walk, except that with --follow-symlinks it walks into symlinked
+// directories too. Their files are given paths through the link, as if the directory were in
+// the tree, and links back into a directory already being walked are skipped.
+func walkTree(root string, fn filepath.WalkFunc) error {
+	if !*followSymlinks {
+		return filepath.Walk(root, fn)
+	}
+	real, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return filepath.Walk(root, fn)
+	}
+	return walkLinked(root, real, []string{real}, fn)
+}
+
+// walkLinked walks the directory at real as if it were at shown. walking holds the real paths of
+// the directories being walked, outermost first.
+func walkLinked(shown, real string, walking []string, fn filepath.WalkFunc) error {
+	return filepath.Walk(real, func(path string, info fs.FileInfo, err error) error {
+		rel, relErr := filepath.Rel(real, path)
+		if relErr != nil {
+			return relErr
+		}
+		path, linkPath := filepath.Join(shown, rel), path
+		if err != nil || info.Mode()&fs.ModeSymlink == 0 {
+			return fn(path, info, err)
+		}
+		linked, err := os.Stat(linkPath)
+		if err != nil {
+			// A dangling link.
+			return fn(path, info, err)
+		}
+		if !linked.IsDir() {
+			return fn(path, linked, nil)
+		}
+		to, err := filepath.EvalSymlinks(linkPath)
+		if err != nil {
+			return fn(path, info, err)
+		}
+		linkDir, err := filepath.EvalSymlinks(filepath.Dir(linkPath))
+		if err != nil {
+			return fn(path, info, err)
+		}
+		for _, dir := range append(walking, linkDir) {
+			if dir == to || strings.HasPrefix(dir, to+string(filepath.Separator)) {
+				slog.Warn("Not following symlink back into a directory being walked", "path", path, "to", to)
+				return nil
+			}
+		}
+		return walkLinked(path, to, append(walking[:len(walking):len(walking)], to), fn)
+	})
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+// renderRollup tabularizes the per-directory rollup, worst-matching directories first.
+func renderRollup(dirs []*dirSummary) string {
+	sorted := append([]*dirSummary(nil), dirs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].score < sorted[j].score
+	})
+	tw := table.NewWriter()
+	tw.SetStyle(table.StyleDouble)
+	tw.AppendHeader(table.Row{"Directory", "Score", "LoC"})
+	for _, dir := range sorted {
+		tw.AppendRow(table.Row{dir.name + "/", percentage(dir.score), dir.lineCount})
+	}
+	tw.SetColumnConfigs([]table.ColumnConfig{
+		{Number: 2, Align: text.AlignRight},
+		{Number: 3, Align: text.AlignRight},
+	})
+	return tw.Render()
+}
+
+// subtotal sums up the rows of a group, weighted per --weight-by as the totals are. Only the rows
+// shown count, so that a subtotal agrees with the rows above it.
+func subtotal(results []*findResult) (float64, int) {
+	var score, weight float64
+	lines := 0
+	for _, result := range results {
+		score += result.matchSimilarity * result.weight()
+		weight += result.weight()
+		lines += result.lineCount
+	}
+	if weight == 0 {
+		return 0, lines
+	}
+	return score / weight, lines
+}
+
+// renderTable tabularizes the results real nice.
+func renderTable(c *comparison) string {
+	tw := table.NewWriter()
+	tw.SetStyle(table.StyleDouble)
+	if *plainTable {
+		tw.SetStyle(plainStyle())
+	}
+	prefix := greatestCommonPrefix(c.sources[0].root, c.targetRoot)
+	from := strings.TrimPrefix(c.sources[0].root, prefix)
+	if len(c.sources) > 1 {
+		names := make([]string, len(c.sources))
+		for i, s := range c.sources {
+			names[i] = s.name
+		}
+		from = strings.Join(names, ", ")
+	}
+	pathHeader := fmt.Sprintf("Path in %s", strings.TrimPrefix(c.targetRoot, prefix))
+	matchHeader := fmt.Sprintf("Best match from %s", from)
+	if len(c.sources) == 1 && c.sources[0].root == c.targetRoot {
+		pathHeader, matchHeader = "Path", "Most similar other file"
+	}
+	header := make(table.Row, len(tableColumns))
+	for i, col := range tableColumns {
+		switch col.name {
+		case "path":
+			header[i] = pathHeader
+		case "match":
+			header[i] = matchHeader
+		default:
+			header[i] = col.header
+		}
+	}
+	tw.AppendHeader(header)
+	pathAt := columnIndex("path")
+	// How many files are shown, and how many more would be but for --top.
+	count, cut := 0, 0
+	if *groupBy == "dir" {
+		for _, dir := range c.directories() {
+			var results []*findResult
+			for _, result := range dir.results {
+				if shown(result) && !isNew(result) {
+					results = append(results, result)
+				}
+			}
+			if *topFiles > 0 && count+len(results) > *topFiles {
+				cut += count + len(results) - *topFiles
+				results = results[:*topFiles-count]
+			}
+			if len(results) == 0 {
+				continue
+			}
+			count += len(results)
+			tw.AppendRow(columnRow(map[string]interface{}{"path": dir.name + "/"}))
+			for _, result := range results {
+				row := resultRow(c, result)
+				if pathAt >= 0 {
+					row[pathAt] = "  " + strings.TrimPrefix(c.relTarget(result.filename), dir.prefix())
+				}
+				tw.AppendRow(row)
+			}
+			score, lines := subtotal(results)
+			tw.AppendRow(columnRow(map[string]interface{}{
+				"path":  "  Subtotal",
+				"score": percentage(score),
+				"loc":   lines,
+			}))
+			tw.AppendSeparator()
+		}
+	} else {
+		for _, result := range c.results {
+			if !shown(result) || isNew(result) {
+				continue
+			}
+			if *topFiles > 0 && count >= *topFiles {
+				cut++
+				continue
+			}
+			count++
+			tw.AppendRow(resultRow(c, result))
+		}
+	}
+	if *weightBy == "lines" {
+		tw.AppendFooter(columnRow(map[string]interface{}{
+			"path":  "Total",
+			"score": percentage(c.overallScore),
+			"loc":   c.totalLineCount,
+		}))
+	} else {
+		// Show the line-weighted total too, for comparison with other runs.
+		tw.AppendFooter(columnRow(map[string]interface{}{
+			"path":  "Total by lines",
+			"score": percentage(c.lineScore),
+			"loc":   c.totalLineCount,
+		}))
+		tw.AppendFooter(columnRow(map[string]interface{}{
+			"path":  "Total by " + *weightBy,
+			"score": percentage(c.overallScore),
+		}))
+	}
+	var caption []string
+	if filtered() {
+		caption = append(caption, fmt.Sprintf("Showing %d of %d files, those scoring %v to %v; totals are of all files.", count, len(c.results), percentage(*minScore), percentage(*maxScore)))
+	} else if cut > 0 {
+		caption = append(caption, fmt.Sprintf("Showing the first %d of %d files; totals are of all files.", count, count+cut))
+	}
+	if *excludeNew {
+		caption = append(caption, "Total scores leave out new files.")
+	}
+	if len(caption) > 0 {
+		tw.SetCaption("%s", strings.Join(caption, " "))
+	}
+	tw.SetColumnConfigs(columnConfigs())
+	// Rows are colored by score, so without that column they aren't colored.
+	if scoreAt := columnIndex("score"); scoreAt >= 0 && !*noColor && !*plainTable {
+		tw.SetRowPainter(func(row table.Row) text.Colors {
+			// Directory headings don't have a score.
+			pct, ok := row[scoreAt].(percentage)
+			if !ok {
+				return text.Colors{text.Bold}
+			}
+			return scoreColors(pct)
+		})
+	}
+	return tw.Render()
+}
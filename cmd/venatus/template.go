@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+)
+
+var templatePath = flag.String("template", "", "print the results through this Go text/template instead of the usual report, to make a team's own report format; it is given .Score, .LineScore, .Lines, .Files (each with .Path, .Dir, .Match, .License, .Score, .Confidence, .Lines, .Tokens, .Bytes, .Complexity, .Weight, .Kind, .TimedOut, .Approximate, .Conflicts, and .Semantic), .Metadata, and .Coverage, and the functions percent and json")
+
+// The parsed --template.
+var reportTemplate *template.Template
+
+var templateFuncs = template.FuncMap{
+	"percent": func(f float64) string { return percentage(f).String() },
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// checkTemplate parses --template up front, so that mistakes in it are found before a long run
+// rather than after.
+func checkTemplate() error {
+	if *templatePath == "" {
+		return nil
+	}
+	if *outputFormat == "jsonl" {
+		return fmt.Errorf("--template replaces the report, so it can't be used with --format=jsonl")
+	}
+	t, err := template.New(filepath.Base(*templatePath)).Funcs(templateFuncs).ParseFiles(*templatePath)
+	if err != nil {
+		return fmt.Errorf("--template: %w", err)
+	}
+	reportTemplate = t
+	return nil
+}
+
+// templateData is what --template is given.
+type templateData struct {
+	Score     float64
+	LineScore float64
+	Lines     int
+	Files     []templateFile
+	Metadata  *runMetadata
+	Coverage  *runCoverage
+}
+
+// A templateFile is a target file, as --template is given it.
+type templateFile struct {
+	resultView
+	Tokens, Bytes, Complexity int
+	// What generated or vendored code the file looks like, with --generated=tag.
+	Kind                  string
+	TimedOut, Approximate bool
+	// Other source files that matched nearly as well.
+	Conflicts []string
+	// How alike the embeddings of the file and its match are, with --embeddings.
+	Semantic *float64
+}
+
+// printTemplate prints the results through --template.
+func printTemplate(c *comparison) error {
+	data := templateData{
+		Score:     c.overallScore,
+		LineScore: c.lineScore,
+		Lines:     c.totalLineCount,
+		Metadata:  c.metadata,
+		Coverage:  c.coverage(),
+	}
+	for i, view := range c.views() {
+		result := c.results[i]
+		f := templateFile{
+			resultView:  view,
+			Tokens:      result.tokenCount,
+			Bytes:       result.byteCount,
+			Complexity:  result.complexity,
+			Kind:        c.targetReport.tagged[result.filename],
+			TimedOut:    result.timedOut,
+			Approximate: result.approximate,
+			Semantic:    result.semantic,
+		}
+		for _, other := range result.conflicts {
+			f.Conflicts = append(f.Conflicts, c.sourceLabel(other.filename))
+		}
+		data.Files = append(data.Files, f)
+	}
+	return reportTemplate.Execute(os.Stdout, data)
+}
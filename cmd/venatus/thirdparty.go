@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+)
+
+var knownSnippets = flag.String("known-snippets", "", "directory (or index) of well-known third-party code, such as public-domain hash implementations, stb headers, or small MIT-licensed helpers, a file per snippet; report regions of target files of at least --snippet-min-lines lines copied from any of them, whatever their whole-file matches")
+
+// matchKnownSnippets looks for code from the --known-snippets corpus in every target file. Each
+// snippet is named by its path in the corpus, so a corpus laid out like sha256/brad-conte.c names
+// what it finds well, and its license is found as for source files.
+func (c *comparison) matchKnownSnippets() error {
+	corpus := &sourceRepo{root: *knownSnippets}
+	files, _, err := openSource(corpus, nil)
+	if err != nil {
+		return fmt.Errorf("--known-snippets: %w", err)
+	}
+	c.snippetLicenses = make(map[string]string)
+	index := indexShinglesOf(files)
+	if c.lineNumbers == nil {
+		c.lineNumbers = make(map[string][]int)
+	}
+	licenses := newLicenseFinder()
+	for _, result := range c.results {
+		result.thirdParty = c.copiedRegions(index, files, result.filename)
+		for _, s := range result.thirdParty {
+			if _, ok := c.snippetLicenses[s.sourceFile]; !ok {
+				c.snippetLicenses[s.sourceFile] = licenses.licenseOf(corpus.root, s.sourceFile)
+			}
+		}
+	}
+	slog.Debug("Searched for known snippets", "snippets", len(files))
+	return nil
+}
+
+// printThirdParty lists the regions of target files found in --known-snippets.
+func printThirdParty(c *comparison) {
+	if *knownSnippets == "" {
+		return
+	}
+	var found []*findResult
+	for _, result := range c.results {
+		if len(result.thirdParty) > 0 {
+			found = append(found, result)
+		}
+	}
+	if len(found) == 0 {
+		fmt.Printf("\n\nNo known third-party snippets found.\n")
+		return
+	}
+	fmt.Printf("\n\n%d target files contain known third-party snippets:\n", len(found))
+	for _, result := range found {
+		fmt.Println(c.relTarget(result.filename))
+		for _, s := range result.thirdParty {
+			license := ""
+			if l := c.snippetLicenses[s.sourceFile]; l != "" {
+				license = ", " + l
+			}
+			fmt.Printf("  lines %d-%d ~ %s lines %d-%d (%v%s)\n", s.targetStart, s.targetEnd, relTo(*knownSnippets, s.sourceFile), s.sourceStart, s.sourceEnd, percentage(s.similarity), license)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+
+	"golang.org/x/sync/errgroup"
+)
+
+var retryTimeout = flag.Duration("retry-timeout", 0, "once every file has been compared, diff the matches whose diff hit --pair-timeout again, giving each this long, so that their scores are exact rather than estimates (0 not to retry)")
+
+func init() {
+	// --pair-timeout is the newer name of --diff-timeout, which scripts may still use.
+	flag.DurationVar(diffTimeout, "pair-timeout", *diffTimeout, "how long to spend diffing any one pair of files before settling for a rougher diff, whose score is only an estimate and is marked as such (same as --diff-timeout)")
+}
+
+func checkRetryTimeout() error {
+	if *retryTimeout < 0 {
+		return fmt.Errorf("--retry-timeout must not be negative")
+	}
+	if *retryTimeout > 0 && *retryTimeout <= *diffTimeout {
+		return fmt.Errorf("--retry-timeout must be longer than --pair-timeout (%v)", *diffTimeout)
+	}
+	return nil
+}
+
+// retryTimedOut diffs the matches that timed out again with --retry-timeout, keeping the matches
+// but updating their scores. Only the chosen match is diffed again, not every candidate.
+func (c *comparison) retryTimedOut() {
+	var timedOut []*findResult
+	for _, result := range c.results {
+		if result.timedOut && !result.approximate && result.matchedFilename != "N/A" {
+			timedOut = append(timedOut, result)
+		}
+	}
+	if len(timedOut) == 0 {
+		return
+	}
+	slog.Info("Diffing timed-out matches again", "files", len(timedOut), "timeout", *retryTimeout)
+	// Other comparisons may be diffing at the same time, as under serve, so the usual settings are
+	// left alone.
+	differ := *dmp
+	differ.DiffTimeout = *retryTimeout
+	var retries errgroup.Group
+	retries.SetLimit(max(*workers, 1))
+	for _, result := range timedOut {
+		result := result
+		retries.Go(func() error {
+			d := cachedDiffWith(&differ, c.targetContents(result.filename), c.sourceFiles[result.matchedFilename])
+			score, stillTimedOut := d.asPercentage(), d.timedOut
+			result.matchSimilarity = calibrate(result.filename, score)
+			result.timedOut = stillTimedOut
+			// The other candidates are gone by now, bar those that scored close to the match.
+			result.confidence = result.confidenceAmong(result.conflicts, stillTimedOut)
+			return nil
+		})
+	}
+	retries.Wait()
+}
+
+// printTimedOut says how many scores are estimates because diffing their files timed out.
+func printTimedOut(c *comparison) {
+	n := 0
+	for _, result := range c.results {
+		if result.timedOut {
+			n++
+		}
+	}
+	if n == 0 {
+		return
+	}
+	hint := "; rerun with --retry-timeout to diff them for longer"
+	if *retryTimeout > 0 {
+		hint = fmt.Sprintf(", even given --retry-timeout (%v)", *retryTimeout)
+	}
+	fmt.Printf("\n\n%d target files' scores are estimates, as diffing them hit --pair-timeout (%v)%s.\n", n, *diffTimeout, hint)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	cpuProfile = flag.String("cpuprofile", "", "file to write a CPU profile of the run to, for go tool pprof")
+	memProfile = flag.String("memprofile", "", "file to write a heap profile to at the end of the run, for go tool pprof")
+	showTiming = flag.Bool("timing", false, "print how long was spent in each phase of the comparison")
+)
+
+// A phase of a comparison, timed for --timing.
+type phase int
+
+const (
+	walking phase = iota
+	normalizing
+	comparing
+	filtering
+	diffing
+	numPhases
+)
+
+// Time spent in each phase. Phases run by several workers at once add up the time of each.
+var phaseTimes [numPhases]atomic.Int64
+
+// timeSince adds the time since start to a phase; use it as
+//
+//	defer timeSince(diffing, time.Now())
+func timeSince(p phase, start time.Time) {
+	phaseTimes[p].Add(int64(time.Since(start)))
+}
+
+func phaseTime(p phase) time.Duration {
+	return time.Duration(phaseTimes[p].Load()).Round(time.Millisecond)
+}
+
+// startProfiling starts the profiling asked for with --cpuprofile and --memprofile, returning a
+// function that finishes it.
+func startProfiling() (func() error, error) {
+	stopCPU := func() {}
+	if *cpuProfile != "" {
+		f, err := os.Create(*cpuProfile)
+		if err != nil {
+			return nil, err
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+		stopCPU = func() {
+			pprof.StopCPUProfile()
+			f.Close()
+		}
+	}
+	return func() error {
+		stopCPU()
+		if *memProfile == "" {
+			return nil
+		}
+		f, err := os.Create(*memProfile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		runtime.GC()
+		return pprof.WriteHeapProfile(f)
+	}, nil
+}
+
+// printTiming prints how long each phase took, with --timing.
+func printTiming(total time.Duration) {
+	if !*showTiming {
+		return
+	}
+	fmt.Print("\n\nTiming:\n")
+	fmt.Printf("  walking and reading files  %v\n", phaseTime(walking))
+	fmt.Printf("    of which normalizing     %v (summed over readers)\n", phaseTime(normalizing))
+	fmt.Printf("  comparing files            %v\n", phaseTime(comparing))
+	fmt.Printf("    filtering candidates     %v (summed over workers)\n", phaseTime(filtering))
+	fmt.Printf("    diffing                  %v (summed over workers)\n", phaseTime(diffing))
+	fmt.Printf("  total                      %v\n", total.Round(time.Millisecond))
+}
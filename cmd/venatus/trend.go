@@ -0,0 +1,192 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+//go:embed trend.html
+var trendHTML string
+
+var trendTemplate = template.Must(template.New("trend").Parse(trendHTML))
+
+// Size of each chart in the HTML trend, in pixels.
+const trendWidth, trendHeight = 600, 120
+
+// The blocks a sparkline is drawn with, from lowest to highest.
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// A trendSeries is a score over a series of runs, by index. Runs it wasn't in are left out.
+type trendSeries struct {
+	name   string
+	runs   []int
+	scores []float64
+}
+
+// sparkline draws scores from 0 to 1 as a line of blocks, one per score.
+func sparkline(scores []float64) string {
+	var b strings.Builder
+	for _, s := range scores {
+		i := int(s * float64(len(sparks)))
+		b.WriteRune(sparks[max(0, min(i, len(sparks)-1))])
+	}
+	return b.String()
+}
+
+// readSummaries reads --summary-json files, oldest run first.
+func readSummaries(paths []string) ([]*summary, error) {
+	var runs []*summary
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		var s summary
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		runs = append(runs, &s)
+	}
+	// Summaries from before runs recorded when they started keep the order they were given in.
+	sort.SliceStable(runs, func(i, j int) bool {
+		if runs[i].Metadata == nil || runs[j].Metadata == nil {
+			return false
+		}
+		return runs[i].Metadata.Started.Before(runs[j].Metadata.Started)
+	})
+	return runs, nil
+}
+
+// trends works out the series of scores to chart: the overall score, and then those of each
+// directory or file, per by. A directory's score is the mean of its files'. Only files under
+// prefix are counted.
+func trends(runs []*summary, by, prefix string) []*trendSeries {
+	overall := &trendSeries{name: "overall"}
+	byName := make(map[string]*trendSeries)
+	for i, run := range runs {
+		overall.runs = append(overall.runs, i)
+		overall.scores = append(overall.scores, run.OverallScore)
+		if by == "total" {
+			continue
+		}
+		sums := make(map[string]float64)
+		counts := make(map[string]int)
+		for file, score := range run.Scores {
+			if !strings.HasPrefix(file, prefix) {
+				continue
+			}
+			name := file
+			if by == "dir" {
+				name = path.Dir(file)
+			}
+			sums[name] += score
+			counts[name]++
+		}
+		for _, name := range sortedKeys(sums) {
+			series, ok := byName[name]
+			if !ok {
+				series = &trendSeries{name: name}
+				byName[name] = series
+			}
+			series.runs = append(series.runs, i)
+			series.scores = append(series.scores, sums[name]/float64(counts[name]))
+		}
+	}
+	all := []*trendSeries{overall}
+	for _, name := range sortedKeys(byName) {
+		all = append(all, byName[name])
+	}
+	return all
+}
+
+type trendDot struct {
+	X, Y  int
+	Label string
+}
+
+type trendSeriesView struct {
+	Name   string
+	Last   percentage
+	Points string
+	Dots   []trendDot
+}
+
+// writeTrendHTML writes the series as a page of line charts.
+func writeTrendHTML(out string, runs []*summary, all []*trendSeries) error {
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var views []trendSeriesView
+	for _, series := range all {
+		view := trendSeriesView{Name: series.name, Last: percentage(series.scores[len(series.scores)-1])}
+		var points []string
+		for i, run := range series.runs {
+			x := 20 + (trendWidth-40)*run/max(1, len(runs)-1)
+			y := 5 + int(float64(trendHeight-10)*(1-series.scores[i]))
+			points = append(points, fmt.Sprintf("%d,%d", x, y))
+			label := fmt.Sprintf("run %d: %v", run+1, percentage(series.scores[i]))
+			if md := runs[run].Metadata; md != nil {
+				label = fmt.Sprintf("%s: %v", md.Started.Format("2006-01-02 15:04"), percentage(series.scores[i]))
+			}
+			view.Dots = append(view.Dots, trendDot{X: x, Y: y, Label: label})
+		}
+		view.Points = strings.Join(points, " ")
+		views = append(views, view)
+	}
+	err = trendTemplate.Execute(f, struct {
+		Target        string
+		Width, Height int
+		Series        []trendSeriesView
+	}{runs[len(runs)-1].Target, trendWidth, trendHeight, views})
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+var (
+	historyFlags = flag.NewFlagSet("history", flag.ContinueOnError)
+	historyBy    = historyFlags.String("by", "total", "what to chart besides the overall score: total (nothing else), dir, or file")
+	historyPath  = historyFlags.String("path", "", "only chart directories and files under this path of the target")
+	historyHTML  = historyFlags.String("html", "", "also write the charts to this HTML file")
+)
+
+// trend charts how scores have changed over a series of runs, from their --summary-json files,
+// to show whether the target is drifting further from upstream.
+func trend(args []string) error {
+	switch *historyBy {
+	case "total", "dir", "file":
+	default:
+		return fmt.Errorf("--by: unknown value %q (want total, dir, or file)", *historyBy)
+	}
+	if len(args) == 0 {
+		return errors.New("usage: venatus history [flags] SUMMARY.json...")
+	}
+	runs, err := readSummaries(args)
+	if err != nil {
+		return err
+	}
+	all := trends(runs, *historyBy, *historyPath)
+	width := 0
+	for _, series := range all {
+		width = max(width, len(series.name))
+	}
+	for _, series := range all {
+		first, last := series.scores[0], series.scores[len(series.scores)-1]
+		fmt.Printf("%-*s  %s  %v -> %v\n", width, series.name, sparkline(series.scores), percentage(first), percentage(last))
+	}
+	if *historyHTML != "" {
+		return writeTrendHTML(*historyHTML, runs, all)
+	}
+	return nil
+}
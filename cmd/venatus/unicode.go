@@ -0,0 +1,145 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	composeUnicode = flag.Bool("compose-unicode", true, "compose letters written as a base letter and a combining accent into the single characters they stand for, as in Unicode's NFC form, so that the two ways of writing é compare equal")
+	foldHomoglyphs = flag.Bool("fold-homoglyphs", false, "treat characters that look like ASCII ones as those, e.g. Cyrillic а as a, fullwidth letters as ASCII, and non-breaking and other unusual spaces as spaces, and drop zero-width characters, so that copies disguised with lookalikes are still found")
+)
+
+// normalizeUnicode applies --compose-unicode and --fold-homoglyphs to text.
+func normalizeUnicode(text string) string {
+	if isASCII(text) {
+		return text
+	}
+	if *composeUnicode {
+		text = compose(text)
+	}
+	if *foldHomoglyphs {
+		text = foldLookalikes(text)
+	}
+	return text
+}
+
+func isASCII(text string) bool {
+	for i := 0; i < len(text); i++ {
+		if text[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// compose replaces each letter followed by a combining accent with the precomposed letter, where
+// there is one. This covers the Latin letters, which is what code and its comments mostly use;
+// it isn't full NFC, which would take Unicode's whole composition table.
+func compose(text string) string {
+	var sb strings.Builder
+	sb.Grow(len(text))
+	var prev rune = -1
+	for _, r := range text {
+		if prev >= 0 {
+			if composed, ok := compositions[[2]rune{prev, r}]; ok {
+				prev = composed
+				continue
+			}
+			sb.WriteRune(prev)
+		}
+		prev = r
+	}
+	if prev >= 0 {
+		sb.WriteRune(prev)
+	}
+	return sb.String()
+}
+
+// foldLookalikes replaces characters that look like ASCII ones with those, for --fold-homoglyphs.
+func foldLookalikes(text string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 0xFF01 && r <= 0xFF5E:
+			// Fullwidth forms of ASCII.
+			return r - 0xFEE0
+		case r == 0x00A0 || r >= 0x2000 && r <= 0x200A || r == 0x202F || r == 0x205F || r == 0x3000:
+			return ' '
+		case r >= 0x200B && r <= 0x200D || r == 0x2060 || r == 0xFEFF:
+			return -1
+		}
+		if ascii, ok := lookalikes[r]; ok {
+			return ascii
+		}
+		return r
+	}, text)
+}
+
+// Letters of other scripts, and punctuation, that are easily mistaken for ASCII characters.
+var lookalikes = map[rune]rune{
+	// Cyrillic.
+	'А': 'A', 'В': 'B', 'Е': 'E', 'К': 'K', 'М': 'M', 'Н': 'H', 'О': 'O', 'Р': 'P', 'С': 'C', 'Т': 'T',
+	'Х': 'X', 'Ѕ': 'S', 'І': 'I', 'Ј': 'J', 'а': 'a', 'е': 'e', 'о': 'o', 'р': 'p', 'с': 'c', 'у': 'y',
+	'х': 'x', 'ѕ': 's', 'і': 'i', 'ј': 'j', 'ԁ': 'd', 'ԛ': 'q', 'ԝ': 'w',
+	// Greek.
+	'Α': 'A', 'Β': 'B', 'Ε': 'E', 'Ζ': 'Z', 'Η': 'H', 'Ι': 'I', 'Κ': 'K', 'Μ': 'M', 'Ν': 'N', 'Ο': 'O',
+	'Ρ': 'P', 'Τ': 'T', 'Υ': 'Y', 'Χ': 'X', 'ο': 'o',
+	// Punctuation.
+	'‘': '\'', '’': '\'', '“': '"', '”': '"', '‐': '-', '‑': '-', '‒': '-', '–': '-', '—': '-', '−': '-',
+}
+
+// Precomposed Latin letters, by the letter and combining accent they are composed of.
+var compositions = map[[2]rune]rune{
+	{'A', 0x0300}: 'À', {'A', 0x0301}: 'Á', {'A', 0x0302}: 'Â', {'A', 0x0303}: 'Ã', {'A', 0x0308}: 'Ä',
+	{'A', 0x030A}: 'Å', {'C', 0x0327}: 'Ç', {'E', 0x0300}: 'È', {'E', 0x0301}: 'É', {'E', 0x0302}: 'Ê',
+	{'E', 0x0308}: 'Ë', {'I', 0x0300}: 'Ì', {'I', 0x0301}: 'Í', {'I', 0x0302}: 'Î', {'I', 0x0308}: 'Ï',
+	{'N', 0x0303}: 'Ñ', {'O', 0x0300}: 'Ò', {'O', 0x0301}: 'Ó', {'O', 0x0302}: 'Ô', {'O', 0x0303}: 'Õ',
+	{'O', 0x0308}: 'Ö', {'U', 0x0300}: 'Ù', {'U', 0x0301}: 'Ú', {'U', 0x0302}: 'Û', {'U', 0x0308}: 'Ü',
+	{'Y', 0x0301}: 'Ý', {'a', 0x0300}: 'à', {'a', 0x0301}: 'á', {'a', 0x0302}: 'â', {'a', 0x0303}: 'ã',
+	{'a', 0x0308}: 'ä', {'a', 0x030A}: 'å', {'c', 0x0327}: 'ç', {'e', 0x0300}: 'è', {'e', 0x0301}: 'é',
+	{'e', 0x0302}: 'ê', {'e', 0x0308}: 'ë', {'i', 0x0300}: 'ì', {'i', 0x0301}: 'í', {'i', 0x0302}: 'î',
+	{'i', 0x0308}: 'ï', {'n', 0x0303}: 'ñ', {'o', 0x0300}: 'ò', {'o', 0x0301}: 'ó', {'o', 0x0302}: 'ô',
+	{'o', 0x0303}: 'õ', {'o', 0x0308}: 'ö', {'u', 0x0300}: 'ù', {'u', 0x0301}: 'ú', {'u', 0x0302}: 'û',
+	{'u', 0x0308}: 'ü', {'y', 0x0301}: 'ý', {'y', 0x0308}: 'ÿ', {'A', 0x0304}: 'Ā', {'a', 0x0304}: 'ā',
+	{'A', 0x0306}: 'Ă', {'a', 0x0306}: 'ă', {'A', 0x0328}: 'Ą', {'a', 0x0328}: 'ą', {'C', 0x0301}: 'Ć',
+	{'c', 0x0301}: 'ć', {'C', 0x0302}: 'Ĉ', {'c', 0x0302}: 'ĉ', {'C', 0x0307}: 'Ċ', {'c', 0x0307}: 'ċ',
+	{'C', 0x030C}: 'Č', {'c', 0x030C}: 'č', {'D', 0x030C}: 'Ď', {'d', 0x030C}: 'ď', {'E', 0x0304}: 'Ē',
+	{'e', 0x0304}: 'ē', {'E', 0x0306}: 'Ĕ', {'e', 0x0306}: 'ĕ', {'E', 0x0307}: 'Ė', {'e', 0x0307}: 'ė',
+	{'E', 0x0328}: 'Ę', {'e', 0x0328}: 'ę', {'E', 0x030C}: 'Ě', {'e', 0x030C}: 'ě', {'G', 0x0302}: 'Ĝ',
+	{'g', 0x0302}: 'ĝ', {'G', 0x0306}: 'Ğ', {'g', 0x0306}: 'ğ', {'G', 0x0307}: 'Ġ', {'g', 0x0307}: 'ġ',
+	{'G', 0x0327}: 'Ģ', {'g', 0x0327}: 'ģ', {'H', 0x0302}: 'Ĥ', {'h', 0x0302}: 'ĥ', {'I', 0x0303}: 'Ĩ',
+	{'i', 0x0303}: 'ĩ', {'I', 0x0304}: 'Ī', {'i', 0x0304}: 'ī', {'I', 0x0306}: 'Ĭ', {'i', 0x0306}: 'ĭ',
+	{'I', 0x0328}: 'Į', {'i', 0x0328}: 'į', {'I', 0x0307}: 'İ', {'J', 0x0302}: 'Ĵ', {'j', 0x0302}: 'ĵ',
+	{'K', 0x0327}: 'Ķ', {'k', 0x0327}: 'ķ', {'L', 0x0301}: 'Ĺ', {'l', 0x0301}: 'ĺ', {'L', 0x0327}: 'Ļ',
+	{'l', 0x0327}: 'ļ', {'L', 0x030C}: 'Ľ', {'l', 0x030C}: 'ľ', {'N', 0x0301}: 'Ń', {'n', 0x0301}: 'ń',
+	{'N', 0x0327}: 'Ņ', {'n', 0x0327}: 'ņ', {'N', 0x030C}: 'Ň', {'n', 0x030C}: 'ň', {'O', 0x0304}: 'Ō',
+	{'o', 0x0304}: 'ō', {'O', 0x0306}: 'Ŏ', {'o', 0x0306}: 'ŏ', {'O', 0x030B}: 'Ő', {'o', 0x030B}: 'ő',
+	{'R', 0x0301}: 'Ŕ', {'r', 0x0301}: 'ŕ', {'R', 0x0327}: 'Ŗ', {'r', 0x0327}: 'ŗ', {'R', 0x030C}: 'Ř',
+	{'r', 0x030C}: 'ř', {'S', 0x0301}: 'Ś', {'s', 0x0301}: 'ś', {'S', 0x0302}: 'Ŝ', {'s', 0x0302}: 'ŝ',
+	{'S', 0x0327}: 'Ş', {'s', 0x0327}: 'ş', {'S', 0x030C}: 'Š', {'s', 0x030C}: 'š', {'T', 0x0327}: 'Ţ',
+	{'t', 0x0327}: 'ţ', {'T', 0x030C}: 'Ť', {'t', 0x030C}: 'ť', {'U', 0x0303}: 'Ũ', {'u', 0x0303}: 'ũ',
+	{'U', 0x0304}: 'Ū', {'u', 0x0304}: 'ū', {'U', 0x0306}: 'Ŭ', {'u', 0x0306}: 'ŭ', {'U', 0x030A}: 'Ů',
+	{'u', 0x030A}: 'ů', {'U', 0x030B}: 'Ű', {'u', 0x030B}: 'ű', {'U', 0x0328}: 'Ų', {'u', 0x0328}: 'ų',
+	{'W', 0x0302}: 'Ŵ', {'w', 0x0302}: 'ŵ', {'Y', 0x0302}: 'Ŷ', {'y', 0x0302}: 'ŷ', {'Y', 0x0308}: 'Ÿ',
+	{'Z', 0x0301}: 'Ź', {'z', 0x0301}: 'ź', {'Z', 0x0307}: 'Ż', {'z', 0x0307}: 'ż', {'Z', 0x030C}: 'Ž',
+	{'z', 0x030C}: 'ž', {'O', 0x031B}: 'Ơ', {'o', 0x031B}: 'ơ', {'U', 0x031B}: 'Ư', {'u', 0x031B}: 'ư',
+	{'A', 0x030C}: 'Ǎ', {'a', 0x030C}: 'ǎ', {'I', 0x030C}: 'Ǐ', {'i', 0x030C}: 'ǐ', {'O', 0x030C}: 'Ǒ',
+	{'o', 0x030C}: 'ǒ', {'U', 0x030C}: 'Ǔ', {'u', 0x030C}: 'ǔ', {'Ü', 0x0304}: 'Ǖ', {'ü', 0x0304}: 'ǖ',
+	{'Ü', 0x0301}: 'Ǘ', {'ü', 0x0301}: 'ǘ', {'Ü', 0x030C}: 'Ǚ', {'ü', 0x030C}: 'ǚ', {'Ü', 0x0300}: 'Ǜ',
+	{'ü', 0x0300}: 'ǜ', {'Ä', 0x0304}: 'Ǟ', {'ä', 0x0304}: 'ǟ', {'Ȧ', 0x0304}: 'Ǡ', {'ȧ', 0x0304}: 'ǡ',
+	{'Æ', 0x0304}: 'Ǣ', {'æ', 0x0304}: 'ǣ', {'G', 0x030C}: 'Ǧ', {'g', 0x030C}: 'ǧ', {'K', 0x030C}: 'Ǩ',
+	{'k', 0x030C}: 'ǩ', {'O', 0x0328}: 'Ǫ', {'o', 0x0328}: 'ǫ', {'Ǫ', 0x0304}: 'Ǭ', {'ǫ', 0x0304}: 'ǭ',
+	{'Ʒ', 0x030C}: 'Ǯ', {'ʒ', 0x030C}: 'ǯ', {'j', 0x030C}: 'ǰ', {'G', 0x0301}: 'Ǵ', {'g', 0x0301}: 'ǵ',
+	{'N', 0x0300}: 'Ǹ', {'n', 0x0300}: 'ǹ', {'Å', 0x0301}: 'Ǻ', {'å', 0x0301}: 'ǻ', {'Æ', 0x0301}: 'Ǽ',
+	{'æ', 0x0301}: 'ǽ', {'Ø', 0x0301}: 'Ǿ', {'ø', 0x0301}: 'ǿ', {'A', 0x030F}: 'Ȁ', {'a', 0x030F}: 'ȁ',
+	{'A', 0x0311}: 'Ȃ', {'a', 0x0311}: 'ȃ', {'E', 0x030F}: 'Ȅ', {'e', 0x030F}: 'ȅ', {'E', 0x0311}: 'Ȇ',
+	{'e', 0x0311}: 'ȇ', {'I', 0x030F}: 'Ȉ', {'i', 0x030F}: 'ȉ', {'I', 0x0311}: 'Ȋ', {'i', 0x0311}: 'ȋ',
+	{'O', 0x030F}: 'Ȍ', {'o', 0x030F}: 'ȍ', {'O', 0x0311}: 'Ȏ', {'o', 0x0311}: 'ȏ', {'R', 0x030F}: 'Ȑ',
+	{'r', 0x030F}: 'ȑ', {'R', 0x0311}: 'Ȓ', {'r', 0x0311}: 'ȓ', {'U', 0x030F}: 'Ȕ', {'u', 0x030F}: 'ȕ',
+	{'U', 0x0311}: 'Ȗ', {'u', 0x0311}: 'ȗ', {'S', 0x0326}: 'Ș', {'s', 0x0326}: 'ș', {'T', 0x0326}: 'Ț',
+	{'t', 0x0326}: 'ț', {'H', 0x030C}: 'Ȟ', {'h', 0x030C}: 'ȟ', {'A', 0x0307}: 'Ȧ', {'a', 0x0307}: 'ȧ',
+	{'E', 0x0327}: 'Ȩ', {'e', 0x0327}: 'ȩ', {'Ö', 0x0304}: 'Ȫ', {'ö', 0x0304}: 'ȫ', {'Õ', 0x0304}: 'Ȭ',
+	{'õ', 0x0304}: 'ȭ', {'O', 0x0307}: 'Ȯ', {'o', 0x0307}: 'ȯ', {'Ȯ', 0x0304}: 'Ȱ', {'ȯ', 0x0304}: 'ȱ',
+	{'Y', 0x0304}: 'Ȳ', {'y', 0x0304}: 'ȳ',
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// How many lines of unchanged context to print around each hunk.
+const diffContext = 3
+
+const (
+	diffEqual  = diffmatchpatch.DiffEqual
+	diffDelete = diffmatchpatch.DiffDelete
+	diffInsert = diffmatchpatch.DiffInsert
+)
+
+type diffLine struct {
+	op   diffmatchpatch.Operation
+	text string
+}
+
+// splitLines splits text into lines, keeping the trailing newline on each one.
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// lineRune maps the i'th distinct line onto a rune, skipping over the surrogate range.
+func lineRune(i int) rune {
+	r := rune(i + 1)
+	if r >= 0xD800 {
+		r += 0x800
+	}
+	return r
+}
+
+func lineIndex(r rune) int {
+	if r >= 0xE000 {
+		r -= 0x800
+	}
+	return int(r - 1)
+}
+
+// lineDiff computes a line-oriented diff of two texts.
+// Each distinct line is encoded as a single rune so that diffmatchpatch can do the heavy lifting.
+// (diffmatchpatch's own DiffLinesToChars encodes lines as comma-separated indices, which the
+// character-level diff then happily splits in the middle of.)
+func lineDiff(from, to string) []diffLine {
+	index := make(map[string]rune)
+	var table []string
+	encode := func(text string) []rune {
+		lines := splitLines(text)
+		runes := make([]rune, len(lines))
+		for i, line := range lines {
+			r, ok := index[line]
+			if !ok {
+				r = lineRune(len(table))
+				index[line] = r
+				table = append(table, line)
+			}
+			runes[i] = r
+		}
+		return runes
+	}
+	fromRunes := encode(from)
+	toRunes := encode(to)
+
+	var result []diffLine
+	for _, d := range dmp.DiffMainRunes(fromRunes, toRunes, false) {
+		for _, r := range d.Text {
+			result = append(result, diffLine{op: d.Type, text: table[lineIndex(r)]})
+		}
+	}
+	return result
+}
+
+// unifiedDiff renders the changes needed to turn from into to, in the unified diff format.
+// Returns the empty string if the texts are identical.
+func unifiedDiff(fromName, toName, from, to string) string {
+	lines := lineDiff(from, to)
+
+	// Line numbers (1-based) in each file at which each diff line sits.
+	fromLineNo := make([]int, len(lines))
+	toLineNo := make([]int, len(lines))
+	f, t := 1, 1
+	for i, line := range lines {
+		fromLineNo[i] = f
+		toLineNo[i] = t
+		if line.op != diffmatchpatch.DiffInsert {
+			f++
+		}
+		if line.op != diffmatchpatch.DiffDelete {
+			t++
+		}
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(lines); {
+		if lines[i].op == diffmatchpatch.DiffEqual {
+			i++
+			continue
+		}
+		// Extend the hunk until we see enough unchanged lines in a row to start a new one.
+		start := max(i-diffContext, 0)
+		last := i
+		for j := i; j < len(lines) && j-last <= 2*diffContext; j++ {
+			if lines[j].op != diffmatchpatch.DiffEqual {
+				last = j
+			}
+		}
+		end := min(last+1+diffContext, len(lines))
+
+		fromCount, toCount := 0, 0
+		for _, line := range lines[start:end] {
+			if line.op != diffmatchpatch.DiffInsert {
+				fromCount++
+			}
+			if line.op != diffmatchpatch.DiffDelete {
+				toCount++
+			}
+		}
+		fromStart, toStart := fromLineNo[start], toLineNo[start]
+		// By convention, an empty range is reported as starting on the line before it.
+		if fromCount == 0 {
+			fromStart--
+		}
+		if toCount == 0 {
+			toStart--
+		}
+
+		if sb.Len() == 0 {
+			fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", fromStart, fromCount, toStart, toCount)
+		for _, line := range lines[start:end] {
+			switch line.op {
+			case diffmatchpatch.DiffEqual:
+				sb.WriteRune(' ')
+			case diffmatchpatch.DiffDelete:
+				sb.WriteRune('-')
+			case diffmatchpatch.DiffInsert:
+				sb.WriteRune('+')
+			}
+			sb.WriteString(line.text)
+			if !strings.HasSuffix(line.text, "\n") {
+				sb.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = end
+	}
+	return sb.String()
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+var findUpstream = flag.Bool("find-upstream-commit", false, "for target files that don't match exactly, search the git history of their match for the revision they match best, to find which upstream snapshot was copied")
+
+// At most this many revisions of each file are compared, newest first.
+const maxUpstreamRevisions = 500
+
+// An upstreamRevision is a revision of a source file from its git history.
+type upstreamRevision struct {
+	hash, date string
+	similarity float64
+}
+
+// revisions lists the commits that changed a file, newest first, with the file's path (relative
+// to the top of the repo) as of each commit, following renames.
+func revisions(path string) (commits []upstreamRevision, paths []string, err error) {
+	out, err := exec.Command("git", "-C", filepath.Dir(path), "log", "--follow", "--format=%h %cs", "--name-only",
+		fmt.Sprintf("-%d", maxUpstreamRevisions), "--", filepath.Base(path)).Output()
+	if err != nil {
+		return nil, nil, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		if len(commits) > len(paths) {
+			paths = append(paths, line)
+			continue
+		}
+		hash, date, _ := strings.Cut(line, " ")
+		commits = append(commits, upstreamRevision{hash: hash, date: date})
+	}
+	return commits[:len(paths)], paths, scanner.Err()
+}
+
+// bestRevision finds the revision of the matched source file that a target file is most like.
+func (c *comparison) bestRevision(result *findResult) (*upstreamRevision, error) {
+	info, err := os.Lstat(result.matchedFilename)
+	if err != nil {
+		return nil, err
+	}
+	lang := languageOf(result.matchedFilename, info)
+	commits, paths, err := revisions(result.matchedFilename)
+	if err != nil {
+		return nil, err
+	}
+	var best *upstreamRevision
+	for i := range commits {
+		rev := &commits[i]
+		data, err := exec.Command("git", "-C", filepath.Dir(result.matchedFilename), "show", rev.hash+":"+paths[i]).Output()
+		if err != nil {
+			slog.Debug("Couldn't read revision", "path", paths[i], "commit", rev.hash, "err", err)
+			continue
+		}
+		code, _, err := normalizeCode(result.matchedFilename, data, lang)
+		if err != nil {
+			continue
+		}
+		rev.similarity, _ = similarity(c.targetContents(result.filename), code)
+		// The newest of equally good revisions wins, since it was seen first.
+		if best == nil || rev.similarity > best.similarity {
+			best = rev
+		}
+	}
+	return best, nil
+}
+
+// matchUpstream finds the best revision of the match of each target file that isn't an exact copy.
+func (c *comparison) matchUpstream() {
+	for _, result := range c.results {
+		if result.matchSimilarity <= 0 || result.matchSimilarity >= 1 {
+			continue
+		}
+		rev, err := c.bestRevision(result)
+		if err != nil {
+			slog.Warn("Couldn't search history", "path", result.matchedFilename, "err", err)
+			continue
+		}
+		result.upstream = rev
+	}
+}
+
+// printUpstream lists the revisions found with --find-upstream-commit.
+func printUpstream(c *comparison) {
+	var found []*findResult
+	for _, result := range c.results {
+		if result.upstream != nil {
+			found = append(found, result)
+		}
+	}
+	if len(found) == 0 {
+		return
+	}
+	fmt.Printf("\n\nRevisions of their matches that %d target files are most like:\n", len(found))
+	for _, result := range found {
+		rev := result.upstream
+		fmt.Printf("%s: %s at %s (%s), %v (%v at the current revision)\n", c.relTarget(result.filename), c.sourceLabel(result.matchedFilename), rev.hash, rev.date, percentage(rev.similarity), percentage(result.matchSimilarity))
+	}
+}
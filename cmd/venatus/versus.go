@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+var versus = flag.Bool("versus", false, "with exactly two --source repos (e.g. two upstream versions, or two candidate upstream projects), report for each target file which of them it matches better, and by how much")
+
+// bestBySource returns the best score among the candidates from each source repo, in order.
+func (c *comparison) bestBySource(path string, candidates []candidate) []float64 {
+	best := make([]float64, len(c.sources))
+	for _, cand := range candidates {
+		s := c.sourceOf(cand.filename)
+		for i := range c.sources {
+			if c.sources[i] == s {
+				best[i] = max(best[i], calibrate(path, cand.similarity))
+			}
+		}
+	}
+	return best
+}
+
+// printVersus tabularizes which of the two sources each target file matches better, with --versus,
+// followed by how many files and lines of code match each better.
+func printVersus(c *comparison) {
+	if !*versus || len(c.sources) != 2 {
+		return
+	}
+	a, b := c.sources[0].name, c.sources[1].name
+	tw := table.NewWriter()
+	tw.SetStyle(table.StyleDouble)
+	tw.AppendHeader(table.Row{"Path", a, b, "Better match", "Margin"})
+	var files, lines [3]int
+	for _, result := range c.results {
+		if len(result.bySource) < 2 {
+			// Resumed from a checkpoint saved before --versus scores were.
+			continue
+		}
+		scoreA, scoreB := result.bySource[0], result.bySource[1]
+		better, which := "", 2
+		switch {
+		case scoreA > scoreB:
+			better, which = a, 0
+		case scoreB > scoreA:
+			better, which = b, 1
+		case scoreA > 0:
+			better = "either"
+		}
+		files[which]++
+		lines[which] += result.lineCount
+		margin := scoreA - scoreB
+		if margin < 0 {
+			margin = -margin
+		}
+		tw.AppendRow(table.Row{c.relTarget(result.filename), percentage(scoreA), percentage(scoreB), better, percentage(margin)})
+	}
+	tw.SetColumnConfigs([]table.ColumnConfig{
+		{Number: 2, Align: text.AlignRight},
+		{Number: 3, Align: text.AlignRight},
+		{Number: 5, Align: text.AlignRight},
+	})
+	fmt.Printf("\n\nWhich source each target file matches better:\n%s\n", tw.Render())
+	fmt.Printf("%s matches better for %d files (%d LoC), %s for %d files (%d LoC), and neither better for %d files (%d LoC).\n",
+		a, files[0], lines[0], b, files[1], lines[1], files[2], lines[2])
+}
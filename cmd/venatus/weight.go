@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"unicode"
+)
+
+var weightBy = flag.String("weight-by", "lines", "what to weight each file's score by in totals and subtotals: lines, tokens, bytes, or complexity (how many branches the code has, so that big tables of data count for little and logic-heavy files for more)")
+
+// tokenCount counts the tokens in normalized code.
+func tokenCount(code string) int {
+	n := 0
+	forEachToken(code, func(string) { n++ })
+	return n
+}
+
+// A tokenizer calls fn with each token in normalized code. A nil tokenizer is forEachToken.
+type tokenizer func(code string, fn func(token string))
+
+func (t tokenizer) each(code string, fn func(token string)) {
+	if t == nil {
+		t = forEachToken
+	}
+	t(code, fn)
+}
+
+// forEachToken calls fn with each token in normalized code, roughly: each run of letters, digits,
+// and underscores is one token, and so is each other non-space character.
+func forEachToken(code string, fn func(token string)) {
+	start := -1
+	for i, r := range code {
+		word := r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+		if start >= 0 && !word {
+			fn(code[start:i])
+			start = -1
+		}
+		if word && start < 0 {
+			start = i
+		} else if !word && !unicode.IsSpace(r) {
+			fn(string(r))
+		}
+	}
+	if start >= 0 {
+		fn(code[start:])
+	}
+}
+
+// Tokens that branch: keywords of the languages venatus knows, and the conditional operator.
+// && and || are counted too, as two tokens each.
+var branchTokens = map[string]bool{
+	"if": true, "elif": true, "elsif": true, "for": true, "foreach": true, "while": true,
+	"case": true, "when": true, "catch": true, "except": true, "rescue": true, "?": true,
+}
+
+// complexity measures how much logic normalized code has, roughly as its cyclomatic complexity:
+// one more than the number of places it branches.
+func complexity(code string) int {
+	n := 1
+	prev := ""
+	forEachToken(code, func(token string) {
+		if branchTokens[token] || (token == "&" || token == "|") && token == prev {
+			n++
+			// So that &&& counts once.
+			token = ""
+		}
+		prev = token
+	})
+	return n
+}
+
+// weight is how much a result counts towards totals and subtotals, per --weight-by and
+// --extension-weights. Weighting by lines over-weights files with many short lines; tokens and
+// bytes don't.
+func (r *findResult) weight() float64 {
+	size := float64(r.lineCount)
+	switch *weightBy {
+	case "tokens":
+		size = float64(r.tokenCount)
+	case "bytes":
+		size = float64(r.byteCount)
+	case "complexity":
+		size = float64(r.complexity)
+	}
+	return size * extensionWeight(r.filename)
+}
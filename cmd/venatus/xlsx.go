@@ -0,0 +1,210 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+var xlsxPath = flag.String("xlsx", "", "write the results to this Excel workbook, with a sheet of files and a sheet of directories, scores formatted as percentages and shaded from red to green")
+
+// An xlsxCell is a cell of a sheet: a string, a number, or a percentage.
+type xlsxCell struct {
+	text    string
+	number  float64
+	isText  bool
+	percent bool
+}
+
+func textCell(s string) xlsxCell { return xlsxCell{text: s, isText: true} }
+
+func numberCell(n float64) xlsxCell { return xlsxCell{number: n} }
+
+func percentCell(p float64) xlsxCell { return xlsxCell{number: p, percent: true} }
+
+// An xlsxSheet is a sheet of a workbook: a header row, then rows of cells. Columns listed in
+// shaded are colored by their values, as scores.
+type xlsxSheet struct {
+	name   string
+	header []string
+	rows   [][]xlsxCell
+	shaded []int
+}
+
+// writeXLSX writes the results as a workbook. An XLSX file is a zip of XML parts; only those
+// Excel needs are written, with strings inline rather than in a shared table, to keep this simple.
+func writeXLSX(path string, c *comparison) error {
+	files := xlsxSheet{name: "Files", header: []string{"Path", "Best match", "License", "Score", "Confidence", "LoC"}, shaded: []int{3}}
+	for _, result := range c.results {
+		match := ""
+		if result.matchedFilename != "N/A" {
+			match = c.sourceLabel(result.matchedFilename)
+		}
+		files.rows = append(files.rows, []xlsxCell{
+			textCell(c.relTarget(result.filename)), textCell(match), textCell(result.license),
+			percentCell(result.matchSimilarity), percentCell(result.confidence), numberCell(float64(result.lineCount)),
+		})
+	}
+	dirs := xlsxSheet{name: "Directories", header: []string{"Directory", "Score", "Files", "LoC"}, shaded: []int{1}}
+	for _, dir := range c.directories() {
+		dirs.rows = append(dirs.rows, []xlsxCell{
+			textCell(dir.name + "/"), percentCell(dir.score), numberCell(float64(len(dir.results))), numberCell(float64(dir.lineCount)),
+		})
+	}
+	sheets := []xlsxSheet{files, dirs}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	zw := zip.NewWriter(f)
+	parts := []xlsxPart{
+		{"[Content_Types].xml", xlsxContentTypes(len(sheets))},
+		{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+		{"xl/workbook.xml", xlsxWorkbook(sheets)},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels(len(sheets))},
+		{"xl/styles.xml", xlsxStyles},
+	}
+	for i, sheet := range sheets {
+		parts = append(parts, xlsxPart{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), sheet.xml()})
+	}
+	for _, part := range parts {
+		w, err := zw.Create(part.name)
+		if err == nil {
+			_, err = io.WriteString(w, part.xml)
+		}
+		if err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// An xlsxPart is a file in the zip of a workbook.
+type xlsxPart struct {
+	name string
+	xml  string
+}
+
+func xlsxContentTypes(sheets int) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`)
+	b.WriteString(`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`)
+	b.WriteString(`<Default Extension="xml" ContentType="application/xml"/>`)
+	b.WriteString(`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+	b.WriteString(`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	for i := 1; i <= sheets; i++ {
+		fmt.Fprintf(&b, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i)
+	}
+	b.WriteString(`</Types>`)
+	return b.String()
+}
+
+func xlsxWorkbook(sheets []xlsxSheet) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	for i, sheet := range sheets {
+		fmt.Fprintf(&b, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xmlEscape(sheet.name), i+1, i+1)
+	}
+	// Excel keeps the ranges of sheets' filters as hidden names.
+	b.WriteString(`</sheets><definedNames>`)
+	for i, sheet := range sheets {
+		fmt.Fprintf(&b, `<definedName name="_xlnm._FilterDatabase" localSheetId="%d" hidden="1">'%s'!%s</definedName>`, i, xmlEscape(sheet.name), sheet.filterRange(true))
+	}
+	b.WriteString(`</definedNames></workbook>`)
+	return b.String()
+}
+
+func xlsxWorkbookRels(sheets int) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i := 1; i <= sheets; i++ {
+		fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i, i)
+	}
+	fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, sheets+1)
+	b.WriteString(`</Relationships>`)
+	return b.String()
+}
+
+// The cell styles: 0 is plain, 1 is a bold header, and 2 is a percentage with one decimal place.
+const xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<numFmts count="1"><numFmt numFmtId="164" formatCode="0.0%"/></numFmts>
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="3"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/><xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/></cellXfs>
+</styleSheet>`
+
+// xlsxColumn returns the letter of a column, from 0. Sheets here have few enough columns for one.
+func xlsxColumn(i int) string {
+	return string(rune('A' + i))
+}
+
+func (s xlsxSheet) xml() string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	// Keep the header in view while scrolling.
+	b.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	b.WriteString(`<sheetData><row r="1">`)
+	for i, name := range s.header {
+		fmt.Fprintf(&b, `<c r="%s1" t="inlineStr" s="1"><is><t>%s</t></is></c>`, xlsxColumn(i), xmlEscape(name))
+	}
+	b.WriteString(`</row>`)
+	for r, row := range s.rows {
+		fmt.Fprintf(&b, `<row r="%d">`, r+2)
+		for i, cell := range row {
+			ref := fmt.Sprintf("%s%d", xlsxColumn(i), r+2)
+			switch {
+			case cell.isText:
+				fmt.Fprintf(&b, `<c r="%s" t="inlineStr"><is><t>%s</t></is></c>`, ref, xmlEscape(cell.text))
+			case cell.percent:
+				fmt.Fprintf(&b, `<c r="%s" s="2"><v>%g</v></c>`, ref, cell.number)
+			default:
+				fmt.Fprintf(&b, `<c r="%s"><v>%g</v></c>`, ref, cell.number)
+			}
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData>`)
+	last := len(s.rows) + 1
+	fmt.Fprintf(&b, `<autoFilter ref="%s"/>`, s.filterRange(false))
+	// Shade scores from red at 0%, through yellow at 80%, to green at 100%.
+	for i, col := range s.shaded {
+		fmt.Fprintf(&b, `<conditionalFormatting sqref="%[1]s2:%[1]s%[2]d"><cfRule type="colorScale" priority="%[3]d"><colorScale>`, xlsxColumn(col), max(last, 2), i+1)
+		b.WriteString(`<cfvo type="num" val="0"/><cfvo type="num" val="0.8"/><cfvo type="num" val="1"/>`)
+		b.WriteString(`<color rgb="FFF8696B"/><color rgb="FFFFEB84"/><color rgb="FF63BE7B"/></colorScale></cfRule></conditionalFormatting>`)
+	}
+	b.WriteString(`</worksheet>`)
+	return b.String()
+}
+
+// filterRange returns the range of the sheet's cells, header and all, absolute or not.
+func (s xlsxSheet) filterRange(absolute bool) string {
+	format := "A1:%s%d"
+	if absolute {
+		format = "$A$1:$%s$%d"
+	}
+	return fmt.Sprintf(format, xlsxColumn(len(s.header)-1), len(s.rows)+1)
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -3,13 +3,16 @@ module github.com/chrisfenner/venatus
 go 1.21.6
 
 require (
-	github.com/jedib0t/go-pretty/v6 v6.5.4 // indirect
+	github.com/jedib0t/go-pretty/v6 v6.5.4
+	github.com/schollz/progressbar/v3 v3.14.1
+	github.com/sergi/go-diff v1.3.1
+	golang.org/x/sync v0.6.0
+)
+
+require (
 	github.com/mattn/go-runewidth v0.0.15 // indirect
 	github.com/mitchellh/colorstring v0.0.0-20190213212951-d06e56a500db // indirect
 	github.com/rivo/uniseg v0.4.4 // indirect
-	github.com/schollz/progressbar/v3 v3.14.1 // indirect
-	github.com/sergi/go-diff v1.3.1 // indirect
-	golang.org/x/sync v0.6.0 // indirect
 	golang.org/x/sys v0.16.0 // indirect
 	golang.org/x/term v0.16.0 // indirect
 )